@@ -0,0 +1,169 @@
+// Package clickstream publishes raw click events for downstream consumers.
+// Redirects hand clicks to a buffered publisher that never blocks them; a
+// background loop hashes visitor IPs and writes the clicks to a Redis Stream
+// in batches. Clicks arriving while the buffer is full are dropped and
+// counted.
+package clickstream
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"sync/atomic"
+	"time"
+	"url-shortener/repository"
+)
+
+type Config struct {
+	Enabled bool   `yaml:"enabled"`
+	Stream  string `yaml:"stream"`
+	// MaxLen is roughly how many events the stream keeps.
+	MaxLen int64 `yaml:"max_len"`
+	// Buffer is how many clicks may wait to be written before new ones are
+	// dropped.
+	Buffer int `yaml:"buffer"`
+	// IPSalt is mixed into IP hashes so they cannot be reversed by hashing
+	// every address. A random salt is used if it is empty, which changes the
+	// hashes on every restart.
+	IPSalt string `yaml:"ip_salt"`
+}
+
+// Click is a redirect as seen by the controller.
+type Click struct {
+	Key       string
+	At        time.Time
+	IP        string
+	Referrer  string
+	UserAgent string
+	Variant   int
+}
+
+// Publisher accepts clicks without blocking the redirect.
+type Publisher interface {
+	Publish(click Click)
+}
+
+// Writer stores a batch of click events.
+type Writer interface {
+	WriteClicks(ctx context.Context, events []repository.ClickEvent) error
+}
+
+const (
+	maxBatch = 100
+	// dropReportInterval limits how often dropped clicks are logged.
+	dropReportInterval = time.Minute
+)
+
+// Buffered is a Publisher that queues clicks in memory for Run to write.
+type Buffered struct {
+	writer  Writer
+	salt    []byte
+	clicks  chan Click
+	dropped atomic.Int64
+}
+
+func NewBuffered(writer Writer, cfg Config) (*Buffered, error) {
+	salt := []byte(cfg.IPSalt)
+	if len(salt) == 0 {
+		salt = make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+	}
+	return &Buffered{
+		writer: writer,
+		salt:   salt,
+		clicks: make(chan Click, cfg.Buffer),
+	}, nil
+}
+
+// Publish queues click, or drops it if the buffer is full.
+func (b *Buffered) Publish(click Click) {
+	select {
+	case b.clicks <- click:
+	default:
+		b.dropped.Add(1)
+	}
+}
+
+// Dropped returns how many clicks were dropped because the buffer was full.
+func (b *Buffered) Dropped() int64 {
+	return b.dropped.Load()
+}
+
+// Run writes queued clicks until ctx is done, then writes what is left in
+// the buffer.
+func (b *Buffered) Run(ctx context.Context) {
+	var reported int64
+	lastReport := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// The context is gone, but the clicks are still worth saving.
+			b.drain(context.WithoutCancel(ctx))
+			return
+		case click := <-b.clicks:
+			b.write(ctx, b.batch(click))
+		}
+
+		if dropped := b.Dropped(); dropped > reported && time.Since(lastReport) >= dropReportInterval {
+			slog.WarnContext(ctx, "click events dropped", "dropped", dropped-reported, "total", dropped)
+			reported, lastReport = dropped, time.Now()
+		}
+	}
+}
+
+// batch collects first and the clicks already waiting behind it.
+func (b *Buffered) batch(first Click) []repository.ClickEvent {
+	events := []repository.ClickEvent{b.event(first)}
+	for len(events) < maxBatch {
+		select {
+		case click := <-b.clicks:
+			events = append(events, b.event(click))
+		default:
+			return events
+		}
+	}
+	return events
+}
+
+func (b *Buffered) drain(ctx context.Context) {
+	for {
+		select {
+		case click := <-b.clicks:
+			b.write(ctx, b.batch(click))
+		default:
+			return
+		}
+	}
+}
+
+func (b *Buffered) write(ctx context.Context, events []repository.ClickEvent) {
+	if err := b.writer.WriteClicks(ctx, events); err != nil {
+		slog.WarnContext(ctx, "failed to write click events", "events", len(events), "error", err)
+	}
+}
+
+func (b *Buffered) event(click Click) repository.ClickEvent {
+	return repository.ClickEvent{
+		Key:       click.Key,
+		At:        click.At,
+		IPHash:    b.hashIP(click.IP),
+		Referrer:  click.Referrer,
+		UserAgent: click.UserAgent,
+		Variant:   click.Variant,
+	}
+}
+
+func (b *Buffered) hashIP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, b.salt)
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
@@ -0,0 +1,102 @@
+package clickstream
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"url-shortener/repository"
+)
+
+type fakeWriter struct {
+	mu      sync.Mutex
+	batches [][]repository.ClickEvent
+}
+
+func (w *fakeWriter) WriteClicks(ctx context.Context, events []repository.ClickEvent) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.batches = append(w.batches, events)
+	return nil
+}
+
+func (w *fakeWriter) events() []repository.ClickEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var events []repository.ClickEvent
+	for _, batch := range w.batches {
+		events = append(events, batch...)
+	}
+	return events
+}
+
+func TestBuffered_DropsWhenFull(t *testing.T) {
+	writer := &fakeWriter{}
+	b, err := NewBuffered(writer, Config{Buffer: 2, IPSalt: "pepper"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Nothing is writing yet, so only the buffer's worth is kept.
+	for _, key := range []string{"a", "b", "c", "d"} {
+		b.Publish(Click{Key: key})
+	}
+	if got := b.Dropped(); got != 2 {
+		t.Errorf("Dropped() = %d, want 2", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.Run(ctx)
+
+	events := writer.events()
+	if len(events) != 2 || events[0].Key != "a" || events[1].Key != "b" {
+		t.Errorf("expected the buffered clicks to be written on shutdown, got %+v", events)
+	}
+}
+
+func TestBuffered_Run(t *testing.T) {
+	writer := &fakeWriter{}
+	b, _ := NewBuffered(writer, Config{Buffer: 10, IPSalt: "pepper"})
+	other, _ := NewBuffered(writer, Config{Buffer: 10, IPSalt: "salt"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.Run(ctx)
+		close(done)
+	}()
+
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	b.Publish(Click{Key: "abc", At: at, IP: "203.0.113.7", Referrer: "https://news.example", UserAgent: "curl/8", Variant: 2})
+	b.Publish(Click{Key: "abc", At: at, IP: "203.0.113.7"})
+	b.Publish(Click{Key: "abc", At: at})
+
+	deadline := time.Now().Add(time.Second)
+	for len(writer.events()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	events := writer.events()
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	first := events[0]
+	if first.Key != "abc" || !first.At.Equal(at) || first.Referrer != "https://news.example" || first.UserAgent != "curl/8" || first.Variant != 2 {
+		t.Errorf("unexpected event: %+v", first)
+	}
+	if len(first.IPHash) != 32 || first.IPHash == "203.0.113.7" {
+		t.Errorf("expected a hashed IP, got %q", first.IPHash)
+	}
+	if events[1].IPHash != first.IPHash {
+		t.Error("expected the same IP to hash the same")
+	}
+	if other.hashIP("203.0.113.7") == first.IPHash {
+		t.Error("expected the salt to change the hash")
+	}
+	if events[2].IPHash != "" {
+		t.Errorf("expected no hash without an IP, got %q", events[2].IPHash)
+	}
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/repository"
+	"url-shortener/service"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var errUsage = errors.New("invalid usage")
+
+type cli struct {
+	svc service.ShortenerService
+	out *printer
+	// rdb, prefix and stream are only used to migrate keys, which works
+	// below the service.
+	rdb    *redis.Client
+	prefix string
+	stream string
+}
+
+func (c *cli) run(ctx context.Context, command string, args []string) error {
+	switch command {
+	case "create":
+		return c.create(ctx, args)
+	case "resolve":
+		return c.resolve(ctx, args)
+	case "inspect":
+		return c.inspect(ctx, args)
+	case "delete":
+		return c.delete(ctx, args)
+	case "list":
+		return c.list(ctx, args)
+	case "apikey":
+		return c.apikey(ctx, args)
+	case "purge-expired":
+		return c.purgeExpired(ctx)
+	case "recheck":
+		return c.recheck(ctx)
+	case "disabled":
+		return c.disabled(ctx, args)
+	case "migrate-keys":
+		return c.migrateKeys(ctx, args)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+func (c *cli) create(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	url := fs.String("url", "", "URL to shorten")
+	alias := fs.String("alias", "", "custom key")
+	ttl := fs.Duration("ttl", 0, "lifetime of the link, 0 for no expiry")
+	owner := fs.String("owner", "", "owner of the link")
+	interstitial := fs.Duration("interstitial", 0, "show a preview page for this long before redirecting")
+	password := fs.String("password", "", "password visitors must enter")
+	maxClicks := fs.Int64("max-clicks", 0, "burn the link after this many visits, 0 for no limit")
+	var notBefore, notAfter time.Time
+	fs.Func("not-before", "RFC 3339 time the link goes live", timeFlag(&notBefore))
+	fs.Func("not-after", "RFC 3339 time the link stops working", timeFlag(&notAfter))
+	fallbackURL := fs.String("fallback-url", "", "where visitors go outside the activation window")
+	var rules []repository.Rule
+	fs.Func("rules", `redirect rules as JSON, e.g. [{"platform":"ios","url":"https://apps.apple.com/..."}]`, func(value string) error {
+		return json.Unmarshal([]byte(value), &rules)
+	})
+	var variants []repository.Variant
+	fs.Func("variants", `A/B split as JSON, e.g. [{"url":"https://example.com/a","weight":1},...]`, func(value string) error {
+		return json.Unmarshal([]byte(value), &variants)
+	})
+	var utm repository.UTM
+	fs.StringVar(&utm.Source, "utm-source", "", "utm_source added to the destination")
+	fs.StringVar(&utm.Medium, "utm-medium", "", "utm_medium added to the destination")
+	fs.StringVar(&utm.Campaign, "utm-campaign", "", "utm_campaign added to the destination")
+	forwardQuery := fs.String("forward-query", "", "pass the visitor's query on: keep, override or append")
+	forwardPath := fs.Bool("forward-path", false, "append the path after the key to the destination")
+	fs.Parse(args)
+
+	if *url == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	key, err := c.svc.ShortenURL(ctx, service.ShortenRequest{
+		URL:          *url,
+		Owner:        *owner,
+		Alias:        *alias,
+		TTL:          *ttl,
+		Interstitial: *interstitial,
+		Password:     *password,
+		MaxClicks:    *maxClicks,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		FallbackURL:  *fallbackURL,
+		Rules:        rules,
+		Variants:     variants,
+		UTM:          utm,
+		ForwardQuery: repository.QueryForwarding(*forwardQuery),
+		ForwardPath:  *forwardPath,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.out.record(map[string]any{"key": key, "url": *url}, []string{"key", "url"})
+}
+
+func (c *cli) resolve(ctx context.Context, args []string) error {
+	key, err := keyArg("resolve", args)
+	if err != nil {
+		return err
+	}
+
+	// Inspecting instead of resolving keeps the click count untouched.
+	link, err := c.svc.InspectLink(ctx, adminIdentity, key)
+	if err != nil {
+		return err
+	}
+
+	return c.out.record(map[string]any{"key": link.Key, "url": link.URL}, []string{"key", "url"})
+}
+
+func (c *cli) inspect(ctx context.Context, args []string) error {
+	key, err := keyArg("inspect", args)
+	if err != nil {
+		return err
+	}
+
+	link, err := c.svc.InspectLink(ctx, adminIdentity, key)
+	if err != nil {
+		return err
+	}
+
+	fields := linkFields(link)
+	columns := append(linkColumns, "expires_at")
+	fields["expires_at"] = formatTime(link.ExpiresAt)
+	if link.MaxClicks > 0 {
+		fields["max_clicks"] = link.MaxClicks
+		fields["remaining_clicks"] = link.RemainingClicks
+		columns = append(columns, "max_clicks", "remaining_clicks")
+	}
+	if !link.NotBefore.IsZero() || !link.NotAfter.IsZero() {
+		fields["not_before"] = formatTime(link.NotBefore)
+		fields["not_after"] = formatTime(link.NotAfter)
+		fields["fallback_url"] = link.FallbackURL
+		columns = append(columns, "not_before", "not_after", "fallback_url")
+	}
+	if len(link.Rules) > 0 {
+		rules, _ := json.Marshal(link.Rules)
+		fields["rules"] = string(rules)
+		columns = append(columns, "rules")
+	}
+	if len(link.Variants) > 0 {
+		split := make([]string, len(link.Variants))
+		for i, variant := range link.Variants {
+			split[i] = fmt.Sprintf("%s weight=%d clicks=%d", variant.URL, variant.Weight, variant.Clicks)
+		}
+		fields["variants"] = strings.Join(split, "; ")
+		columns = append(columns, "variants")
+	}
+	return c.out.record(fields, columns)
+}
+
+func (c *cli) delete(ctx context.Context, args []string) error {
+	key, err := keyArg("delete", args)
+	if err != nil {
+		return err
+	}
+
+	if err := c.svc.DeleteURL(ctx, adminIdentity, key); err != nil {
+		return err
+	}
+
+	return c.out.record(map[string]any{"deleted": key}, []string{"deleted"})
+}
+
+func (c *cli) list(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	owner := fs.String("owner", "", "owner whose links are listed")
+	sort := fs.String("sort", string(repository.SortByCreated), "sort by created or clicks")
+	asc := fs.Bool("asc", false, "oldest or least clicked first")
+	limit := fs.Int("limit", 20, "page size")
+	cursor := fs.String("cursor", "", "cursor printed by the previous page")
+	fs.Parse(args)
+
+	if *owner == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	page, err := c.svc.ListLinks(ctx, service.ListRequest{
+		Owner:     *owner,
+		Sort:      repository.SortField(*sort),
+		Ascending: *asc,
+		Cursor:    *cursor,
+		Limit:     *limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	rows := make([]map[string]any, len(page.Links))
+	for i, link := range page.Links {
+		rows[i] = linkFields(link)
+	}
+	if err := c.out.table(rows, linkColumns); err != nil {
+		return err
+	}
+	if page.NextCursor != "" {
+		c.out.note("next cursor: " + page.NextCursor)
+	}
+	return nil
+}
+
+func (c *cli) apikey(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: apikey create|list|revoke", errUsage)
+	}
+
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("apikey create", flag.ExitOnError)
+		owner := fs.String("owner", "", "owner the key acts as")
+		admin := fs.Bool("admin", false, "grant admin access")
+		fs.Parse(args[1:])
+
+		token, key, err := c.svc.CreateAPIKey(ctx, *owner, *admin)
+		if err != nil {
+			return err
+		}
+		fields := apiKeyFields(key)
+		fields["token"] = token
+		if err := c.out.record(fields, append([]string{"token"}, apiKeyColumns...)); err != nil {
+			return err
+		}
+		c.out.note("store the token now, it cannot be shown again")
+		return nil
+
+	case "list":
+		keys, err := c.svc.ListAPIKeys(ctx)
+		if err != nil {
+			return err
+		}
+		rows := make([]map[string]any, len(keys))
+		for i, key := range keys {
+			rows[i] = apiKeyFields(key)
+		}
+		return c.out.table(rows, apiKeyColumns)
+
+	case "revoke":
+		id, err := keyArg("apikey revoke", args[1:])
+		if err != nil {
+			return err
+		}
+		if err := c.svc.RevokeAPIKey(ctx, id); err != nil {
+			return err
+		}
+		return c.out.record(map[string]any{"revoked": id}, []string{"revoked"})
+
+	default:
+		return fmt.Errorf("unknown apikey command %q", args[0])
+	}
+}
+
+func (c *cli) purgeExpired(ctx context.Context) error {
+	purged, err := c.svc.PurgeExpired(ctx)
+	if err != nil {
+		return err
+	}
+	return c.out.record(map[string]any{"purged": purged}, []string{"purged"})
+}
+
+func (c *cli) recheck(ctx context.Context) error {
+	summary, err := c.svc.RecheckLinks(ctx)
+	if err != nil {
+		return err
+	}
+	return c.out.record(map[string]any{"checked": summary.Checked, "disabled": summary.Disabled}, []string{"checked", "disabled"})
+}
+
+func (c *cli) disabled(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: disabled list|enable", errUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		links, err := c.svc.ListDisabledLinks(ctx)
+		if err != nil {
+			return err
+		}
+		rows := make([]map[string]any, len(links))
+		for i, link := range links {
+			rows[i] = map[string]any{
+				"key":         link.Key,
+				"url":         link.URL,
+				"reason":      link.DisabledReason,
+				"disabled_at": formatTime(link.DisabledAt),
+			}
+		}
+		return c.out.table(rows, []string{"key", "url", "reason", "disabled_at"})
+
+	case "enable":
+		key, err := keyArg("disabled enable", args[1:])
+		if err != nil {
+			return err
+		}
+		if err := c.svc.EnableLink(ctx, key); err != nil {
+			return err
+		}
+		return c.out.record(map[string]any{"enabled": key}, []string{"enabled"})
+
+	default:
+		return fmt.Errorf("unknown disabled command %q", args[0])
+	}
+}
+
+func (c *cli) migrateKeys(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("migrate-keys", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only list the keys that would be moved")
+	verbose := fs.Bool("v", false, "list every moved key, not only conflicts")
+	allLinks := fs.Bool("all-links", false, "move every string that looks like a link key; only for a database of its own")
+	keysFile := fs.String("keys-file", "", `file with one link key per line to move, "-" for stdin`)
+	fs.Parse(args)
+
+	links := fs.Args()
+	if *keysFile != "" {
+		listed, err := readKeys(*keysFile)
+		if err != nil {
+			return err
+		}
+		links = append(links, listed...)
+	}
+
+	opts := repository.MigrateOptions{
+		Prefix:      c.prefix,
+		DryRun:      *dryRun,
+		Links:       links,
+		AllLinks:    *allLinks,
+		ClickStream: c.stream,
+	}
+	var moved, conflicts int
+	var rows []map[string]any
+	err := repository.MigrateKeys(ctx, c.rdb, opts, func(m repository.KeyMigration) error {
+		status := "moved"
+		switch {
+		case m.Conflict:
+			status = "conflict"
+			conflicts++
+		case *dryRun:
+			status = "would move"
+			moved++
+		default:
+			moved++
+		}
+		if m.Conflict || *verbose || *dryRun {
+			rows = append(rows, map[string]any{"from": m.From, "to": m.To, "status": status})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(rows) > 0 {
+		if err := c.out.table(rows, []string{"from", "to", "status"}); err != nil {
+			return err
+		}
+	}
+	if conflicts > 0 {
+		c.out.note("conflicting keys already exist under the prefix and were left in place")
+	}
+	if *dryRun {
+		return c.out.record(map[string]any{"would_move": moved, "conflicts": conflicts}, []string{"would_move", "conflicts"})
+	}
+	return c.out.record(map[string]any{"moved": moved, "conflicts": conflicts}, []string{"moved", "conflicts"})
+}
+
+// readKeys reads one key per line from path, or from stdin for "-",
+// skipping blank lines.
+func readKeys(path string) ([]string, error) {
+	r := io.Reader(os.Stdin)
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var keys []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if key := strings.TrimSpace(scanner.Text()); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys, scanner.Err()
+}
+
+func keyArg(command string, args []string) (string, error) {
+	if len(args) != 1 || args[0] == "" {
+		return "", fmt.Errorf("%w: %s <key>", errUsage, command)
+	}
+	return args[0], nil
+}
+
+var linkColumns = []string{"key", "url", "owner", "created_at", "clicks"}
+
+func linkFields(link repository.Link) map[string]any {
+	return map[string]any{
+		"key":        link.Key,
+		"url":        link.URL,
+		"owner":      link.Owner,
+		"created_at": formatTime(link.CreatedAt),
+		"clicks":     link.Clicks,
+	}
+}
+
+var apiKeyColumns = []string{"id", "owner", "admin", "created_at"}
+
+func apiKeyFields(key repository.APIKey) map[string]any {
+	return map[string]any{
+		"id":         key.ID,
+		"owner":      key.Owner,
+		"admin":      strconv.FormatBool(key.Admin),
+		"created_at": formatTime(key.CreatedAt),
+	}
+}
+
+// timeFlag parses an RFC 3339 flag value into t.
+func timeFlag(t *time.Time) func(string) error {
+	return func(value string) error {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return err
+		}
+		*t = parsed
+		return nil
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
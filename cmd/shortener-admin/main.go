@@ -0,0 +1,105 @@
+// Command shortener-admin manages links and API keys directly in the
+// configured repository.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"url-shortener/config"
+	"url-shortener/geoip"
+	"url-shortener/policy"
+	"url-shortener/repository"
+	"url-shortener/service"
+)
+
+const usage = `usage: shortener-admin [-config path] [-output table|json] <command> [flags]
+
+commands:
+  create         shorten a URL
+  resolve        print the destination of a key
+  inspect        show everything stored about a key
+  delete         delete a link
+  list           list the links of an owner
+  apikey         create, list or revoke API keys
+  purge-expired  remove metadata left behind by expired links
+  recheck        disable links whose destination the policy now blocks
+  disabled       list or re-enable disabled links
+  migrate-keys   move keys of the unprefixed layout under the key prefix;
+                 links without metadata or an owner are only moved when
+                 their keys are given as arguments or in -keys-file, or
+                 with -all-links
+`
+
+// adminIdentity is used for every service call, so the tool can manage links
+// of any owner.
+var adminIdentity = service.Identity{Owner: "shortener-admin", Admin: true}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	configPath := flag.String("config", "config.yaml", "path to the YAML config file")
+	output := flag.String("output", "table", "output format: table or json")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	out, err := newPrinter(os.Stdout, *output)
+	if err != nil {
+		fatal(err)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fatal(fmt.Errorf("failed to load config: %w", err))
+	}
+
+	ctx := context.Background()
+	rdb, err := repository.NewClient(ctx, cfg.Redis)
+	if err != nil {
+		fatal(fmt.Errorf("failed to connect to redis: %w", err))
+	}
+	defer rdb.Close()
+
+	repo := repository.NewRedisRepository(rdb,
+		repository.WithRetention(cfg.Timeseries),
+		repository.WithKeyPrefix(cfg.Redis.Prefix),
+	)
+
+	destinations, err := policy.New(cfg.Policy, repo)
+	if err != nil {
+		fatal(fmt.Errorf("failed to load destination policy: %w", err))
+	}
+
+	svcOpts := []service.Option{service.WithPolicy(destinations)}
+	if cfg.GeoIP.Database != "" {
+		countries, err := geoip.Open(cfg.GeoIP.Database)
+		if err != nil {
+			fatal(fmt.Errorf("failed to open GeoIP database: %w", err))
+		}
+		defer countries.Close()
+		svcOpts = append(svcOpts, service.WithCountryResolver(countries))
+	}
+	cli := &cli{
+		svc:    service.NewShortenerService(repo, svcOpts...),
+		out:    out,
+		rdb:    rdb,
+		prefix: cfg.Redis.Prefix,
+		stream: cfg.Clicks.Stream,
+	}
+
+	if err := cli.run(ctx, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "shortener-admin:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// printer renders command results either as aligned tables for people or as
+// JSON for scripts.
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	switch format {
+	case "table":
+		return &printer{w: w}, nil
+	case "json":
+		return &printer{w: w, json: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q, expected table or json", format)
+	}
+}
+
+func (p *printer) record(fields map[string]any, columns []string) error {
+	if p.json {
+		return p.encode(fields)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	for _, column := range columns {
+		fmt.Fprintf(tw, "%s:\t%v\n", column, fields[column])
+	}
+	return tw.Flush()
+}
+
+func (p *printer) table(rows []map[string]any, columns []string) error {
+	if p.json {
+		return p.encode(rows)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+	for _, row := range rows {
+		values := make([]string, len(columns))
+		for i, column := range columns {
+			values[i] = fmt.Sprint(row[column])
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	return tw.Flush()
+}
+
+// note prints hints for people. They go to stderr so JSON output stays
+// parseable.
+func (p *printer) note(msg string) {
+	fmt.Fprintln(os.Stderr, msg)
+}
+
+func (p *printer) encode(v any) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
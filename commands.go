@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"url-shortener/service"
+	"url-shortener/transfer"
+)
+
+func runExport(ctx context.Context, svc service.ShortenerService, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", string(transfer.FormatJSONL), "output format: jsonl or csv")
+	output := fs.String("o", "-", "output file, - for stdout")
+	fs.Parse(args)
+
+	f, err := transfer.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	return transfer.Export(ctx, w, f, svc)
+}
+
+func runImport(ctx context.Context, svc service.ShortenerService, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", string(transfer.FormatJSONL), "input format: jsonl or csv")
+	fs.Parse(args)
+
+	f, err := transfer.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if path := fs.Arg(0); path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	summary, err := transfer.Import(ctx, r, f, svc)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(summary); err != nil {
+		return err
+	}
+	if summary.Failed > 0 || summary.Conflicts > 0 {
+		return fmt.Errorf("%d conflicts, %d failures", summary.Conflicts, summary.Failed)
+	}
+	return nil
+}
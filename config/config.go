@@ -0,0 +1,184 @@
+package config
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+	"url-shortener/clickstream"
+	"url-shortener/logging"
+	"url-shortener/policy"
+	"url-shortener/ratelimit"
+	"url-shortener/repository"
+	"url-shortener/service"
+	"url-shortener/signing"
+	"url-shortener/tracing"
+	"url-shortener/webhook"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	HTTP      HTTPConfig         `yaml:"http"`
+	Redis     repository.Config  `yaml:"redis"`
+	RateLimit ratelimit.Config   `yaml:"rate_limit"`
+	Batch     BatchConfig        `yaml:"batch"`
+	Policy    policy.Config      `yaml:"policy"`
+	Passwords PasswordConfig     `yaml:"passwords"`
+	GeoIP     GeoIPConfig        `yaml:"geoip"`
+	Log       logging.Config     `yaml:"log"`
+	Tracing   tracing.Config     `yaml:"tracing"`
+	Webhooks  webhook.Config     `yaml:"webhooks"`
+	Clicks    clickstream.Config `yaml:"clicks"`
+	// Timeseries is how long hourly and daily click buckets and the daily
+	// rankings of the admin statistics are kept.
+	Timeseries repository.Retention `yaml:"timeseries"`
+	Signing    signing.Config       `yaml:"signing"`
+	Keys       KeyConfig            `yaml:"keys"`
+	Domains    DomainConfig         `yaml:"domains"`
+}
+
+// GeoIPConfig points at the MaxMind database used by country redirect rules.
+type GeoIPConfig struct {
+	// Database is the path of a GeoLite2 or GeoIP2 Country or City file.
+	// Country rules are rejected while it is empty.
+	Database string `yaml:"database"`
+}
+
+// PasswordConfig limits wrong password attempts per protected link.
+type PasswordConfig struct {
+	MaxAttempts int           `yaml:"max_attempts"`
+	Window      time.Duration `yaml:"window"`
+}
+
+// DomainConfig enables custom domains, registered through the admin API,
+// that each have their own namespace of keys.
+type DomainConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// DefaultHosts are served the links of the default namespace. Requests
+	// for hosts that are neither listed here nor registered are rejected.
+	DefaultHosts []string `yaml:"default_hosts"`
+}
+
+// KeyConfig decides how keys are generated for links without an alias.
+type KeyConfig struct {
+	// Random keys cannot be guessed from the destination. Without it keys
+	// are a hash of the URL and shortening it twice gives the same link.
+	Random bool `yaml:"random"`
+	// Length of random keys, at least service.MinRandomKeyLength.
+	Length int `yaml:"length"`
+}
+
+type BatchConfig struct {
+	MaxSize int `yaml:"max_size"`
+}
+
+type HTTPConfig struct {
+	Addr string `yaml:"addr"`
+	// PublicURL is the scheme and host short links are shared under, used
+	// wherever a full short URL is needed.
+	PublicURL string `yaml:"public_url"`
+	// TemplatesDir holds HTML pages that replace the built-in ones.
+	TemplatesDir string `yaml:"templates_dir"`
+	// TrustedProxies lists the addresses or CIDR ranges whose
+	// X-Forwarded-For header is believed. Without any, the client address
+	// is always the peer's, so rate limits cannot be dodged with a forged
+	// header.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+func Default() Config {
+	return Config{
+		HTTP: HTTPConfig{
+			Addr:      ":8080",
+			PublicURL: "http://localhost:8080",
+		},
+		Redis: repository.Config{
+			Addr:        "localhost:6379",
+			Password:    "test1234",
+			User:        "default",
+			DB:          0,
+			MaxRetries:  5,
+			DialTimeout: 10 * time.Second,
+			Timeout:     5 * time.Second,
+			Prefix:      repository.DefaultKeyPrefix,
+		},
+		RateLimit: ratelimit.Config{
+			Enabled:  true,
+			Backend:  ratelimit.BackendMemory,
+			Create:   ratelimit.Rule{Limit: 30, Window: time.Minute},
+			Redirect: ratelimit.Rule{Limit: 300, Window: time.Minute},
+			Scan: ratelimit.ScanConfig{
+				Enabled:  true,
+				Misses:   ratelimit.Rule{Limit: 20, Window: time.Minute},
+				BlockFor: 15 * time.Minute,
+			},
+			Auth: ratelimit.ScanConfig{
+				Enabled:  true,
+				Misses:   ratelimit.Rule{Limit: 10, Window: time.Minute},
+				BlockFor: 15 * time.Minute,
+			},
+		},
+		Keys: KeyConfig{
+			Length: 10,
+		},
+		Batch: BatchConfig{
+			MaxSize: 1000,
+		},
+		Policy: policy.Config{
+			SelfHosts:       []string{"localhost:8080"},
+			ReloadInterval:  30 * time.Second,
+			RecheckInterval: time.Hour,
+		},
+		Passwords: PasswordConfig{
+			MaxAttempts: 5,
+			Window:      15 * time.Minute,
+		},
+		Log: logging.Config{
+			Level:  "info",
+			Format: logging.FormatJSON,
+		},
+		Tracing: tracing.Config{
+			ServiceName: "url-shortener",
+			SampleRatio: 1,
+		},
+		Webhooks: webhook.Config{
+			MaxAttempts:    8,
+			Backoff:        30 * time.Second,
+			MaxBackoff:     time.Hour,
+			Timeout:        10 * time.Second,
+			PollInterval:   time.Second,
+			ExpiryInterval: 10 * time.Minute,
+			Milestones:     service.DefaultMilestones,
+		},
+		Clicks: clickstream.Config{
+			Stream: "clicks",
+			MaxLen: 1000000,
+			Buffer: 10000,
+		},
+		Timeseries: repository.Retention{
+			Hourly:   7 * 24 * time.Hour,
+			Daily:    365 * 24 * time.Hour,
+			Rankings: 30 * 24 * time.Hour,
+		},
+	}
+}
+
+// Load reads the YAML file at path on top of the defaults. A missing file is
+// not an error so the server can start with the defaults alone.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return Config{}, err
+	}
+
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad_MissingFileUsesDefaults(t *testing.T) {
+	cfg, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.HTTP.Addr != Default().HTTP.Addr {
+		t.Errorf("expected default addr, got %q", cfg.HTTP.Addr)
+	}
+}
+
+func TestLoad_OverridesDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := []byte(`
+redis:
+  addr: "redis:6379"
+  timeout: 2s
+rate_limit:
+  backend: redis
+  create:
+    limit: 5
+    window: 10s
+`)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Redis.Addr != "redis:6379" || cfg.Redis.Timeout != 2*time.Second {
+		t.Errorf("unexpected redis config: %+v", cfg.Redis)
+	}
+	if cfg.Redis.DialTimeout != 10*time.Second {
+		t.Errorf("expected unset fields to keep defaults, got %s", cfg.Redis.DialTimeout)
+	}
+	if cfg.RateLimit.Backend != "redis" || cfg.RateLimit.Create.Limit != 5 || cfg.RateLimit.Create.Window != 10*time.Second {
+		t.Errorf("unexpected rate limit config: %+v", cfg.RateLimit)
+	}
+	if cfg.RateLimit.Redirect.Limit != 300 {
+		t.Errorf("expected redirect budget to keep its default, got %d", cfg.RateLimit.Redirect.Limit)
+	}
+}
+
+func TestLoad_InvalidYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("redis: ["), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for invalid YAML")
+	}
+}
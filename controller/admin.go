@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"expvar"
+	"net/http"
+	"time"
+	"url-shortener/transfer"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (c *Controller) requireAdmin(ctx *gin.Context) {
+	id, ok := identityFrom(ctx)
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse{Error: "api key required"})
+		return
+	}
+	if !id.Admin {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse{Error: "admin access required"})
+		return
+	}
+	ctx.Next()
+}
+
+var exportContentTypes = map[transfer.Format]string{
+	transfer.FormatJSONL: "application/x-ndjson",
+	transfer.FormatCSV:   "text/csv",
+}
+
+// exportLinks godoc
+//
+//	@Summary		Export links
+//	@Description	stream every stored link as JSON lines or CSV
+//	@Tags			admin
+//	@Produce		plain
+//	@Security		ApiKeyAuth
+//	@Param			format	query		string	false	"Output format"	Enums(jsonl, csv)	default(jsonl)
+//	@Success		200		{string}	string	"Exported links"
+//	@Failure		400		{object}	errorResponse
+//	@Failure		401		{object}	errorResponse
+//	@Failure		403		{object}	errorResponse
+//	@Router			/api/v1/admin/export [get]
+func (c *Controller) exportLinks(ctx *gin.Context) {
+	format, err := transfer.ParseFormat(ctx.DefaultQuery("format", string(transfer.FormatJSONL)))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	ctx.Header("Content-Type", exportContentTypes[format])
+	ctx.Header("Content-Disposition", `attachment; filename="links.`+string(format)+`"`)
+	ctx.Status(http.StatusOK)
+
+	// The status is already sent, so a failure can only cut the stream short.
+	if err := transfer.Export(ctx, ctx.Writer, format, c.service); err != nil {
+		_ = ctx.Error(err)
+	}
+}
+
+// importLinks godoc
+//
+//	@Summary		Import links
+//	@Description	import links from JSON lines or CSV, keeping their keys
+//	@Tags			admin
+//	@Accept			plain
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			format	query		string	false	"Input format"	Enums(jsonl, csv)	default(jsonl)
+//	@Param			body	body		string	true	"Links to import"
+//	@Success		200		{object}	transfer.Summary
+//	@Failure		400		{object}	errorResponse
+//	@Failure		401		{object}	errorResponse
+//	@Failure		403		{object}	errorResponse
+//	@Router			/api/v1/admin/import [post]
+func (c *Controller) importLinks(ctx *gin.Context) {
+	format, err := transfer.ParseFormat(ctx.DefaultQuery("format", string(transfer.FormatJSONL)))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	summary, err := transfer.Import(ctx, ctx.Request.Body, format, c.service)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, summary)
+}
+
+type disabledLinkResponse struct {
+	Key        string    `json:"key" example:"abc123"`
+	URL        string    `json:"url" example:"https://example.com"`
+	Owner      string    `json:"owner,omitempty" example:"alice"`
+	Reason     string    `json:"reason" example:"blocklist:example.net"`
+	DisabledAt time.Time `json:"disabled_at"`
+}
+
+// listDisabled godoc
+//
+//	@Summary		List disabled links
+//	@Description	list links disabled because their destination was flagged
+//	@Tags			admin
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{array}		disabledLinkResponse
+//	@Failure		401	{object}	errorResponse
+//	@Failure		403	{object}	errorResponse
+//	@Router			/api/v1/admin/disabled [get]
+func (c *Controller) listDisabled(ctx *gin.Context) {
+	links, err := c.service.ListDisabledLinks(ctx)
+	if err != nil {
+		_ = ctx.Error(err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse{Error: "failed to list disabled links"})
+		return
+	}
+
+	resp := make([]disabledLinkResponse, len(links))
+	for i, link := range links {
+		resp[i] = disabledLinkResponse{
+			Key:        link.Key,
+			URL:        link.URL,
+			Owner:      link.Owner,
+			Reason:     link.DisabledReason,
+			DisabledAt: link.DisabledAt,
+		}
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// enableLink godoc
+//
+//	@Summary		Re-enable link
+//	@Description	put a disabled link back into service; rechecks skip it while its destination is unchanged
+//	@Tags			admin
+//	@Security		ApiKeyAuth
+//	@Param			key	path	string	true	"Short URL key"
+//	@Success		204
+//	@Failure		401	{object}	errorResponse
+//	@Failure		403	{object}	errorResponse
+//	@Failure		404	{object}	errorResponse
+//	@Router			/api/v1/admin/disabled/{key}/enable [post]
+func (c *Controller) enableLink(ctx *gin.Context) {
+	if err := c.service.EnableLink(ctx, ctx.Param("key")); err != nil {
+		c.manageError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// metrics godoc
+//
+//	@Summary		Metrics
+//	@Description	show the process counters published with expvar, such as scan_guard with the clients
+//	@Description	blocked for scanning keys and their refused requests
+//	@Tags			admin
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	map[string]interface{}
+//	@Failure		401	{object}	errorResponse
+//	@Failure		403	{object}	errorResponse
+//	@Router			/api/v1/admin/metrics [get]
+func (c *Controller) metrics(ctx *gin.Context) {
+	expvar.Handler().ServeHTTP(ctx.Writer, ctx.Request)
+}
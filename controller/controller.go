@@ -1,30 +1,350 @@
 package controller
 
 import (
+	"errors"
+	"html/template"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/clickstream"
+	"url-shortener/ratelimit"
+	"url-shortener/repository"
 	"url-shortener/service"
+	"url-shortener/signing"
+	"url-shortener/webhook"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	apiKeyHeader       = "X-API-Key"
+	linkPasswordHeader = "X-Link-Password"
+	identityKey        = "identity"
+)
+
+const defaultMaxBatchSize = 1000
+
+// variantCookieAge is how long visitors stay on the variant they were given.
+const variantCookieAge = 30 * 24 * time.Hour
+
 type Controller struct {
-	service service.ShortenerService
+	service         service.ShortenerService
+	createLimiter   ratelimit.Limiter
+	redirectLimiter ratelimit.Limiter
+	maxBatchSize    int
+	templates       *template.Template
+	baseURL         string
+	webhooks        webhook.Registry
+	clicks          clickstream.Publisher
+	signer          *signing.Signer
+	scanGuard       *ratelimit.ScanGuard
+	authGuard       *ratelimit.ScanGuard
+	domains         bool
+	defaultHosts    []string
+}
+
+type Option func(*Controller)
+
+// WithCreateLimiter limits how often a client may shorten URLs.
+func WithCreateLimiter(l ratelimit.Limiter) Option {
+	return func(c *Controller) {
+		c.createLimiter = l
+	}
+}
+
+// WithRedirectLimiter limits how often a client may resolve short keys.
+func WithRedirectLimiter(l ratelimit.Limiter) Option {
+	return func(c *Controller) {
+		c.redirectLimiter = l
+	}
+}
+
+// WithScanGuard blocks clients that request too many unknown keys from the
+// redirect routes.
+func WithScanGuard(g *ratelimit.ScanGuard) Option {
+	return func(c *Controller) {
+		c.scanGuard = g
+	}
+}
+
+// WithDomains serves registered custom domains, each with its own keys, and
+// rejects requests for any other host than defaultHosts, which share the
+// default namespace.
+func WithDomains(defaultHosts ...string) Option {
+	return func(c *Controller) {
+		c.domains = true
+		for _, host := range defaultHosts {
+			c.defaultHosts = append(c.defaultHosts, strings.ToLower(host))
+		}
+	}
+}
+
+// WithAuthGuard blocks clients that send too many invalid API keys, see
+// ratelimit.NewAuthGuard.
+func WithAuthGuard(g *ratelimit.ScanGuard) Option {
+	return func(c *Controller) {
+		c.authGuard = g
+	}
+}
+
+// WithMaxBatchSize caps the number of URLs accepted by one batch request.
+func WithMaxBatchSize(n int) Option {
+	return func(c *Controller) {
+		c.maxBatchSize = n
+	}
+}
+
+// WithBaseURL sets the scheme and host short URLs are served from, for
+// example https://sho.rt. Without it the request's host is used.
+func WithBaseURL(url string) Option {
+	return func(c *Controller) {
+		c.baseURL = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithTemplates replaces the HTML pages, see LoadTemplates.
+func WithTemplates(t *template.Template) Option {
+	return func(c *Controller) {
+		c.templates = t
+	}
+}
+
+// WithWebhooks enables the webhook subscription endpoints.
+func WithWebhooks(r webhook.Registry) Option {
+	return func(c *Controller) {
+		c.webhooks = r
+	}
+}
+
+// WithClickPublisher publishes every counted redirect to p.
+func WithClickPublisher(p clickstream.Publisher) Option {
+	return func(c *Controller) {
+		c.clicks = p
+	}
 }
 
-func NewController(service service.ShortenerService) *Controller {
-	return &Controller{service: service}
+func NewController(service service.ShortenerService, opts ...Option) *Controller {
+	c := &Controller{
+		service:      service,
+		maxBatchSize: defaultMaxBatchSize,
+		templates:    defaultTemplates,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 type shortenRequest struct {
-	URL string `json:"url" binding:"required" example:"https://example.com"`
+	URL   string `json:"url" binding:"required" example:"https://example.com"`
+	Alias string `json:"alias,omitempty" example:"launch"`
+	// TTL is the link lifetime in seconds.
+	TTL int64 `json:"ttl,omitempty" example:"86400"`
+	// Interstitial shows visitors a preview page for this many seconds
+	// before redirecting them.
+	Interstitial int64 `json:"interstitial,omitempty" example:"5"`
+	// Password protects the link; visitors must enter it before redirecting.
+	Password string `json:"password,omitempty" example:"hunter2"`
+	// MaxClicks burns the link after this many visits.
+	MaxClicks int64 `json:"max_clicks,omitempty" example:"1"`
+	// NotBefore and NotAfter limit when the link can be followed.
+	NotBefore time.Time `json:"not_before,omitempty" example:"2024-06-01T09:00:00Z"`
+	NotAfter  time.Time `json:"not_after,omitempty" example:"2024-06-30T18:00:00Z"`
+	// FallbackURL receives visitors outside the activation window, who
+	// otherwise get a 404 before it opens and a 410 after it closes.
+	FallbackURL string `json:"fallback_url,omitempty" example:"https://example.com/campaign-over"`
+	// Rules send matching visitors elsewhere; the first match wins.
+	Rules []ruleRequest `json:"rules,omitempty"`
+	// Variants split redirects between destinations by weight. URL is
+	// still shown in previews.
+	Variants []variantRequest `json:"variants,omitempty"`
+	// UTM parameters are added to the destination unless it sets them.
+	UTM utmRequest `json:"utm,omitempty"`
+	// ForwardQuery passes the visitor's query string on: keep leaves the
+	// destination's parameters alone, override replaces them and append
+	// adds to them.
+	ForwardQuery string `json:"forward_query,omitempty" enums:"keep,override,append" example:"keep"`
+	// ForwardPath appends anything after the key to the destination path.
+	ForwardPath bool `json:"forward_path,omitempty"`
+	// Signed links can only be followed through the returned URL, which
+	// carries a signature, so their keys cannot be guessed.
+	Signed bool `json:"signed,omitempty"`
+}
+
+type utmRequest struct {
+	Source   string `json:"source,omitempty" example:"newsletter"`
+	Medium   string `json:"medium,omitempty" example:"email"`
+	Campaign string `json:"campaign,omitempty" example:"spring_sale"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+type variantRequest struct {
+	URL    string `json:"url" example:"https://example.com/landing-b"`
+	Weight int    `json:"weight" example:"50"`
+}
+
+// ruleRequest sends visitors matching all of its conditions to URL.
+type ruleRequest struct {
+	// Platform is one of ios, android, windows, macos or linux.
+	Platform string `json:"platform,omitempty" example:"ios"`
+	// Language matches the visitor's preferred language and its subtags.
+	Language string `json:"language,omitempty" example:"de"`
+	// Country is an ISO 3166-1 alpha-2 code looked up from the client IP.
+	Country string `json:"country,omitempty" example:"DE"`
+	// From and To bound the time of day (HH:MM) in Timezone, UTC if empty.
+	From     string `json:"from,omitempty" example:"09:00"`
+	To       string `json:"to,omitempty" example:"17:00"`
+	Timezone string `json:"timezone,omitempty" example:"Europe/Berlin"`
+	URL      string `json:"url" example:"https://apps.apple.com/app/id123"`
+}
+
+func (r shortenRequest) toService(owner string) service.ShortenRequest {
+	var rules []repository.Rule
+	for _, rule := range r.Rules {
+		rules = append(rules, repository.Rule(rule))
+	}
+	var variants []repository.Variant
+	for _, variant := range r.Variants {
+		variants = append(variants, repository.Variant{URL: variant.URL, Weight: variant.Weight})
+	}
+	return service.ShortenRequest{
+		URL:          r.URL,
+		Owner:        owner,
+		Alias:        r.Alias,
+		TTL:          time.Duration(r.TTL) * time.Second,
+		Interstitial: time.Duration(r.Interstitial) * time.Second,
+		Password:     r.Password,
+		MaxClicks:    r.MaxClicks,
+		NotBefore:    r.NotBefore,
+		NotAfter:     r.NotAfter,
+		FallbackURL:  r.FallbackURL,
+		Rules:        rules,
+		Variants:     variants,
+		UTM:          repository.UTM(r.UTM),
+		ForwardQuery: repository.QueryForwarding(r.ForwardQuery),
+		ForwardPath:  r.ForwardPath,
+		Signed:       r.Signed,
+	}
 }
 
 type shortenResponse struct {
 	URL string `json:"url" example:"abc123"`
 }
 
+type batchRequest struct {
+	Items []shortenRequest `json:"items" binding:"required"`
+}
+
+type batchItemResponse struct {
+	URL   string `json:"url,omitempty" example:"abc123"`
+	Error string `json:"error,omitempty" example:"invalid url"`
+	Rule  string `json:"rule,omitempty" example:"blocklist:example.net"`
+}
+
+type batchResponse struct {
+	Results []batchItemResponse `json:"results"`
+}
+
+type updateRequest struct {
+	URL string `json:"url" binding:"required" example:"https://example.org"`
+}
+
+type linkResponse struct {
+	Key       string    `json:"key" example:"abc123"`
+	URL       string    `json:"url" example:"https://example.com"`
+	CreatedAt time.Time `json:"created_at"`
+	Clicks    int64     `json:"clicks" example:"42"`
+}
+
+type statsResponse struct {
+	Key       string     `json:"key" example:"abc123"`
+	URL       string     `json:"url" example:"https://example.com"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Clicks    int64      `json:"clicks" example:"42"`
+	// MaxClicks and RemainingClicks are only set for click-limited links.
+	MaxClicks       int64  `json:"max_clicks,omitempty" example:"5"`
+	RemainingClicks *int64 `json:"remaining_clicks,omitempty" example:"3"`
+	// NotBefore and NotAfter are only set for scheduled links.
+	NotBefore   *time.Time `json:"not_before,omitempty"`
+	NotAfter    *time.Time `json:"not_after,omitempty"`
+	FallbackURL string     `json:"fallback_url,omitempty" example:"https://example.com/campaign-over"`
+	// Variants are only set for links split between destinations.
+	Variants []variantStats `json:"variants,omitempty"`
+}
+
+type variantStats struct {
+	URL    string `json:"url" example:"https://example.com/landing-b"`
+	Weight int    `json:"weight" example:"50"`
+	Clicks int64  `json:"clicks" example:"21"`
+}
+
+type listResponse struct {
+	Links      []linkResponse `json:"links"`
+	NextCursor string         `json:"next_cursor,omitempty" example:"MjA"`
+}
+
 type errorResponse struct {
 	Error string `json:"error" example:"url not found"`
+	// Rule names the destination policy rule that blocked a URL.
+	Rule string `json:"rule,omitempty" example:"blocklist:example.net"`
+}
+
+// authenticate resolves the caller from the API key header when one is sent.
+// Requests without a key continue anonymously. Clients the auth guard
+// blocked for sending invalid keys are refused before their key is looked
+// up, as the route's rate limit only applies once the caller is known.
+func (c *Controller) authenticate(ctx *gin.Context) {
+	apiKey := ctx.GetHeader(apiKeyHeader)
+	if apiKey == "" {
+		ctx.Next()
+		return
+	}
+
+	client := "ip:" + ctx.ClientIP()
+	if c.authGuard != nil {
+		if d, err := c.authGuard.Blocked(ctx, client); err == nil && d > 0 {
+			ctx.Header("Retry-After", seconds(d))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, errorResponse{Error: "too many invalid api keys"})
+			return
+		}
+	}
+
+	id, err := c.service.Authenticate(ctx, apiKey)
+	if err != nil {
+		if c.authGuard != nil && errors.Is(err, service.ErrUnauthorized) {
+			if blocked, err := c.authGuard.Miss(ctx, client); err != nil {
+				slog.WarnContext(ctx, "failed to record invalid api key", "error", err)
+			} else if blocked {
+				slog.WarnContext(ctx, "blocked client guessing api keys", "client", client)
+			}
+		}
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse{Error: "invalid api key"})
+		return
+	}
+
+	ctx.Set(identityKey, id)
+	ctx.Next()
+}
+
+func (c *Controller) requireIdentity(ctx *gin.Context) {
+	if _, ok := identityFrom(ctx); !ok {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse{Error: "api key required"})
+		return
+	}
+	ctx.Next()
+}
+
+func identityFrom(ctx *gin.Context) (service.Identity, bool) {
+	v, ok := ctx.Get(identityKey)
+	if !ok {
+		return service.Identity{}, false
+	}
+	id, ok := v.(service.Identity)
+	return id, ok
 }
 
 // create godoc
@@ -36,6 +356,11 @@ type errorResponse struct {
 //	@Produce		json
 //	@Param			request	body		shortenRequest	true	"URL to shorten"
 //	@Success		200		{object}	shortenResponse
+//	@Failure		400		{object}	errorResponse
+//	@Failure		401		{object}	errorResponse
+//	@Failure		409		{object}	errorResponse
+//	@Failure		422		{object}	errorResponse
+//	@Failure		429		{object}	errorResponse
 //	@Failure		500		{object}	errorResponse
 //	@Router			/api/v1/ [post]
 func (c *Controller) create(ctx *gin.Context) {
@@ -45,39 +370,645 @@ func (c *Controller) create(ctx *gin.Context) {
 		return
 	}
 
-	shortKey := c.service.ShortenURL(ctx, req.URL)
+	id, _ := identityFrom(ctx)
+	sreq := req.toService(id.Owner)
+	if !c.signRequest(&sreq) {
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: errSigningDisabled})
+		return
+	}
+	shortKey, err := c.service.ShortenURL(ctx, sreq)
+	if err != nil {
+		status, resp := shortenError(err)
+		if status == http.StatusInternalServerError {
+			_ = ctx.Error(err)
+		}
+		ctx.JSON(status, resp)
+		return
+	}
 
 	ctx.JSON(http.StatusOK, shortenResponse{
-		URL: shortKey,
+		URL: c.linkSegment(shortKey, sreq.Signed),
 	})
 }
 
+// batch godoc
+//
+//	@Summary		Shorten URLs in batch
+//	@Description	shorten many URLs at once, reporting success or failure per item
+//	@Tags			urls
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		batchRequest	true	"URLs to shorten"
+//	@Success		200		{object}	batchResponse
+//	@Failure		400		{object}	errorResponse
+//	@Failure		401		{object}	errorResponse
+//	@Failure		429		{object}	errorResponse
+//	@Router			/api/v1/batch [post]
+func (c *Controller) batch(ctx *gin.Context) {
+	var req batchRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: "invalid request"})
+		return
+	}
+	if len(req.Items) > c.maxBatchSize {
+		ctx.JSON(http.StatusBadRequest, errorResponse{
+			Error: "batch exceeds the maximum of " + strconv.Itoa(c.maxBatchSize) + " items",
+		})
+		return
+	}
+
+	id, _ := identityFrom(ctx)
+	reqs := make([]service.ShortenRequest, 0, len(req.Items))
+	// indexes maps the requests sent to the service back to the items.
+	indexes := make([]int, 0, len(req.Items))
+	resp := batchResponse{Results: make([]batchItemResponse, len(req.Items))}
+	for i, item := range req.Items {
+		sreq := item.toService(id.Owner)
+		if !c.signRequest(&sreq) {
+			resp.Results[i].Error = errSigningDisabled
+			continue
+		}
+		reqs = append(reqs, sreq)
+		indexes = append(indexes, i)
+	}
+
+	results := c.service.ShortenBatch(ctx, reqs)
+
+	for j, result := range results {
+		i := indexes[j]
+		if result.Err != nil {
+			status, e := shortenError(result.Err)
+			if status == http.StatusInternalServerError {
+				_ = ctx.Error(result.Err)
+			}
+			resp.Results[i].Error, resp.Results[i].Rule = e.Error, e.Rule
+			continue
+		}
+		resp.Results[i].URL = c.linkSegment(result.Key, reqs[j].Signed)
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func shortenError(err error) (int, errorResponse) {
+	var policyErr *service.PolicyError
+	switch {
+	case errors.As(err, &policyErr):
+		return http.StatusUnprocessableEntity, errorResponse{Error: "destination blocked", Rule: policyErr.Rule}
+	case errors.Is(err, service.ErrInvalidURL):
+		return http.StatusBadRequest, errorResponse{Error: "invalid url"}
+	case errors.Is(err, service.ErrInvalidAlias):
+		return http.StatusBadRequest, errorResponse{Error: "invalid alias"}
+	case errors.Is(err, service.ErrInvalidTTL):
+		return http.StatusBadRequest, errorResponse{Error: "invalid ttl"}
+	case errors.Is(err, service.ErrInvalidInterstitial):
+		return http.StatusBadRequest, errorResponse{Error: "invalid interstitial"}
+	case errors.Is(err, service.ErrInvalidPassword):
+		return http.StatusBadRequest, errorResponse{Error: "invalid password"}
+	case errors.Is(err, service.ErrInvalidMaxClicks):
+		return http.StatusBadRequest, errorResponse{Error: "invalid max clicks"}
+	case errors.Is(err, service.ErrInvalidForwarding):
+		return http.StatusBadRequest, errorResponse{Error: "invalid query forwarding"}
+	case errors.Is(err, service.ErrInvalidSchedule):
+		return http.StatusBadRequest, errorResponse{Error: "invalid activation window"}
+	case errors.Is(err, service.ErrInvalidRule), errors.Is(err, service.ErrInvalidVariant):
+		// The message names the offending entry and why it was rejected.
+		return http.StatusBadRequest, errorResponse{Error: err.Error()}
+	case errors.Is(err, service.ErrConflict):
+		return http.StatusConflict, errorResponse{Error: "short key already taken"}
+	default:
+		return http.StatusInternalServerError, errorResponse{Error: "failed to shorten url"}
+	}
+}
+
 // get godoc
 //
 //	@Summary		get original URL
-//	@Description	Redirect to the original URL by short key
+//	@Description	Redirect to the original URL by short key. A key ending in "+" or the preview
+//	@Description	query parameter shows where the link leads instead of redirecting.
 //	@Tags			urls
-//	@Produce		json
-//	@Param			key	path		string	true	"Short URL key"
-//	@Success		301	{string}	string	"Redirect to original URL"
-//	@Failure		404	{object}	errorResponse
+//	@Produce		json,html
+//	@Param			key		path		string	true	"Short URL key"
+//	@Param			preview				query		bool	false	"Show the preview page"
+//	@Param			X-Link-Password		header		string	false	"Password of a protected link"
+//	@Success		200					{string}	string	"Preview or interstitial page"
+//	@Success		301					{string}	string	"Redirect to original URL"
+//	@Failure		401					{string}	string	"Password form or wrong password"
+//	@Failure		404					{object}	errorResponse
+//	@Failure		410					{object}	errorResponse
+//	@Failure		429					{object}	errorResponse
+//	@Failure		451					{string}	string	"Link disabled"
 //	@Router			/api/v1/{key} [get]
 func (c *Controller) get(ctx *gin.Context) {
+	segment, preview := previewKey(ctx)
+	key, signed, ok := c.linkKey(ctx, segment)
+	if !ok {
+		return
+	}
+	visit := visitFrom(ctx, key, ctx.GetHeader(linkPasswordHeader))
+	visit.Signed = signed
+	c.visit(ctx, key, preview, visit, false)
+}
+
+// getPath godoc
+//
+//	@Summary		get original URL with path
+//	@Description	Redirect like GET /{key}, appending the rest of the path to the destination of links
+//	@Description	that forward paths. /{key}/qr is the QR code of the link.
+//	@Tags			urls
+//	@Produce		json,html
+//	@Param			key		path		string	true	"Short URL key"
+//	@Param			path	path		string	true	"Path forwarded to the destination"
+//	@Success		301		{string}	string	"Redirect to original URL"
+//	@Failure		404		{object}	errorResponse
+//	@Router			/api/v1/{key}/{path} [get]
+func (c *Controller) getPath(ctx *gin.Context) {
+	// Gin cannot route a static segment next to a catch-all one, so the QR
+	// code is dispatched from here.
+	if ctx.Param("path") == "/qr" {
+		c.qrCode(ctx)
+		return
+	}
+	c.get(ctx)
+}
+
+// unlock godoc
+//
+//	@Summary		Unlock password protected link
+//	@Description	submit the password form shown for a protected link
+//	@Tags			urls
+//	@Accept			x-www-form-urlencoded
+//	@Produce		html
+//	@Param			key			path		string	true	"Short URL key"
+//	@Param			password	formData	string	true	"Link password"
+//	@Success		303			{string}	string	"Redirect to original URL"
+//	@Failure		401			{string}	string	"Password form"
+//	@Failure		404			{object}	errorResponse
+//	@Failure		429			{string}	string	"Password form"
+//	@Router			/api/v1/{key} [post]
+func (c *Controller) unlock(ctx *gin.Context) {
+	segment, preview := previewKey(ctx)
+	key, signed, ok := c.linkKey(ctx, segment)
+	if !ok {
+		return
+	}
+	visit := visitFrom(ctx, key, ctx.PostForm("password"))
+	visit.Signed = signed
+	c.visit(ctx, key, preview, visit, true)
+}
+
+// visitFrom describes the client for redirect rules and A/B splits.
+func visitFrom(ctx *gin.Context, key string, password string) service.Visit {
+	visit := service.Visit{
+		Password:       password,
+		UserAgent:      ctx.GetHeader("User-Agent"),
+		AcceptLanguage: ctx.GetHeader("Accept-Language"),
+		IP:             ctx.ClientIP(),
+		Path:           ctx.Param("path"),
+		Referrer:       ctx.Request.Referer(),
+	}
+	if ctx.Request.URL.RawQuery != "" {
+		visit.Query = ctx.Request.URL.Query()
+		// The preview switch is meant for us, not the destination.
+		visit.Query.Del("preview")
+		if len(visit.Query) == 0 {
+			visit.Query = nil
+		}
+	}
+	if cookie, err := ctx.Cookie(variantCookie(key)); err == nil {
+		visit.Variant, _ = strconv.Atoi(cookie)
+	}
+	return visit
+}
+
+// variantCookie names the cookie that keeps a visitor on one variant of key.
+func variantCookie(key string) string {
+	return "variant_" + key
+}
+
+// previewKey strips the "+" that asks for a preview from the key.
+func previewKey(ctx *gin.Context) (string, bool) {
 	key := ctx.Param("key")
+	preview := ctx.Query("preview") == "1" || ctx.Query("preview") == "true"
+	if trimmed, ok := strings.CutSuffix(key, "+"); ok {
+		key, preview = trimmed, true
+	}
+	return key, preview
+}
 
-	originUrl, err := c.service.GetOriginalURL(ctx, key)
-	if err != nil {
+// visit resolves key and answers with a redirect or one of the HTML pages.
+// Password problems are shown on the password form for form submissions and
+// browsers, and as JSON for clients that sent the password header.
+func (c *Controller) visit(ctx *gin.Context, key string, preview bool, visit service.Visit, form bool) {
+	var link repository.Link
+	var err error
+	if preview {
+		link, err = c.service.PreviewLink(ctx, key, visit)
+	} else {
+		link, err = c.service.GetOriginalURL(ctx, key, visit)
+	}
+
+	viaHeader := visit.Password != "" && !form
+	var inactive *service.InactiveError
+	switch {
+	case err == nil:
+	case errors.As(err, &inactive) && inactive.FallbackURL != "":
+		ctx.Header("Cache-Control", "no-store")
+		ctx.Redirect(http.StatusFound, inactive.FallbackURL)
+		return
+	case errors.As(err, &inactive) && inactive.Ended:
+		ctx.JSON(http.StatusGone, errorResponse{Error: "link is no longer active"})
+		return
+	case errors.Is(err, service.ErrDisabled):
+		c.render(ctx, http.StatusUnavailableForLegalReasons, "disabled.html", pageData{Key: key})
+		return
+	case errors.Is(err, service.ErrExhausted):
+		ctx.JSON(http.StatusGone, errorResponse{Error: "link has no uses left"})
+		return
+	case errors.Is(err, service.ErrPasswordRequired):
+		c.render(ctx, http.StatusUnauthorized, "password.html", pageData{Key: key})
+		return
+	case errors.Is(err, service.ErrWrongPassword) && viaHeader:
+		ctx.JSON(http.StatusUnauthorized, errorResponse{Error: "wrong password"})
+		return
+	case errors.Is(err, service.ErrWrongPassword):
+		c.render(ctx, http.StatusUnauthorized, "password.html", pageData{Key: key, Error: "Wrong password."})
+		return
+	case errors.Is(err, service.ErrTooManyAttempts) && viaHeader:
+		ctx.JSON(http.StatusTooManyRequests, errorResponse{Error: "too many wrong passwords"})
+		return
+	case errors.Is(err, service.ErrTooManyAttempts):
+		c.render(ctx, http.StatusTooManyRequests, "password.html", pageData{Key: key, Error: "Too many wrong passwords. Try again later."})
+		return
+	default:
+		if !errors.Is(err, service.ErrNotFound) {
+			_ = ctx.Error(err)
+		} else if d, ok := service.DomainFrom(ctx); ok && d.FallbackURL != "" {
+			ctx.Set(missKey, true)
+			ctx.Header("Cache-Control", "no-store")
+			ctx.Redirect(http.StatusFound, d.FallbackURL)
+			return
+		}
 		ctx.JSON(http.StatusNotFound, errorResponse{Error: "url not found"})
 		return
 	}
 
-	ctx.Redirect(http.StatusMovedPermanently, originUrl)
+	domain, inDomain := service.DomainFrom(ctx)
+	if !preview && c.clicks != nil {
+		clickKey := key
+		if inDomain {
+			clickKey = domain.Name + "/" + key
+		}
+		c.clicks.Publish(clickstream.Click{
+			Key:       clickKey,
+			At:        time.Now().UTC(),
+			IP:        visit.IP,
+			Referrer:  visit.Referrer,
+			UserAgent: visit.UserAgent,
+			Variant:   link.Variant,
+		})
+	}
+
+	if link.Variant > 0 {
+		ctx.SetSameSite(http.SameSiteLaxMode)
+		ctx.SetCookie(variantCookie(key), strconv.Itoa(link.Variant), int(variantCookieAge/time.Second), "/", "", false, true)
+	}
+
+	switch {
+	case preview:
+		page := linkPage(link)
+		page.Countdown = 0
+		c.render(ctx, http.StatusOK, "preview.html", page)
+	case link.Interstitial > 0:
+		c.render(ctx, http.StatusOK, "preview.html", linkPage(link))
+	case form:
+		ctx.Redirect(http.StatusSeeOther, link.URL)
+	case link.PasswordHash != "" || link.MaxClicks > 0 || !link.NotAfter.IsZero() || len(link.Rules) > 0 || len(link.Variants) > 0:
+		// A permanent redirect would be cached and skip the password or the
+		// click limit, outlive the activation window or pin one rule's
+		// target or variant.
+		ctx.Header("Cache-Control", "no-store")
+		ctx.Redirect(http.StatusFound, link.URL)
+	case inDomain && domain.RedirectCode != 0:
+		ctx.Redirect(domain.RedirectCode, link.URL)
+	default:
+		ctx.Redirect(http.StatusMovedPermanently, link.URL)
+	}
+}
+
+// update godoc
+//
+//	@Summary		Update link
+//	@Description	change the destination of a link owned by the caller
+//	@Tags			links
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			key		path	string			true	"Short URL key"
+//	@Param			request	body	updateRequest	true	"New destination"
+//	@Success		204
+//	@Failure		400	{object}	errorResponse
+//	@Failure		401	{object}	errorResponse
+//	@Failure		403	{object}	errorResponse
+//	@Failure		404	{object}	errorResponse
+//	@Failure		422	{object}	errorResponse
+//	@Router			/api/v1/{key} [patch]
+func (c *Controller) update(ctx *gin.Context) {
+	var req updateRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: "invalid request"})
+		return
+	}
+
+	id, _ := identityFrom(ctx)
+	if err := c.service.UpdateURL(ctx, id, ctx.Param("key"), req.URL); err != nil {
+		c.manageError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// delete godoc
+//
+//	@Summary		Delete link
+//	@Description	delete a link owned by the caller
+//	@Tags			links
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			key	path	string	true	"Short URL key"
+//	@Success		204
+//	@Failure		401	{object}	errorResponse
+//	@Failure		403	{object}	errorResponse
+//	@Failure		404	{object}	errorResponse
+//	@Router			/api/v1/{key} [delete]
+func (c *Controller) delete(ctx *gin.Context) {
+	id, _ := identityFrom(ctx)
+	if err := c.service.DeleteURL(ctx, id, ctx.Param("key")); err != nil {
+		c.manageError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// list godoc
+//
+//	@Summary		List links
+//	@Description	list links created by the caller, newest or most clicked first
+//	@Tags			links
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			owner	query		string	false	"Owner to list, only \"me\" unless admin"	default(me)
+//	@Param			sort	query		string	false	"Sort field"								Enums(created, clicks)
+//	@Param			order	query		string	false	"Sort order"								Enums(asc, desc)
+//	@Param			cursor	query		string	false	"Cursor from the previous page"
+//	@Param			limit	query		int		false	"Page size"	default(20)	maximum(100)
+//	@Success		200		{object}	listResponse
+//	@Failure		400		{object}	errorResponse
+//	@Failure		401		{object}	errorResponse
+//	@Failure		403		{object}	errorResponse
+//	@Router			/api/v1/links [get]
+func (c *Controller) list(ctx *gin.Context) {
+	id, _ := identityFrom(ctx)
+
+	owner := ctx.DefaultQuery("owner", "me")
+	switch {
+	case owner == "me":
+		owner = id.Owner
+	case !id.Admin:
+		ctx.JSON(http.StatusForbidden, errorResponse{Error: "cannot list links of another owner"})
+		return
+	}
+
+	sort := repository.SortField(ctx.DefaultQuery("sort", string(repository.SortByCreated)))
+	if sort != repository.SortByCreated && sort != repository.SortByClicks {
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: "invalid sort"})
+		return
+	}
+
+	order := ctx.DefaultQuery("order", "desc")
+	if order != "asc" && order != "desc" {
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: "invalid order"})
+		return
+	}
+
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: "invalid limit"})
+		return
+	}
+
+	page, err := c.service.ListLinks(ctx, service.ListRequest{
+		Owner:     owner,
+		Sort:      sort,
+		Ascending: order == "asc",
+		Cursor:    ctx.Query("cursor"),
+		Limit:     limit,
+	})
+	if err != nil {
+		c.manageError(ctx, err)
+		return
+	}
+
+	resp := listResponse{Links: make([]linkResponse, 0, len(page.Links)), NextCursor: page.NextCursor}
+	for _, link := range page.Links {
+		resp.Links = append(resp.Links, linkResponse{
+			Key:       link.Key,
+			URL:       link.URL,
+			CreatedAt: link.CreatedAt,
+			Clicks:    link.Clicks,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// stats godoc
+//
+//	@Summary		Link statistics
+//	@Description	show clicks, expiry and remaining uses of a link owned by the caller
+//	@Tags			links
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			key	path		string	true	"Short URL key"
+//	@Success		200	{object}	statsResponse
+//	@Failure		401	{object}	errorResponse
+//	@Failure		403	{object}	errorResponse
+//	@Failure		404	{object}	errorResponse
+//	@Router			/api/v1/links/{key}/stats [get]
+func (c *Controller) stats(ctx *gin.Context) {
+	id, _ := identityFrom(ctx)
+	link, err := c.service.InspectLink(ctx, id, ctx.Param("key"))
+	if err != nil {
+		c.manageError(ctx, err)
+		return
+	}
+
+	resp := statsResponse{
+		Key:         link.Key,
+		URL:         link.URL,
+		CreatedAt:   link.CreatedAt,
+		Clicks:      link.Clicks,
+		MaxClicks:   link.MaxClicks,
+		FallbackURL: link.FallbackURL,
+	}
+	if !link.ExpiresAt.IsZero() {
+		resp.ExpiresAt = &link.ExpiresAt
+	}
+	if link.MaxClicks > 0 {
+		resp.RemainingClicks = &link.RemainingClicks
+	}
+	if !link.NotBefore.IsZero() {
+		resp.NotBefore = &link.NotBefore
+	}
+	if !link.NotAfter.IsZero() {
+		resp.NotAfter = &link.NotAfter
+	}
+	for _, variant := range link.Variants {
+		resp.Variants = append(resp.Variants, variantStats{URL: variant.URL, Weight: variant.Weight, Clicks: variant.Clicks})
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+type timeseriesPoint struct {
+	Start  time.Time `json:"start"`
+	Clicks int64     `json:"clicks" example:"12"`
+}
+
+type timeseriesResponse struct {
+	Key         string            `json:"key" example:"abc123"`
+	Granularity string            `json:"granularity" example:"hour"`
+	From        time.Time         `json:"from"`
+	To          time.Time         `json:"to"`
+	Points      []timeseriesPoint `json:"points"`
+}
+
+// timeseries godoc
+//
+//	@Summary		Link clicks over time
+//	@Description	show the clicks of a link owned by the caller per hour or day; empty buckets are included
+//	@Tags			links
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			key			path		string	true	"Short URL key"
+//	@Param			granularity	query		string	false	"Bucket width"								Enums(hour, day)	default(hour)
+//	@Param			from		query		string	false	"RFC 3339 start, default a day or 30 days ago"
+//	@Param			to			query		string	false	"RFC 3339 end, default now"
+//	@Success		200			{object}	timeseriesResponse
+//	@Failure		400			{object}	errorResponse
+//	@Failure		401			{object}	errorResponse
+//	@Failure		403			{object}	errorResponse
+//	@Failure		404			{object}	errorResponse
+//	@Router			/api/v1/links/{key}/stats/timeseries [get]
+func (c *Controller) timeseries(ctx *gin.Context) {
+	req := service.TimeseriesRequest{Granularity: repository.Granularity(ctx.Query("granularity"))}
+	if !queryTime(ctx, "from", &req.From) || !queryTime(ctx, "to", &req.To) {
+		return
+	}
+
+	id, _ := identityFrom(ctx)
+	series, err := c.service.ClickTimeseries(ctx, id, ctx.Param("key"), req)
+	if errors.Is(err, service.ErrInvalidTimeseries) {
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.manageError(ctx, err)
+		return
+	}
+
+	resp := timeseriesResponse{
+		Key:         ctx.Param("key"),
+		Granularity: string(series.Granularity),
+		From:        series.From,
+		To:          series.To,
+		Points:      make([]timeseriesPoint, len(series.Points)),
+	}
+	for i, point := range series.Points {
+		resp.Points[i] = timeseriesPoint{Start: point.Start, Clicks: point.Clicks}
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func (c *Controller) manageError(ctx *gin.Context, err error) {
+	var policyErr *service.PolicyError
+	switch {
+	case errors.As(err, &policyErr):
+		ctx.JSON(http.StatusUnprocessableEntity, errorResponse{Error: "destination blocked", Rule: policyErr.Rule})
+	case errors.Is(err, service.ErrNotFound):
+		ctx.JSON(http.StatusNotFound, errorResponse{Error: "url not found"})
+	case errors.Is(err, service.ErrForbidden):
+		ctx.JSON(http.StatusForbidden, errorResponse{Error: "access denied"})
+	case errors.Is(err, service.ErrInvalidCursor):
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: "invalid cursor"})
+	case errors.Is(err, service.ErrInvalidURL):
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: "invalid url"})
+	default:
+		_ = ctx.Error(err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse{Error: "internal error"})
+	}
+}
+
+// limited prepends the rate limit middleware to handlers when l is set.
+func limited(l ratelimit.Limiter, handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+	if l == nil {
+		return handlers
+	}
+	return append([]gin.HandlerFunc{rateLimit(l)}, handlers...)
+}
+
+// redirect puts the scan guard and redirect limiter in front of h.
+func (c *Controller) redirect(h gin.HandlerFunc) []gin.HandlerFunc {
+	var handlers []gin.HandlerFunc
+	if c.scanGuard != nil {
+		handlers = append(handlers, scanGuard(c.scanGuard))
+	}
+	return append(handlers, limited(c.redirectLimiter, h)...)
 }
 
 func (c *Controller) RegisterRoutes(router *gin.Engine) {
-	api := router.Group("/api/v1")
+	// Handlers pass the gin context to the service, which must see the
+	// request context for cancellation and request IDs.
+	router.ContextWithFallback = true
+
+	middleware := []gin.HandlerFunc{c.authenticate}
+	if c.domains {
+		middleware = append([]gin.HandlerFunc{c.resolveDomain}, middleware...)
+	}
+	api := router.Group("/api/v1", middleware...)
 	{
-		api.POST("/", c.create)
-		api.GET("/:key", c.get)
+		api.POST("/", limited(c.createLimiter, c.create)...)
+		api.POST("/batch", limited(c.createLimiter, c.batch)...)
+		api.GET("/links", c.requireIdentity, c.list)
+		api.GET("/links/:key/stats", c.requireIdentity, c.stats)
+		api.GET("/links/:key/stats/timeseries", c.requireIdentity, c.timeseries)
+		api.GET("/:key", c.redirect(c.get)...)
+		api.POST("/:key", c.redirect(c.unlock)...)
+		api.GET("/:key/*path", c.redirect(c.getPath)...)
+		api.PATCH("/:key", c.requireIdentity, c.update)
+		api.DELETE("/:key", c.requireIdentity, c.delete)
+
+		admin := api.Group("/admin", c.requireAdmin)
+		admin.GET("/export", c.exportLinks)
+		admin.POST("/import", c.importLinks)
+		admin.GET("/disabled", c.listDisabled)
+		admin.POST("/disabled/:key/enable", c.enableLink)
+		admin.GET("/stats/top-links", c.topLinks)
+		admin.GET("/stats/top-referrers", c.topReferrers)
+		admin.GET("/stats/created", c.linksCreated)
+		admin.GET("/metrics", c.metrics)
+
+		if c.webhooks != nil {
+			api.POST("/webhooks", c.requireIdentity, c.createWebhook)
+			api.GET("/webhooks", c.requireIdentity, c.listWebhooks)
+			api.DELETE("/webhooks/:id", c.requireIdentity, c.deleteWebhook)
+			admin.GET("/webhooks/dead", c.listDeadWebhooks)
+		}
+		if c.domains {
+			admin.PUT("/domains/:name", c.saveDomain)
+			admin.GET("/domains", c.listDomains)
+			admin.DELETE("/domains/:name", c.deleteDomain)
+		}
 	}
 }
@@ -5,27 +5,175 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+	"url-shortener/clickstream"
+	"url-shortener/logging"
+	"url-shortener/ratelimit"
+	"url-shortener/repository"
+	"url-shortener/service"
+	"url-shortener/signing"
+	"url-shortener/webhook"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type MockShortenerService struct {
 	mock.Mock
 }
 
-func (m *MockShortenerService) ShortenURL(ctx context.Context, originalURL string) string {
-	args := m.Called(ctx, originalURL)
-	return args.String(0)
+func (m *MockShortenerService) ShortenURL(ctx context.Context, req service.ShortenRequest) (string, error) {
+	args := m.Called(ctx, req)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockShortenerService) ShortenBatch(ctx context.Context, reqs []service.ShortenRequest) []service.BatchResult {
+	args := m.Called(ctx, reqs)
+	return args.Get(0).([]service.BatchResult)
+}
+
+func (m *MockShortenerService) GetOriginalURL(ctx context.Context, shortKey string, visit service.Visit) (repository.Link, error) {
+	args := m.Called(ctx, shortKey, visit)
+	return args.Get(0).(repository.Link), args.Error(1)
+}
+
+func (m *MockShortenerService) PreviewLink(ctx context.Context, shortKey string, visit service.Visit) (repository.Link, error) {
+	args := m.Called(ctx, shortKey, visit)
+	return args.Get(0).(repository.Link), args.Error(1)
+}
+
+func (m *MockShortenerService) UpdateURL(ctx context.Context, id service.Identity, shortKey string, url string) error {
+	args := m.Called(ctx, id, shortKey, url)
+	return args.Error(0)
+}
+
+func (m *MockShortenerService) DeleteURL(ctx context.Context, id service.Identity, shortKey string) error {
+	args := m.Called(ctx, id, shortKey)
+	return args.Error(0)
+}
+
+func (m *MockShortenerService) ListLinks(ctx context.Context, req service.ListRequest) (service.LinkPage, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(service.LinkPage), args.Error(1)
+}
+
+func (m *MockShortenerService) Authenticate(ctx context.Context, apiKey string) (service.Identity, error) {
+	args := m.Called(ctx, apiKey)
+	return args.Get(0).(service.Identity), args.Error(1)
+}
+
+type stubLimiter struct {
+	result ratelimit.Result
+	keys   []string
+}
+
+func (l *stubLimiter) Allow(ctx context.Context, key string) (ratelimit.Result, error) {
+	l.keys = append(l.keys, key)
+	return l.result, nil
+}
+
+func (m *MockShortenerService) InspectLink(ctx context.Context, id service.Identity, shortKey string) (repository.Link, error) {
+	args := m.Called(ctx, id, shortKey)
+	return args.Get(0).(repository.Link), args.Error(1)
+}
+
+func (m *MockShortenerService) CreateAPIKey(ctx context.Context, owner string, admin bool) (string, repository.APIKey, error) {
+	args := m.Called(ctx, owner, admin)
+	return args.String(0), args.Get(1).(repository.APIKey), args.Error(2)
+}
+
+func (m *MockShortenerService) RevokeAPIKey(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockShortenerService) ListAPIKeys(ctx context.Context) ([]repository.APIKey, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]repository.APIKey), args.Error(1)
+}
+
+func (m *MockShortenerService) PurgeExpired(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockShortenerService) ExportLinks(ctx context.Context, fn func(repository.Link) error) error {
+	args := m.Called(ctx, fn)
+	return args.Error(0)
+}
+
+func (m *MockShortenerService) ImportLinks(ctx context.Context, links []repository.Link) []error {
+	args := m.Called(ctx, links)
+	return args.Get(0).([]error)
+}
+
+func (m *MockShortenerService) RecheckLinks(ctx context.Context) (service.RecheckSummary, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(service.RecheckSummary), args.Error(1)
+}
+
+func (m *MockShortenerService) ClickTimeseries(ctx context.Context, id service.Identity, shortKey string, req service.TimeseriesRequest) (service.Timeseries, error) {
+	args := m.Called(ctx, id, shortKey, req)
+	return args.Get(0).(service.Timeseries), args.Error(1)
+}
+
+func (m *MockShortenerService) TopLinks(ctx context.Context, req service.DashboardRequest) (service.Ranking, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(service.Ranking), args.Error(1)
+}
+
+func (m *MockShortenerService) TopReferrers(ctx context.Context, req service.DashboardRequest) (service.Ranking, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(service.Ranking), args.Error(1)
+}
+
+func (m *MockShortenerService) LinksCreated(ctx context.Context, req service.DashboardRequest) (service.DailyCounts, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(service.DailyCounts), args.Error(1)
+}
+
+func (m *MockShortenerService) SaveDomain(ctx context.Context, d repository.Domain) (repository.Domain, error) {
+	args := m.Called(ctx, d)
+	return args.Get(0).(repository.Domain), args.Error(1)
+}
+
+func (m *MockShortenerService) GetDomain(ctx context.Context, name string) (repository.Domain, error) {
+	args := m.Called(ctx, name)
+	return args.Get(0).(repository.Domain), args.Error(1)
+}
+
+func (m *MockShortenerService) ListDomains(ctx context.Context) ([]repository.Domain, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]repository.Domain), args.Error(1)
+}
+
+func (m *MockShortenerService) DeleteDomain(ctx context.Context, name string) error {
+	args := m.Called(ctx, name)
+	return args.Error(0)
+}
+
+func (m *MockShortenerService) ListDisabledLinks(ctx context.Context) ([]repository.Link, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]repository.Link), args.Error(1)
 }
 
-func (m *MockShortenerService) GetOriginalURL(ctx context.Context, shortKey string) (string, error) {
+func (m *MockShortenerService) EnableLink(ctx context.Context, shortKey string) error {
 	args := m.Called(ctx, shortKey)
-	return args.String(0), args.Error(1)
+	return args.Error(0)
 }
 
 func setupRouter(c *Controller) *gin.Engine {
@@ -45,7 +193,7 @@ func TestController_create_Success(t *testing.T) {
 	}
 	bodyBytes, _ := json.Marshal(requestBody)
 
-	mockService.On("ShortenURL", mock.Anything, "https://example.com").Return("abc123")
+	mockService.On("ShortenURL", mock.Anything, service.ShortenRequest{URL: "https://example.com"}).Return("abc123", nil)
 
 	req, _ := http.NewRequest(http.MethodPost, "/api/v1/", bytes.NewBuffer(bodyBytes))
 	req.Header.Set("Content-Type", "application/json")
@@ -62,6 +210,126 @@ func TestController_create_Success(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestController_create_BlockedDestination(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := setupRouter(controller)
+
+	mockService.On("ShortenURL", mock.Anything, service.ShortenRequest{URL: "https://evil.example"}).
+		Return("", &service.PolicyError{Rule: "safe-browsing:malware"})
+
+	bodyBytes, _ := json.Marshal(shortenRequest{URL: "https://evil.example"})
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/", bytes.NewBuffer(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var response errorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "destination blocked", response.Error)
+	assert.Equal(t, "safe-browsing:malware", response.Rule)
+}
+
+func TestController_create_Rules(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := setupRouter(controller)
+
+	rules := []repository.Rule{
+		{Platform: "ios", URL: "https://apps.apple.com/app/id1"},
+		{Country: "DE", From: "09:00", To: "17:00", Timezone: "Europe/Berlin", URL: "https://example.de"},
+	}
+	mockService.On("ShortenURL", mock.Anything, service.ShortenRequest{URL: "https://example.com", Rules: rules}).Return("app", nil)
+	mockService.On("ShortenURL", mock.Anything, service.ShortenRequest{URL: "https://example.org", Rules: []repository.Rule{{URL: "https://example.de"}}}).
+		Return("", fmt.Errorf("%w 1: no conditions", service.ErrInvalidRule))
+
+	body := `{"url": "https://example.com", "rules": [
+		{"platform": "ios", "url": "https://apps.apple.com/app/id1"},
+		{"country": "DE", "from": "09:00", "to": "17:00", "timezone": "Europe/Berlin", "url": "https://example.de"}
+	]}`
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	body = `{"url": "https://example.org", "rules": [{"url": "https://example.de"}]}`
+	req, _ = http.NewRequest(http.MethodPost, "/api/v1/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error": "invalid rule 1: no conditions"}`, w.Body.String())
+
+	mockService.AssertExpectations(t)
+}
+
+func TestController_get_PassesVisitor(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := setupRouter(controller)
+
+	visit := service.Visit{UserAgent: "Mozilla/5.0 (iPhone)", AcceptLanguage: "de-DE", IP: "203.0.113.7"}
+	mockService.On("GetOriginalURL", mock.Anything, "app", visit).Return(repository.Link{
+		URL:   "https://apps.apple.com/app/id1",
+		Rules: []repository.Rule{{Platform: "ios", URL: "https://apps.apple.com/app/id1"}},
+	}, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/app", nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone)")
+	req.Header.Set("Accept-Language", "de-DE")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://apps.apple.com/app/id1", w.Header().Get("Location"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+}
+
+type recordedClicks []clickstream.Click
+
+func (r *recordedClicks) Publish(click clickstream.Click) {
+	*r = append(*r, click)
+}
+
+func TestController_get_PublishesClick(t *testing.T) {
+	mockService := new(MockShortenerService)
+	var clicks recordedClicks
+	controller := NewController(mockService, WithClickPublisher(&clicks))
+	router := setupRouter(controller)
+
+	visit := service.Visit{UserAgent: "curl/8", IP: "203.0.113.7", Referrer: "https://news.example/post"}
+	mockService.On("GetOriginalURL", mock.Anything, "abc", visit).Return(repository.Link{
+		Key:     "abc",
+		URL:     "https://example.com/b",
+		Variant: 2,
+	}, nil)
+	mockService.On("PreviewLink", mock.Anything, "abc", mock.Anything).Return(repository.Link{Key: "abc", URL: "https://example.com"}, nil)
+	mockService.On("GetOriginalURL", mock.Anything, "gone", mock.Anything).Return(repository.Link{}, service.ErrNotFound)
+
+	for _, path := range []string{"/api/v1/abc", "/api/v1/abc+", "/api/v1/gone"} {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "203.0.113.7:51234"
+		req.Header.Set("User-Agent", "curl/8")
+		req.Header.Set("Referer", "https://news.example/post")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Previews and failed lookups are not clicks.
+	if assert.Len(t, clicks, 1) {
+		click := clicks[0]
+		assert.Equal(t, "abc", click.Key)
+		assert.Equal(t, "203.0.113.7", click.IP)
+		assert.Equal(t, "https://news.example/post", click.Referrer)
+		assert.Equal(t, "curl/8", click.UserAgent)
+		assert.Equal(t, 2, click.Variant)
+		assert.WithinDuration(t, time.Now(), click.At, time.Minute)
+	}
+}
+
 func TestController_create_InvalidRequest(t *testing.T) {
 	mockService := new(MockShortenerService)
 	controller := NewController(mockService)
@@ -125,7 +393,7 @@ func TestController_get_Success(t *testing.T) {
 	controller := NewController(mockService)
 	router := setupRouter(controller)
 
-	mockService.On("GetOriginalURL", mock.Anything, "abc123").Return("https://example.com", nil)
+	mockService.On("GetOriginalURL", mock.Anything, "abc123", service.Visit{}).Return(repository.Link{Key: "abc123", URL: "https://example.com"}, nil)
 
 	req, _ := http.NewRequest(http.MethodGet, "/api/v1/abc123", nil)
 	w := httptest.NewRecorder()
@@ -137,70 +405,1764 @@ func TestController_get_Success(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
-func TestController_get_NotFound(t *testing.T) {
+func TestController_get_Disabled(t *testing.T) {
 	mockService := new(MockShortenerService)
 	controller := NewController(mockService)
 	router := setupRouter(controller)
 
-	mockService.On("GetOriginalURL", mock.Anything, "notfound").Return("", errors.New("key not found"))
+	mockService.On("GetOriginalURL", mock.Anything, "flagged", service.Visit{}).Return(repository.Link{}, service.ErrDisabled)
 
-	req, _ := http.NewRequest(http.MethodGet, "/api/v1/notfound", nil)
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/flagged", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnavailableForLegalReasons, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), "flagged")
+}
+
+func TestController_get_Preview(t *testing.T) {
+	for _, path := range []string{"/api/v1/abc123+", "/api/v1/abc123?preview=1"} {
+		t.Run(path, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			controller := NewController(mockService)
+			router := setupRouter(controller)
+
+			mockService.On("PreviewLink", mock.Anything, "abc123", service.Visit{}).Return(repository.Link{
+				Key:          "abc123",
+				URL:          "https://example.com/?a=1&b=<2>",
+				CreatedAt:    time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+				Clicks:       42,
+				Interstitial: 5 * time.Second,
+			}, nil)
+
+			req, _ := http.NewRequest(http.MethodGet, path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			body := w.Body.String()
+			assert.Contains(t, body, "https://example.com/?a=1&amp;b=%3c2%3e")
+			assert.Contains(t, body, "1 May 2024")
+			assert.Contains(t, body, "42")
+			assert.NotContains(t, body, "http-equiv=\"refresh\"", "a requested preview must not redirect on its own")
+			mockService.AssertNotCalled(t, "GetOriginalURL", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestController_get_Interstitial(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := setupRouter(controller)
+
+	mockService.On("GetOriginalURL", mock.Anything, "abc123", service.Visit{}).Return(repository.Link{
+		Key:          "abc123",
+		URL:          "https://example.com",
+		Interstitial: 5 * time.Second,
+	}, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/abc123", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+	assert.Contains(t, w.Body.String(), `content="5;url=https://example.com"`)
+}
+
+func TestController_get_PasswordProtected(t *testing.T) {
+	tests := []struct {
+		name         string
+		password     string
+		serviceError error
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "form", serviceError: service.ErrPasswordRequired, expectedCode: http.StatusUnauthorized, expectedBody: `name="password"`},
+		{name: "header", password: "secret", expectedCode: http.StatusFound},
+		{name: "wrong header", password: "guess", serviceError: service.ErrWrongPassword, expectedCode: http.StatusUnauthorized, expectedBody: `{"error":"wrong password"}`},
+		{name: "locked", password: "guess", serviceError: service.ErrTooManyAttempts, expectedCode: http.StatusTooManyRequests, expectedBody: `{"error":"too many wrong passwords"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			controller := NewController(mockService)
+			router := setupRouter(controller)
+
+			link := repository.Link{Key: "abc123", URL: "https://example.com", PasswordHash: "hash"}
+			if tt.serviceError != nil {
+				link = repository.Link{}
+			}
+			mockService.On("GetOriginalURL", mock.Anything, "abc123", service.Visit{Password: tt.password}).Return(link, tt.serviceError)
+
+			req, _ := http.NewRequest(http.MethodGet, "/api/v1/abc123", nil)
+			if tt.password != "" {
+				req.Header.Set("X-Link-Password", tt.password)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+			if tt.serviceError == nil {
+				assert.Equal(t, "https://example.com", w.Header().Get("Location"))
+				assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+func TestController_unlock(t *testing.T) {
+	tests := []struct {
+		name         string
+		serviceError error
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "success", expectedCode: http.StatusSeeOther},
+		{name: "wrong password", serviceError: service.ErrWrongPassword, expectedCode: http.StatusUnauthorized, expectedBody: "Wrong password."},
+		{name: "locked", serviceError: service.ErrTooManyAttempts, expectedCode: http.StatusTooManyRequests, expectedBody: "Too many wrong passwords."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			controller := NewController(mockService)
+			router := setupRouter(controller)
+
+			link := repository.Link{Key: "abc123", URL: "https://example.com", PasswordHash: "hash"}
+			if tt.serviceError != nil {
+				link = repository.Link{}
+			}
+			mockService.On("GetOriginalURL", mock.Anything, "abc123", service.Visit{Password: "secret"}).Return(link, tt.serviceError)
+
+			req, _ := http.NewRequest(http.MethodPost, "/api/v1/abc123", bytes.NewBufferString("password=secret"))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+			if tt.serviceError == nil {
+				assert.Equal(t, "https://example.com", w.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestController_qrCode(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService, WithBaseURL("https://sho.rt/"))
+	router := setupRouter(controller)
+
+	mockService.On("PreviewLink", mock.Anything, "abc123", service.Visit{}).Return(repository.Link{Key: "abc123"}, nil)
+	mockService.On("PreviewLink", mock.Anything, "locked", service.Visit{}).Return(repository.Link{}, service.ErrPasswordRequired)
+	mockService.On("PreviewLink", mock.Anything, "missing", service.Visit{}).Return(repository.Link{}, service.ErrNotFound)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/abc123/qr?format=svg&fg=%23336699&size=512", nil)
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `fill="#336699"`)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/abc123/qr?format=svg&fg=%23336699&size=512", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/abc123/qr?size=512", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.NotEqual(t, etag, w.Header().Get("ETag"), "different parameters need a different ETag")
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/locked/qr", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "protected links still get a code")
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/missing/qr", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusNotFound, w.Code)
+}
 
-	var response errorResponse
-	err := json.Unmarshal(w.Body.Bytes(), &response)
+func TestController_qrCode_InvalidParams(t *testing.T) {
+	for _, query := range []string{"format=gif", "size=10", "size=big", "level=Z", "margin=99", "bg=nope"} {
+		t.Run(query, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			controller := NewController(mockService)
+			router := setupRouter(controller)
+
+			req, _ := http.NewRequest(http.MethodGet, "/api/v1/abc123/qr?"+query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			mockService.AssertNotCalled(t, "PreviewLink", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestLoadTemplates_Override(t *testing.T) {
+	dir := t.TempDir()
+	override := []byte(`<p>custom {{.Key}}</p>`)
+	if err := os.WriteFile(filepath.Join(dir, "preview.html"), override, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := LoadTemplates(dir)
 	assert.NoError(t, err)
-	assert.Equal(t, "url not found", response.Error)
 
-	mockService.AssertExpectations(t)
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService, WithTemplates(templates))
+	router := setupRouter(controller)
+
+	mockService.On("PreviewLink", mock.Anything, "abc123", service.Visit{}).Return(repository.Link{Key: "abc123", URL: "https://example.com"}, nil)
+	mockService.On("PreviewLink", mock.Anything, "flagged", service.Visit{}).Return(repository.Link{}, service.ErrDisabled)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/abc123+", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "<p>custom abc123</p>", w.Body.String())
+
+	// Pages without an override keep the built-in template.
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/flagged+", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnavailableForLegalReasons, w.Code)
+	assert.Contains(t, w.Body.String(), "This link has been disabled")
 }
 
-func TestController_get_EmptyKey(t *testing.T) {
+func TestController_get_Inactive(t *testing.T) {
 	mockService := new(MockShortenerService)
 	controller := NewController(mockService)
 	router := setupRouter(controller)
 
-	mockService.On("GetOriginalURL", mock.Anything, "").Return("", errors.New("empty key"))
+	mockService.On("GetOriginalURL", mock.Anything, "soon", service.Visit{}).Return(repository.Link{}, &service.InactiveError{})
+	mockService.On("GetOriginalURL", mock.Anything, "over", service.Visit{}).Return(repository.Link{}, &service.InactiveError{Ended: true})
+	mockService.On("GetOriginalURL", mock.Anything, "sale", service.Visit{}).Return(repository.Link{}, &service.InactiveError{Ended: true, FallbackURL: "https://example.com"})
 
-	req, _ := http.NewRequest(http.MethodGet, "/api/v1/", nil)
+	tests := []struct {
+		key      string
+		code     int
+		location string
+	}{
+		{"soon", http.StatusNotFound, ""},
+		{"over", http.StatusGone, ""},
+		{"sale", http.StatusFound, "https://example.com"},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/"+tt.key, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.code, w.Code, tt.key)
+		assert.Equal(t, tt.location, w.Header().Get("Location"), tt.key)
+	}
+}
+
+func TestController_get_ScheduledIsNotPermanent(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := setupRouter(controller)
+
+	mockService.On("GetOriginalURL", mock.Anything, "sale", service.Visit{}).Return(repository.Link{
+		URL:      "https://example.com/sale",
+		NotAfter: time.Now().Add(time.Hour),
+	}, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/sale", nil)
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
 }
 
-func TestController_RegisterRoutes(t *testing.T) {
+func TestController_get_ClickLimitedIsNotPermanent(t *testing.T) {
 	mockService := new(MockShortenerService)
 	controller := NewController(mockService)
-	router := gin.New()
+	router := setupRouter(controller)
 
-	controller.RegisterRoutes(router)
+	mockService.On("GetOriginalURL", mock.Anything, "once", service.Visit{}).Return(repository.Link{
+		URL:       "https://example.com/once",
+		MaxClicks: 1,
+	}, nil)
 
-	routes := router.Routes()
-	assert.Len(t, routes, 2)
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/once", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
 
-	var hasPostRoute, hasGetRoute bool
-	for _, route := range routes {
-		if route.Method == "POST" && route.Path == "/api/v1/" {
-			hasPostRoute = true
-		}
-		if route.Method == "GET" && route.Path == "/api/v1/:key" {
-			hasGetRoute = true
-		}
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+}
+
+func TestController_get_Variant(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := setupRouter(controller)
+
+	variants := []repository.Variant{{URL: "https://example.com/a", Weight: 1}, {URL: "https://example.com/b", Weight: 1}}
+	mockService.On("GetOriginalURL", mock.Anything, "exp", service.Visit{}).
+		Return(repository.Link{URL: "https://example.com/b", Variants: variants, Variant: 2}, nil)
+	mockService.On("GetOriginalURL", mock.Anything, "exp", service.Visit{Variant: 2}).
+		Return(repository.Link{URL: "https://example.com/b", Variants: variants, Variant: 2}, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/exp", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/b", w.Header().Get("Location"))
+	cookies := w.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "variant_exp", cookies[0].Name)
+		assert.Equal(t, "2", cookies[0].Value)
+		assert.True(t, cookies[0].HttpOnly)
 	}
 
-	assert.True(t, hasPostRoute, "POST route should be registered")
-	assert.True(t, hasGetRoute, "GET route should be registered")
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/exp", nil)
+	req.AddCookie(&http.Cookie{Name: "variant_exp", Value: "2"})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	mockService.AssertExpectations(t)
 }
 
-func TestNewController(t *testing.T) {
+func TestController_get_Forwarding(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := setupRouter(controller)
+
+	mockService.On("GetOriginalURL", mock.Anything, "docs", service.Visit{
+		Path:  "/guide/install",
+		Query: url.Values{"lang": {"de"}},
+	}).Return(repository.Link{URL: "https://example.com/docs/guide/install?lang=de"}, nil)
+	mockService.On("PreviewLink", mock.Anything, "docs", service.Visit{}).
+		Return(repository.Link{Key: "docs", URL: "https://example.com/docs"}, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/docs/guide/install?lang=de", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "https://example.com/docs/guide/install?lang=de", w.Header().Get("Location"))
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/docs?preview=1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestController_get_Exhausted(t *testing.T) {
 	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := setupRouter(controller)
+
+	mockService.On("GetOriginalURL", mock.Anything, "once", service.Visit{}).Return(repository.Link{}, service.ErrExhausted)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/once", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+}
 
+func TestController_stats(t *testing.T) {
+	mockService := new(MockShortenerService)
 	controller := NewController(mockService)
+	router := setupRouter(controller)
 
-	assert.NotNil(t, controller)
-	assert.Equal(t, mockService, controller.service)
+	id := service.Identity{Owner: "alice"}
+	mockService.On("Authenticate", mock.Anything, "secret").Return(id, nil)
+	mockService.On("InspectLink", mock.Anything, id, "invite").Return(repository.Link{
+		Key:             "invite",
+		URL:             "https://example.com/invite",
+		CreatedAt:       time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		ExpiresAt:       time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		Clicks:          3,
+		MaxClicks:       5,
+		RemainingClicks: 0,
+		Variants: []repository.Variant{
+			{URL: "https://example.com/invite-a", Weight: 1, Clicks: 1},
+			{URL: "https://example.com/invite-b", Weight: 2, Clicks: 2},
+		},
+	}, nil)
+	mockService.On("InspectLink", mock.Anything, id, "other").Return(repository.Link{}, service.ErrForbidden)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/links/invite/stats", nil)
+	req.Header.Set("X-API-Key", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"key": "invite",
+		"url": "https://example.com/invite",
+		"created_at": "2024-05-01T00:00:00Z",
+		"expires_at": "2024-06-01T00:00:00Z",
+		"clicks": 3,
+		"max_clicks": 5,
+		"remaining_clicks": 0,
+		"variants": [
+			{"url": "https://example.com/invite-a", "weight": 1, "clicks": 1},
+			{"url": "https://example.com/invite-b", "weight": 2, "clicks": 2}
+		]
+	}`, w.Body.String())
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/links/other/stats", nil)
+	req.Header.Set("X-API-Key", "secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestController_get_NotFound(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := setupRouter(controller)
+
+	mockService.On("GetOriginalURL", mock.Anything, "notfound", service.Visit{}).Return(repository.Link{}, errors.New("key not found"))
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/notfound", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var response errorResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "url not found", response.Error)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestController_get_EmptyKey(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := setupRouter(controller)
+
+	mockService.On("GetOriginalURL", mock.Anything, "", service.Visit{}).Return(repository.Link{}, errors.New("empty key"))
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestController_create_Errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		serviceError error
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "invalid url", serviceError: service.ErrInvalidURL, expectedCode: http.StatusBadRequest, expectedBody: "invalid url"},
+		{name: "alias taken", serviceError: service.ErrConflict, expectedCode: http.StatusConflict, expectedBody: "short key already taken"},
+		{name: "storage failure", serviceError: errors.New("connection refused"), expectedCode: http.StatusInternalServerError, expectedBody: "failed to shorten url"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			controller := NewController(mockService)
+			router := setupRouter(controller)
+
+			mockService.On("ShortenURL", mock.Anything, service.ShortenRequest{
+				URL:   "https://example.com",
+				Alias: "launch",
+				TTL:   time.Hour,
+			}).Return("", tt.serviceError)
+
+			bodyBytes := []byte(`{"url": "https://example.com", "alias": "launch", "ttl": 3600}`)
+			req, _ := http.NewRequest(http.MethodPost, "/api/v1/", bytes.NewBuffer(bodyBytes))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+
+			var response errorResponse
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedBody, response.Error)
+		})
+	}
+}
+
+func TestController_batch_Success(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := setupRouter(controller)
+
+	mockService.On("ShortenBatch", mock.Anything, []service.ShortenRequest{
+		{URL: "https://example.com"},
+		{URL: "bad"},
+		{URL: "https://example.org", Alias: "promo", TTL: time.Minute},
+	}).Return([]service.BatchResult{
+		{Key: "abc123"},
+		{Err: service.ErrInvalidURL},
+		{Key: "promo"},
+	})
+
+	bodyBytes := []byte(`{"items": [
+		{"url": "https://example.com"},
+		{"url": "bad"},
+		{"url": "https://example.org", "alias": "promo", "ttl": 60}
+	]}`)
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/batch", bytes.NewBuffer(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response batchResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, []batchItemResponse{
+		{URL: "abc123"},
+		{Error: "invalid url"},
+		{URL: "promo"},
+	}, response.Results)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestController_batch_TooLarge(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService, WithMaxBatchSize(2))
+	router := setupRouter(controller)
+
+	bodyBytes := []byte(`{"items": [{"url": "https://a.example"}, {"url": "https://b.example"}, {"url": "https://c.example"}]}`)
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/batch", bytes.NewBuffer(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "ShortenBatch", mock.Anything, mock.Anything)
+}
+
+func TestController_create_WithAPIKey(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := setupRouter(controller)
+
+	bodyBytes, _ := json.Marshal(shortenRequest{URL: "https://example.com"})
+
+	mockService.On("Authenticate", mock.Anything, "secret").Return(service.Identity{Owner: "alice"}, nil)
+	mockService.On("ShortenURL", mock.Anything, service.ShortenRequest{URL: "https://example.com", Owner: "alice"}).Return("xyz789", nil)
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/", bytes.NewBuffer(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestController_create_InvalidAPIKey(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := setupRouter(controller)
+
+	bodyBytes, _ := json.Marshal(shortenRequest{URL: "https://example.com"})
+
+	mockService.On("Authenticate", mock.Anything, "wrong").Return(service.Identity{}, service.ErrUnauthorized)
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/", bytes.NewBuffer(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "wrong")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	mockService.AssertNotCalled(t, "ShortenURL", mock.Anything, mock.Anything)
+}
+
+func TestController_list_Success(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := setupRouter(controller)
+
+	mockService.On("Authenticate", mock.Anything, "secret").Return(service.Identity{Owner: "alice"}, nil)
+	mockService.On("ListLinks", mock.Anything, service.ListRequest{
+		Owner: "alice",
+		Sort:  repository.SortByClicks,
+		Limit: 10,
+	}).Return(service.LinkPage{
+		Links:      []repository.Link{{Key: "abc123", URL: "https://example.com", Owner: "alice", Clicks: 3}},
+		NextCursor: "MTA",
+	}, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/links?owner=me&sort=clicks&limit=10", nil)
+	req.Header.Set("X-API-Key", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response listResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response.Links, 1)
+	assert.Equal(t, "abc123", response.Links[0].Key)
+	assert.Equal(t, int64(3), response.Links[0].Clicks)
+	assert.Equal(t, "MTA", response.NextCursor)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestController_list_RequiresAPIKey(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := setupRouter(controller)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/links?owner=me", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestController_list_OtherOwnerForbidden(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := setupRouter(controller)
+
+	mockService.On("Authenticate", mock.Anything, "secret").Return(service.Identity{Owner: "alice"}, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/links?owner=bob", nil)
+	req.Header.Set("X-API-Key", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockService.AssertNotCalled(t, "ListLinks", mock.Anything, mock.Anything)
+}
+
+func TestController_update(t *testing.T) {
+	tests := []struct {
+		name         string
+		serviceError error
+		expectedCode int
+	}{
+		{name: "success", serviceError: nil, expectedCode: http.StatusNoContent},
+		{name: "not owner", serviceError: service.ErrForbidden, expectedCode: http.StatusForbidden},
+		{name: "not found", serviceError: service.ErrNotFound, expectedCode: http.StatusNotFound},
+		{name: "blocked", serviceError: &service.PolicyError{Rule: "redirect-loop"}, expectedCode: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			controller := NewController(mockService)
+			router := setupRouter(controller)
+
+			id := service.Identity{Owner: "alice"}
+			mockService.On("Authenticate", mock.Anything, "secret").Return(id, nil)
+			mockService.On("UpdateURL", mock.Anything, id, "abc123", "https://example.org").Return(tt.serviceError)
+
+			bodyBytes, _ := json.Marshal(updateRequest{URL: "https://example.org"})
+			req, _ := http.NewRequest(http.MethodPatch, "/api/v1/abc123", bytes.NewBuffer(bodyBytes))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-API-Key", "secret")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestController_delete(t *testing.T) {
+	tests := []struct {
+		name         string
+		apiKey       string
+		serviceError error
+		expectedCode int
+	}{
+		{name: "success", apiKey: "secret", serviceError: nil, expectedCode: http.StatusNoContent},
+		{name: "not owner", apiKey: "secret", serviceError: service.ErrForbidden, expectedCode: http.StatusForbidden},
+		{name: "anonymous", apiKey: "", expectedCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			controller := NewController(mockService)
+			router := setupRouter(controller)
+
+			id := service.Identity{Owner: "alice"}
+			mockService.On("Authenticate", mock.Anything, "secret").Return(id, nil)
+			mockService.On("DeleteURL", mock.Anything, id, "abc123").Return(tt.serviceError)
+
+			req, _ := http.NewRequest(http.MethodDelete, "/api/v1/abc123", nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
+
+func TestController_RateLimit_Exceeded(t *testing.T) {
+	mockService := new(MockShortenerService)
+	limiter := &stubLimiter{result: ratelimit.Result{
+		Allowed:    false,
+		Limit:      30,
+		Remaining:  0,
+		Reset:      40 * time.Second,
+		RetryAfter: 1500 * time.Millisecond,
+	}}
+	controller := NewController(mockService, WithCreateLimiter(limiter))
+	router := setupRouter(controller)
+
+	bodyBytes, _ := json.Marshal(shortenRequest{URL: "https://example.com"})
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/", bytes.NewBuffer(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "40", w.Header().Get("X-RateLimit-Reset"))
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Equal(t, []string{"ip:192.0.2.1"}, limiter.keys)
+	mockService.AssertNotCalled(t, "ShortenURL", mock.Anything, mock.Anything)
+}
+
+func TestController_RateLimit_ForwardedFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		proxies  []string
+		expected []int
+	}{
+		// A forged header must not give the client a fresh budget.
+		{"untrusted", nil, []int{http.StatusMovedPermanently, http.StatusTooManyRequests, http.StatusTooManyRequests}},
+		{"trusted proxy", []string{"192.0.2.1"}, []int{http.StatusMovedPermanently, http.StatusMovedPermanently, http.StatusMovedPermanently}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			limiter := ratelimit.NewMemoryLimiter(ratelimit.Rule{Limit: 1, Window: time.Minute})
+			router := setupRouter(NewController(mockService, WithRedirectLimiter(limiter)))
+			assert.NoError(t, router.SetTrustedProxies(tt.proxies))
+
+			mockService.On("GetOriginalURL", mock.Anything, "abc123", mock.Anything).Return(repository.Link{Key: "abc123", URL: "https://example.com"}, nil)
+
+			for i, forwarded := range []string{"", "203.0.113.1", "203.0.113.2"} {
+				req, _ := http.NewRequest(http.MethodGet, "/api/v1/abc123", nil)
+				req.RemoteAddr = "192.0.2.1:1234"
+				if forwarded != "" {
+					req.Header.Set("X-Forwarded-For", forwarded)
+				}
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				assert.Equal(t, tt.expected[i], w.Code, "request %d", i)
+			}
+		})
+	}
+}
+
+func TestController_RateLimit_KeyedByAPIKey(t *testing.T) {
+	mockService := new(MockShortenerService)
+	limiter := &stubLimiter{result: ratelimit.Result{Allowed: true, Limit: 300, Remaining: 299}}
+	controller := NewController(mockService, WithRedirectLimiter(limiter))
+	router := setupRouter(controller)
+
+	mockService.On("Authenticate", mock.Anything, "secret").Return(service.Identity{Owner: "alice"}, nil)
+	mockService.On("GetOriginalURL", mock.Anything, "abc123", service.Visit{}).Return(repository.Link{Key: "abc123", URL: "https://example.com"}, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/abc123", nil)
+	req.Header.Set("X-API-Key", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "299", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Len(t, limiter.keys, 1)
+	assert.Contains(t, limiter.keys[0], "key:")
+	assert.NotContains(t, limiter.keys[0], "secret")
+}
+
+func TestController_export_RequiresAdmin(t *testing.T) {
+	tests := []struct {
+		name         string
+		apiKey       string
+		expectedCode int
+	}{
+		{name: "anonymous", apiKey: "", expectedCode: http.StatusUnauthorized},
+		{name: "not admin", apiKey: "user", expectedCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			controller := NewController(mockService)
+			router := setupRouter(controller)
+
+			mockService.On("Authenticate", mock.Anything, "user").Return(service.Identity{Owner: "alice"}, nil)
+
+			req, _ := http.NewRequest(http.MethodGet, "/api/v1/admin/export", nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			mockService.AssertNotCalled(t, "ExportLinks", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestController_export_CSV(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := setupRouter(controller)
+
+	mockService.On("Authenticate", mock.Anything, "admin").Return(service.Identity{Owner: "ops", Admin: true}, nil)
+	mockService.On("ExportLinks", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		fn := args.Get(1).(func(repository.Link) error)
+		fn(repository.Link{Key: "abc123", URL: "https://example.com", CreatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)})
+	}).Return(nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/admin/export?format=csv", nil)
+	req.Header.Set("X-API-Key", "admin")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Equal(t, "key,url,created_at,expires_at,owner,clicks,interstitial,password_hash,max_clicks,remaining_clicks,not_before,not_after,fallback_url,rules,variants,utm,forward_query,forward_path,signed,disabled_reason,disabled_at,approved_url\n"+
+		"abc123,https://example.com,2024-05-01T00:00:00Z,,,0,,,,,,,,,,,,,,,,\n", w.Body.String())
+}
+
+func TestController_import_Summary(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := setupRouter(controller)
+
+	mockService.On("Authenticate", mock.Anything, "admin").Return(service.Identity{Owner: "ops", Admin: true}, nil)
+	mockService.On("ImportLinks", mock.Anything, mock.Anything).Return([]error{nil, service.ErrConflict})
+
+	body := `{"key": "abc123", "url": "https://example.com"}
+{"key": "taken", "url": "https://example.org"}
+`
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/admin/import?format=jsonl", bytes.NewBufferString(body))
+	req.Header.Set("X-API-Key", "admin")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"imported": 1,
+		"conflicts": 1,
+		"failed": 0,
+		"errors": [{"line": 2, "key": "taken", "error": "short key already taken"}]
+	}`, w.Body.String())
+}
+
+func TestController_listDisabled(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := setupRouter(controller)
+
+	disabledAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mockService.On("Authenticate", mock.Anything, "admin").Return(service.Identity{Owner: "ops", Admin: true}, nil)
+	mockService.On("ListDisabledLinks", mock.Anything).Return([]repository.Link{
+		{Key: "abc123", URL: "https://evil.example", DisabledReason: "blocklist:evil.example", DisabledAt: disabledAt},
+	}, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/admin/disabled", nil)
+	req.Header.Set("X-API-Key", "admin")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{
+		"key": "abc123",
+		"url": "https://evil.example",
+		"reason": "blocklist:evil.example",
+		"disabled_at": "2024-05-01T12:00:00Z"
+	}]`, w.Body.String())
+}
+
+func TestController_enableLink(t *testing.T) {
+	tests := []struct {
+		name         string
+		serviceError error
+		expectedCode int
+	}{
+		{name: "success", expectedCode: http.StatusNoContent},
+		{name: "not found", serviceError: service.ErrNotFound, expectedCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			controller := NewController(mockService)
+			router := setupRouter(controller)
+
+			mockService.On("Authenticate", mock.Anything, "admin").Return(service.Identity{Owner: "ops", Admin: true}, nil)
+			mockService.On("EnableLink", mock.Anything, "abc123").Return(tt.serviceError)
+
+			req, _ := http.NewRequest(http.MethodPost, "/api/v1/admin/disabled/abc123/enable", nil)
+			req.Header.Set("X-API-Key", "admin")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestController_RegisterRoutes(t *testing.T) {
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	router := gin.New()
+
+	controller.RegisterRoutes(router)
+
+	routes := router.Routes()
+	assert.Len(t, routes, 18)
+
+	var hasPostRoute, hasGetRoute bool
+	for _, route := range routes {
+		if route.Method == "POST" && route.Path == "/api/v1/" {
+			hasPostRoute = true
+		}
+		if route.Method == "GET" && route.Path == "/api/v1/:key" {
+			hasGetRoute = true
+		}
+	}
+
+	assert.True(t, hasPostRoute, "POST route should be registered")
+	assert.True(t, hasGetRoute, "GET route should be registered")
+}
+
+func TestNewController(t *testing.T) {
+	mockService := new(MockShortenerService)
+
+	controller := NewController(mockService)
+
+	assert.NotNil(t, controller)
+	assert.Equal(t, mockService, controller.service)
+}
+
+func TestRequestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(logging.Config{Level: "info", Format: logging.FormatJSON}, &buf)
+	assert.NoError(t, err)
+
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), AccessLog(logger))
+	controller.RegisterRoutes(router)
+
+	var seen string
+	mockService.On("GetOriginalURL", mock.Anything, "abc", service.Visit{Query: url.Values{"token": {"secret"}}}).
+		Run(func(args mock.Arguments) {
+			seen = logging.RequestID(args.Get(0).(context.Context))
+		}).
+		Return(repository.Link{}, errors.New("connection refused"))
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/abc?token=secret", nil)
+	req.Header.Set("X-Request-ID", "trace-42")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "trace-42", w.Header().Get("X-Request-ID"))
+	assert.Equal(t, "trace-42", seen)
+
+	var record map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "trace-42", record["request_id"])
+	assert.Equal(t, "/api/v1/abc?token=REDACTED", record["path"])
+	assert.Equal(t, float64(http.StatusNotFound), record["status"])
+	assert.Equal(t, "WARN", record["level"])
+	assert.Contains(t, record["error"], "connection refused")
+	assert.NotContains(t, buf.String(), "secret")
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/links", nil)
+	req.Header.Set("X-Request-ID", "bad id\nwith newline")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Regexp(t, "^[0-9a-f]{32}$", w.Header().Get("X-Request-ID"))
+}
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	mockService := new(MockShortenerService)
+	controller := NewController(mockService)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Tracing("url-shortener"))
+	controller.RegisterRoutes(router)
+
+	var seen trace.SpanContext
+	mockService.On("GetOriginalURL", mock.Anything, "abc", service.Visit{}).
+		Run(func(args mock.Arguments) {
+			seen = trace.SpanContextFromContext(args.Get(0).(context.Context))
+		}).
+		Return(repository.Link{Key: "abc", URL: "https://example.com"}, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/abc", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 1) {
+		span := spans[0]
+		assert.Equal(t, "GET /api/v1/:key", span.Name)
+		assert.Equal(t, trace.SpanKindServer, span.SpanKind)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+		assert.True(t, span.Parent.IsRemote())
+		assert.Equal(t, span.SpanContext.SpanID(), seen.SpanID(), "service should run inside the request span")
+	}
+
+	exporter.Reset()
+	req, _ = http.NewRequest(http.MethodGet, "/swagger/index.html", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Empty(t, exporter.GetSpans())
+}
+
+type MockWebhooks struct {
+	mock.Mock
+}
+
+func (m *MockWebhooks) Subscribe(ctx context.Context, sub webhook.Subscription) (webhook.Subscription, error) {
+	args := m.Called(ctx, sub)
+	return args.Get(0).(webhook.Subscription), args.Error(1)
+}
+
+func (m *MockWebhooks) Subscriptions(ctx context.Context, owner string) ([]webhook.Subscription, error) {
+	args := m.Called(ctx, owner)
+	return args.Get(0).([]webhook.Subscription), args.Error(1)
+}
+
+func (m *MockWebhooks) Unsubscribe(ctx context.Context, owner, id string) error {
+	args := m.Called(ctx, owner, id)
+	return args.Error(0)
+}
+
+func (m *MockWebhooks) DeadLetters(ctx context.Context, n int) ([]webhook.Delivery, error) {
+	args := m.Called(ctx, n)
+	return args.Get(0).([]webhook.Delivery), args.Error(1)
+}
+
+func TestController_createWebhook(t *testing.T) {
+	createdAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		body         string
+		identity     service.Identity
+		serviceError error
+		expectedCode int
+	}{
+		{
+			name:         "success",
+			body:         `{"url": "https://cms.example.com/hook", "events": ["link.created"]}`,
+			identity:     service.Identity{Owner: "alice"},
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "all links needs admin",
+			body:         `{"url": "https://cms.example.com/hook", "all_links": true}`,
+			identity:     service.Identity{Owner: "alice"},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "invalid event",
+			body:         `{"url": "https://cms.example.com/hook", "events": ["link.visited"]}`,
+			identity:     service.Identity{Owner: "alice"},
+			serviceError: webhook.ErrInvalidEvent,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "missing url",
+			body:         `{}`,
+			identity:     service.Identity{Owner: "alice"},
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			webhooks := new(MockWebhooks)
+			router := setupRouter(NewController(mockService, WithWebhooks(webhooks)))
+
+			mockService.On("Authenticate", mock.Anything, "secret").Return(tt.identity, nil)
+			webhooks.On("Subscribe", mock.Anything, webhook.Subscription{
+				Owner:  "alice",
+				URL:    "https://cms.example.com/hook",
+				Events: []service.EventType{service.EventLinkCreated},
+			}).Return(webhook.Subscription{
+				ID:        "sub1",
+				Owner:     "alice",
+				URL:       "https://cms.example.com/hook",
+				Secret:    "shh",
+				Events:    []service.EventType{service.EventLinkCreated},
+				CreatedAt: createdAt,
+			}, nil)
+			webhooks.On("Subscribe", mock.Anything, mock.Anything).Return(webhook.Subscription{}, tt.serviceError)
+
+			req, _ := http.NewRequest(http.MethodPost, "/api/v1/webhooks", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-API-Key", "secret")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode == http.StatusCreated {
+				assert.JSONEq(t, `{
+					"id": "sub1",
+					"url": "https://cms.example.com/hook",
+					"owner": "alice",
+					"events": ["link.created"],
+					"created_at": "2025-06-01T12:00:00Z",
+					"secret": "shh"
+				}`, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestController_listWebhooks(t *testing.T) {
+	mockService := new(MockShortenerService)
+	webhooks := new(MockWebhooks)
+	router := setupRouter(NewController(mockService, WithWebhooks(webhooks)))
+
+	mockService.On("Authenticate", mock.Anything, "secret").Return(service.Identity{Owner: "alice"}, nil)
+	mockService.On("Authenticate", mock.Anything, "admin").Return(service.Identity{Owner: "ops", Admin: true}, nil)
+	webhooks.On("Subscriptions", mock.Anything, "alice").Return([]webhook.Subscription{
+		{ID: "sub1", Owner: "alice", URL: "https://cms.example.com/hook", Secret: "shh"},
+	}, nil)
+	webhooks.On("Subscriptions", mock.Anything, "").Return([]webhook.Subscription{}, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/webhooks", nil)
+	req.Header.Set("X-API-Key", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"sub1"`)
+	assert.NotContains(t, w.Body.String(), "shh")
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/webhooks", nil)
+	req.Header.Set("X-API-Key", "admin")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	webhooks.AssertCalled(t, "Subscriptions", mock.Anything, "")
+}
+
+func TestController_deleteWebhook(t *testing.T) {
+	mockService := new(MockShortenerService)
+	webhooks := new(MockWebhooks)
+	router := setupRouter(NewController(mockService, WithWebhooks(webhooks)))
+
+	mockService.On("Authenticate", mock.Anything, "secret").Return(service.Identity{Owner: "alice"}, nil)
+	webhooks.On("Unsubscribe", mock.Anything, "alice", "sub1").Return(nil)
+	webhooks.On("Unsubscribe", mock.Anything, "alice", "other").Return(webhook.ErrNotFound)
+
+	for id, code := range map[string]int{"sub1": http.StatusNoContent, "other": http.StatusNotFound} {
+		req, _ := http.NewRequest(http.MethodDelete, "/api/v1/webhooks/"+id, nil)
+		req.Header.Set("X-API-Key", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, code, w.Code, id)
+	}
+}
+
+func TestController_listDeadWebhooks(t *testing.T) {
+	mockService := new(MockShortenerService)
+	webhooks := new(MockWebhooks)
+	router := setupRouter(NewController(mockService, WithWebhooks(webhooks)))
+
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	mockService.On("Authenticate", mock.Anything, "admin").Return(service.Identity{Owner: "ops", Admin: true}, nil)
+	webhooks.On("DeadLetters", mock.Anything, deadLetterLimit).Return([]webhook.Delivery{{
+		ID:           "d1",
+		Subscription: "sub1",
+		Event:        service.Event{Type: service.EventLinkDeleted, Key: "abc", Owner: "alice", At: at},
+		Attempts:     8,
+		LastError:    "unexpected status 503",
+	}}, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/admin/webhooks/dead", nil)
+	req.Header.Set("X-API-Key", "admin")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{
+		"id": "d1",
+		"subscription": "sub1",
+		"event": {"type": "link.deleted", "key": "abc", "owner": "alice", "at": "2025-06-01T12:00:00Z"},
+		"attempts": 8,
+		"last_error": "unexpected status 503"
+	}]`, w.Body.String())
+}
+
+func TestController_timeseries(t *testing.T) {
+	mockService := new(MockShortenerService)
+	router := setupRouter(NewController(mockService))
+
+	from := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(2 * time.Hour)
+	alice := service.Identity{Owner: "alice"}
+	mockService.On("Authenticate", mock.Anything, "secret").Return(alice, nil)
+	mockService.On("ClickTimeseries", mock.Anything, alice, "abc123", service.TimeseriesRequest{
+		Granularity: repository.GranularityHour,
+		From:        from,
+		To:          to,
+	}).Return(service.Timeseries{
+		Granularity: repository.GranularityHour,
+		From:        from,
+		To:          to,
+		Points: []service.TimeseriesPoint{
+			{Start: from, Clicks: 0},
+			{Start: from.Add(time.Hour), Clicks: 5},
+		},
+	}, nil)
+	mockService.On("ClickTimeseries", mock.Anything, alice, "abc123", service.TimeseriesRequest{Granularity: "week"}).
+		Return(service.Timeseries{}, service.ErrInvalidTimeseries)
+	mockService.On("ClickTimeseries", mock.Anything, alice, "other", mock.Anything).
+		Return(service.Timeseries{}, service.ErrForbidden)
+
+	tests := []struct {
+		path         string
+		expectedCode int
+	}{
+		{"/api/v1/links/abc123/stats/timeseries?granularity=hour&from=2025-06-01T00:00:00Z&to=2025-06-01T02:00:00Z", http.StatusOK},
+		{"/api/v1/links/abc123/stats/timeseries?granularity=week", http.StatusBadRequest},
+		{"/api/v1/links/abc123/stats/timeseries?from=yesterday", http.StatusBadRequest},
+		{"/api/v1/links/other/stats/timeseries", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("X-API-Key", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.expectedCode, w.Code, tt.path)
+		if tt.expectedCode == http.StatusOK {
+			assert.JSONEq(t, `{
+				"key": "abc123",
+				"granularity": "hour",
+				"from": "2025-06-01T00:00:00Z",
+				"to": "2025-06-01T02:00:00Z",
+				"points": [
+					{"start": "2025-06-01T00:00:00Z", "clicks": 0},
+					{"start": "2025-06-01T01:00:00Z", "clicks": 5}
+				]
+			}`, w.Body.String())
+		}
+	}
+}
+
+func TestController_dashboard(t *testing.T) {
+	from := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 2)
+	window := service.DashboardRequest{From: from, To: to, Limit: 2}
+	query := "?from=2025-06-01T00:00:00Z&to=2025-06-03T00:00:00Z&limit=2"
+
+	tests := []struct {
+		name         string
+		path         string
+		apiKey       string
+		setup        func(*MockShortenerService)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:   "top links",
+			path:   "/api/v1/admin/stats/top-links" + query,
+			apiKey: "admin",
+			setup: func(m *MockShortenerService) {
+				m.On("TopLinks", mock.Anything, window).Return(service.Ranking{
+					From: from, To: to,
+					Entries: []repository.Ranked{{Name: "abc123", Count: 9}, {Name: "def456", Count: 3}},
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"from":"2025-06-01T00:00:00Z","to":"2025-06-03T00:00:00Z","links":[{"key":"abc123","clicks":9},{"key":"def456","clicks":3}]}`,
+		},
+		{
+			name:   "top referrers",
+			path:   "/api/v1/admin/stats/top-referrers" + query,
+			apiKey: "admin",
+			setup: func(m *MockShortenerService) {
+				m.On("TopReferrers", mock.Anything, window).Return(service.Ranking{
+					From: from, To: to,
+					Entries: []repository.Ranked{{Name: "example.com", Count: 4}},
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"from":"2025-06-01T00:00:00Z","to":"2025-06-03T00:00:00Z","referrers":[{"domain":"example.com","clicks":4}]}`,
+		},
+		{
+			name:   "links created",
+			path:   "/api/v1/admin/stats/created?from=2025-06-01T00:00:00Z&to=2025-06-03T00:00:00Z",
+			apiKey: "admin",
+			setup: func(m *MockShortenerService) {
+				m.On("LinksCreated", mock.Anything, service.DashboardRequest{From: from, To: to}).Return(service.DailyCounts{
+					From: from, To: to,
+					Days: []service.DailyCount{{Day: from, Count: 0}, {Day: from.AddDate(0, 0, 1), Count: 5}},
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"from":"2025-06-01T00:00:00Z","to":"2025-06-03T00:00:00Z","days":[{"day":"2025-06-01T00:00:00Z","links":0},{"day":"2025-06-02T00:00:00Z","links":5}]}`,
+		},
+		{
+			name:   "invalid window",
+			path:   "/api/v1/admin/stats/top-links?limit=500",
+			apiKey: "admin",
+			setup: func(m *MockShortenerService) {
+				m.On("TopLinks", mock.Anything, service.DashboardRequest{Limit: 500}).Return(service.Ranking{}, service.ErrInvalidWindow)
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid limit",
+			path:         "/api/v1/admin/stats/top-links?limit=ten",
+			apiKey:       "admin",
+			setup:        func(m *MockShortenerService) {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "not admin",
+			path:         "/api/v1/admin/stats/top-links",
+			apiKey:       "secret",
+			setup:        func(m *MockShortenerService) {},
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			router := setupRouter(NewController(mockService))
+
+			mockService.On("Authenticate", mock.Anything, "admin").Return(service.Identity{Owner: "ops", Admin: true}, nil)
+			mockService.On("Authenticate", mock.Anything, "secret").Return(service.Identity{Owner: "alice"}, nil)
+			tt.setup(mockService)
+
+			req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("X-API-Key", tt.apiKey)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func newTestSigner(t *testing.T, required bool) *signing.Signer {
+	t.Helper()
+	signer, err := signing.New(signing.Config{
+		Enabled:    true,
+		Required:   required,
+		Keys:       map[string]string{"k1": "0123456789abcdef"},
+		CurrentKey: "k1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestController_create_Signed(t *testing.T) {
+	signer := newTestSigner(t, false)
+
+	tests := []struct {
+		name         string
+		opts         []Option
+		expectedCode int
+		expectedURL  string
+	}{
+		{"signed", []Option{WithSigner(signer)}, http.StatusOK, signer.Sign("abc123")},
+		{"signing disabled", nil, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			router := setupRouter(NewController(mockService, tt.opts...))
+			mockService.On("ShortenURL", mock.Anything, service.ShortenRequest{URL: "https://example.com", Signed: true}).Return("abc123", nil)
+
+			req, _ := http.NewRequest(http.MethodPost, "/api/v1/", strings.NewReader(`{"url":"https://example.com","signed":true}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedURL != "" {
+				var response shortenResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, tt.expectedURL, response.URL)
+			}
+		})
+	}
+}
+
+func TestController_create_SignatureRequired(t *testing.T) {
+	signer := newTestSigner(t, true)
+	mockService := new(MockShortenerService)
+	router := setupRouter(NewController(mockService, WithSigner(signer)))
+
+	mockService.On("ShortenBatch", mock.Anything, []service.ShortenRequest{{URL: "https://example.com", Signed: true}}).
+		Return([]service.BatchResult{{Key: "abc123"}})
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/batch", strings.NewReader(`{"items":[{"url":"https://example.com"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"results":[{"url":"`+signer.Sign("abc123")+`"}]}`, w.Body.String())
+}
+
+func TestController_get_Signed(t *testing.T) {
+	signer := newTestSigner(t, false)
+	signed := signer.Sign("abc123")
+
+	tests := []struct {
+		name         string
+		required     bool
+		path         string
+		expectedCode int
+	}{
+		{"valid signature", false, "/api/v1/" + signed, http.StatusMovedPermanently},
+		{"bad signature", false, "/api/v1/abc123.k1.AAAAAAAAAAAAAAAAAAAAAA", http.StatusNotFound},
+		{"unknown key", false, "/api/v1/" + strings.Replace(signed, ".k1.", ".k2.", 1), http.StatusNotFound},
+		{"unsigned", false, "/api/v1/plain", http.StatusMovedPermanently},
+		{"unsigned when required", true, "/api/v1/plain", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			router := setupRouter(NewController(mockService, WithSigner(newTestSigner(t, tt.required))))
+			mockService.On("GetOriginalURL", mock.Anything, "abc123", service.Visit{Signed: true}).
+				Return(repository.Link{Key: "abc123", URL: "https://example.com"}, nil)
+			mockService.On("GetOriginalURL", mock.Anything, "plain", service.Visit{}).
+				Return(repository.Link{Key: "plain", URL: "https://example.com"}, nil)
+
+			req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode == http.StatusNotFound {
+				// Rejected signatures never reach the service.
+				mockService.AssertNotCalled(t, "GetOriginalURL", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestController_ScanGuard(t *testing.T) {
+	mockService := new(MockShortenerService)
+	guard := ratelimit.NewScanGuard(ratelimit.Config{
+		Backend: ratelimit.BackendMemory,
+		Scan: ratelimit.ScanConfig{
+			Enabled:  true,
+			Misses:   ratelimit.Rule{Limit: 2, Window: time.Minute},
+			BlockFor: time.Minute,
+		},
+	}, nil, repository.Keyspace{})
+	router := setupRouter(NewController(mockService, WithScanGuard(guard)))
+
+	mockService.On("GetOriginalURL", mock.Anything, "abc123", mock.Anything).Return(repository.Link{Key: "abc123", URL: "https://example.com"}, nil)
+	mockService.On("GetOriginalURL", mock.Anything, mock.Anything, mock.Anything).Return(repository.Link{}, service.ErrNotFound)
+
+	visit := func(path string, addr string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Hits do not count against the client.
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusMovedPermanently, visit("/api/v1/abc123", "203.0.113.7:1000").Code)
+	}
+	for _, key := range []string{"aaa", "aab", "aac"} {
+		assert.Equal(t, http.StatusNotFound, visit("/api/v1/"+key, "203.0.113.7:1000").Code)
+	}
+
+	// The third miss blocked the client, even for valid keys, without a
+	// lookup.
+	calls := len(mockService.Calls)
+	w := visit("/api/v1/abc123", "203.0.113.7:1000")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Len(t, mockService.Calls, calls)
+
+	assert.Equal(t, http.StatusMovedPermanently, visit("/api/v1/abc123", "198.51.100.1:1000").Code)
+	assert.Equal(t, ratelimit.ScanStats{BlockedClients: 1, RejectedRequests: 1}, guard.Stats())
+}
+
+func TestController_AuthGuard(t *testing.T) {
+	mockService := new(MockShortenerService)
+	guard := ratelimit.NewAuthGuard(ratelimit.Config{
+		Backend: ratelimit.BackendMemory,
+		Auth: ratelimit.ScanConfig{
+			Enabled:  true,
+			Misses:   ratelimit.Rule{Limit: 2, Window: time.Minute},
+			BlockFor: time.Minute,
+		},
+	}, nil, repository.Keyspace{})
+	router := setupRouter(NewController(mockService, WithAuthGuard(guard)))
+
+	mockService.On("Authenticate", mock.Anything, "secret").Return(service.Identity{Owner: "alice"}, nil)
+	mockService.On("Authenticate", mock.Anything, mock.Anything).Return(service.Identity{}, service.ErrUnauthorized)
+	mockService.On("ListLinks", mock.Anything, mock.Anything).Return(service.LinkPage{}, nil)
+
+	list := func(apiKey string, addr string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/links", nil)
+		req.Header.Set("X-API-Key", apiKey)
+		req.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, list("secret", "203.0.113.7:1000").Code)
+	for _, key := range []string{"guess1", "guess2", "guess3"} {
+		assert.Equal(t, http.StatusUnauthorized, list(key, "203.0.113.7:1000").Code)
+	}
+
+	// The third invalid key blocked the client, even with a valid key, and
+	// its keys are no longer looked up.
+	calls := len(mockService.Calls)
+	w := list("guess4", "203.0.113.7:1000")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusTooManyRequests, list("secret", "203.0.113.7:1000").Code)
+	assert.Len(t, mockService.Calls, calls)
+
+	assert.Equal(t, http.StatusOK, list("secret", "198.51.100.1:1000").Code)
+}
+
+func TestController_metrics(t *testing.T) {
+	mockService := new(MockShortenerService)
+	router := setupRouter(NewController(mockService))
+	mockService.On("Authenticate", mock.Anything, "admin").Return(service.Identity{Owner: "ops", Admin: true}, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/admin/metrics", nil)
+	req.Header.Set("X-API-Key", "admin")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var vars map[string]any
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &vars))
+	assert.Contains(t, vars, "memstats")
+}
+
+func TestController_Domains(t *testing.T) {
+	mockService := new(MockShortenerService)
+	router := setupRouter(NewController(mockService, WithDomains("sho.rt")))
+
+	brandA := repository.Domain{Name: "brand-a.link", RedirectCode: http.StatusFound, FallbackURL: "https://brand-a.com"}
+	inBrandA := mock.MatchedBy(func(ctx context.Context) bool {
+		return repository.DomainFrom(ctx) == "brand-a.link"
+	})
+	inDefault := mock.MatchedBy(func(ctx context.Context) bool {
+		return repository.DomainFrom(ctx) == ""
+	})
+	mockService.On("GetDomain", mock.Anything, "brand-a.link").Return(brandA, nil)
+	mockService.On("GetDomain", mock.Anything, "brand-b.link").Return(repository.Domain{}, service.ErrUnknownDomain)
+	mockService.On("GetOriginalURL", inBrandA, "xyz", mock.Anything).Return(repository.Link{Key: "xyz", URL: "https://brand-a.com/sale"}, nil)
+	mockService.On("GetOriginalURL", inBrandA, "nope", mock.Anything).Return(repository.Link{}, service.ErrNotFound)
+	mockService.On("GetOriginalURL", inDefault, "xyz", mock.Anything).Return(repository.Link{Key: "xyz", URL: "https://example.com"}, nil)
+	mockService.On("ShortenURL", inBrandA, service.ShortenRequest{URL: "https://brand-a.com/new"}).Return("new123", nil)
+
+	send := func(method, host, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Host = host
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send(http.MethodGet, "Brand-A.link:443", "/api/v1/xyz", "")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://brand-a.com/sale", w.Header().Get("Location"))
+
+	w = send(http.MethodGet, "brand-a.link", "/api/v1/nope", "")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://brand-a.com", w.Header().Get("Location"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	w = send(http.MethodGet, "sho.rt", "/api/v1/xyz", "")
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "https://example.com", w.Header().Get("Location"))
+
+	w = send(http.MethodPost, "brand-a.link", "/api/v1/", `{"url":"https://brand-a.com/new"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"url":"new123"}`, w.Body.String())
+
+	w = send(http.MethodGet, "brand-b.link", "/api/v1/xyz", "")
+	assert.Equal(t, http.StatusMisdirectedRequest, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestController_domainAdmin(t *testing.T) {
+	created := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	brandA := repository.Domain{Name: "brand-a.link", RedirectCode: http.StatusFound, AllowedDestinations: []string{"brand-a.com"}}
+	saved := brandA
+	saved.CreatedAt = created
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		body         string
+		apiKey       string
+		setup        func(*MockShortenerService)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:   "register",
+			method: http.MethodPut,
+			path:   "/api/v1/admin/domains/Brand-A.link",
+			body:   `{"redirect_code":302,"allowed_destinations":["brand-a.com"]}`,
+			apiKey: "admin",
+			setup: func(m *MockShortenerService) {
+				m.On("SaveDomain", mock.Anything, brandA).Return(saved, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"name":"brand-a.link","redirect_code":302,"allowed_destinations":["brand-a.com"],"created_at":"2025-06-01T00:00:00Z"}`,
+		},
+		{
+			name:   "invalid",
+			method: http.MethodPut,
+			path:   "/api/v1/admin/domains/brand-a.link",
+			body:   `{"redirect_code":200}`,
+			apiKey: "admin",
+			setup: func(m *MockShortenerService) {
+				m.On("SaveDomain", mock.Anything, repository.Domain{Name: "brand-a.link", RedirectCode: 200}).Return(repository.Domain{}, service.ErrInvalidDomain)
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:   "list",
+			method: http.MethodGet,
+			path:   "/api/v1/admin/domains",
+			apiKey: "admin",
+			setup: func(m *MockShortenerService) {
+				m.On("ListDomains", mock.Anything).Return([]repository.Domain{saved}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `[{"name":"brand-a.link","redirect_code":302,"allowed_destinations":["brand-a.com"],"created_at":"2025-06-01T00:00:00Z"}]`,
+		},
+		{
+			name:   "delete",
+			method: http.MethodDelete,
+			path:   "/api/v1/admin/domains/brand-a.link",
+			apiKey: "admin",
+			setup: func(m *MockShortenerService) {
+				m.On("DeleteDomain", mock.Anything, "brand-a.link").Return(nil)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:   "delete unknown",
+			method: http.MethodDelete,
+			path:   "/api/v1/admin/domains/brand-b.link",
+			apiKey: "admin",
+			setup: func(m *MockShortenerService) {
+				m.On("DeleteDomain", mock.Anything, "brand-b.link").Return(service.ErrUnknownDomain)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "not admin",
+			method:       http.MethodGet,
+			path:         "/api/v1/admin/domains",
+			apiKey:       "secret",
+			setup:        func(m *MockShortenerService) {},
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			router := setupRouter(NewController(mockService, WithDomains("example.com")))
+
+			mockService.On("Authenticate", mock.Anything, "admin").Return(service.Identity{Owner: "ops", Admin: true}, nil)
+			mockService.On("Authenticate", mock.Anything, "secret").Return(service.Identity{Owner: "alice"}, nil)
+			tt.setup(mockService)
+
+			req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Host = "example.com"
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-API-Key", tt.apiKey)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			}
+		})
+	}
 }
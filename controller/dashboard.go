@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"url-shortener/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type linkRankResponse struct {
+	Key    string `json:"key" example:"abc123"`
+	Clicks int64  `json:"clicks" example:"1520"`
+}
+
+type topLinksResponse struct {
+	From  time.Time          `json:"from"`
+	To    time.Time          `json:"to"`
+	Links []linkRankResponse `json:"links"`
+}
+
+type referrerRankResponse struct {
+	Domain string `json:"domain" example:"news.ycombinator.com"`
+	Clicks int64  `json:"clicks" example:"310"`
+}
+
+type topReferrersResponse struct {
+	From      time.Time              `json:"from"`
+	To        time.Time              `json:"to"`
+	Referrers []referrerRankResponse `json:"referrers"`
+}
+
+type createdDayResponse struct {
+	Day   time.Time `json:"day"`
+	Links int64     `json:"links" example:"42"`
+}
+
+type linksCreatedResponse struct {
+	From time.Time            `json:"from"`
+	To   time.Time            `json:"to"`
+	Days []createdDayResponse `json:"days"`
+}
+
+// topLinks godoc
+//
+//	@Summary		Most clicked links
+//	@Description	rank links by their clicks on the UTC days of a window
+//	@Tags			admin
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			from	query		string	false	"RFC 3339 start, default 7 days ago"
+//	@Param			to		query		string	false	"RFC 3339 end, default now"
+//	@Param			limit	query		int		false	"Number of links, at most 100"	default(10)
+//	@Success		200		{object}	topLinksResponse
+//	@Failure		400		{object}	errorResponse
+//	@Failure		401		{object}	errorResponse
+//	@Failure		403		{object}	errorResponse
+//	@Router			/api/v1/admin/stats/top-links [get]
+func (c *Controller) topLinks(ctx *gin.Context) {
+	req, ok := dashboardRequest(ctx)
+	if !ok {
+		return
+	}
+	ranking, err := c.service.TopLinks(ctx, req)
+	if err != nil {
+		dashboardError(ctx, err)
+		return
+	}
+
+	resp := topLinksResponse{From: ranking.From, To: ranking.To, Links: make([]linkRankResponse, len(ranking.Entries))}
+	for i, entry := range ranking.Entries {
+		resp.Links[i] = linkRankResponse{Key: entry.Name, Clicks: entry.Count}
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// topReferrers godoc
+//
+//	@Summary		Top referring domains
+//	@Description	rank the domains visitors came from by clicks on the UTC days of a window
+//	@Tags			admin
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			from	query		string	false	"RFC 3339 start, default 7 days ago"
+//	@Param			to		query		string	false	"RFC 3339 end, default now"
+//	@Param			limit	query		int		false	"Number of domains, at most 100"	default(10)
+//	@Success		200		{object}	topReferrersResponse
+//	@Failure		400		{object}	errorResponse
+//	@Failure		401		{object}	errorResponse
+//	@Failure		403		{object}	errorResponse
+//	@Router			/api/v1/admin/stats/top-referrers [get]
+func (c *Controller) topReferrers(ctx *gin.Context) {
+	req, ok := dashboardRequest(ctx)
+	if !ok {
+		return
+	}
+	ranking, err := c.service.TopReferrers(ctx, req)
+	if err != nil {
+		dashboardError(ctx, err)
+		return
+	}
+
+	resp := topReferrersResponse{From: ranking.From, To: ranking.To, Referrers: make([]referrerRankResponse, len(ranking.Entries))}
+	for i, entry := range ranking.Entries {
+		resp.Referrers[i] = referrerRankResponse{Domain: entry.Name, Clicks: entry.Count}
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// linksCreated godoc
+//
+//	@Summary		Links created per day
+//	@Description	count the links created on each UTC day of a window; days without any are included
+//	@Tags			admin
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			from	query		string	false	"RFC 3339 start, default 7 days ago"
+//	@Param			to		query		string	false	"RFC 3339 end, default now"
+//	@Success		200		{object}	linksCreatedResponse
+//	@Failure		400		{object}	errorResponse
+//	@Failure		401		{object}	errorResponse
+//	@Failure		403		{object}	errorResponse
+//	@Router			/api/v1/admin/stats/created [get]
+func (c *Controller) linksCreated(ctx *gin.Context) {
+	req, ok := dashboardRequest(ctx)
+	if !ok {
+		return
+	}
+	counts, err := c.service.LinksCreated(ctx, req)
+	if err != nil {
+		dashboardError(ctx, err)
+		return
+	}
+
+	resp := linksCreatedResponse{From: counts.From, To: counts.To, Days: make([]createdDayResponse, len(counts.Days))}
+	for i, day := range counts.Days {
+		resp.Days[i] = createdDayResponse{Day: day.Day, Links: day.Count}
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// dashboardRequest reads the window and limit of a statistics query and
+// responds with 400 if they do not parse.
+func dashboardRequest(ctx *gin.Context) (service.DashboardRequest, bool) {
+	var req service.DashboardRequest
+	if !queryTime(ctx, "from", &req.From) || !queryTime(ctx, "to", &req.To) {
+		return req, false
+	}
+	if raw := ctx.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse{Error: "invalid limit"})
+			return req, false
+		}
+		req.Limit = limit
+	}
+	return req, true
+}
+
+// queryTime parses the RFC 3339 query parameter name into dst, leaving it
+// unchanged if absent, and responds with 400 if it does not parse.
+func queryTime(ctx *gin.Context, name string, dst *time.Time) bool {
+	raw := ctx.Query(name)
+	if raw == "" {
+		return true
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: "invalid " + name})
+		return false
+	}
+	*dst = t
+	return true
+}
+
+func dashboardError(ctx *gin.Context, err error) {
+	if errors.Is(err, service.ErrInvalidWindow) {
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	_ = ctx.Error(err)
+	ctx.JSON(http.StatusInternalServerError, errorResponse{Error: "failed to load statistics"})
+}
@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"url-shortener/repository"
+	"url-shortener/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// missKey marks requests answered with a domain's fallback URL instead of a
+// 404, so that the scan guard still counts them.
+const missKey = "missed"
+
+type domainRequest struct {
+	// RedirectCode replaces 301 for links that may be cached.
+	RedirectCode int `json:"redirect_code,omitempty" enums:"301,302,307,308" example:"302"`
+	// FallbackURL receives visitors of unknown keys instead of a 404.
+	FallbackURL string `json:"fallback_url,omitempty" example:"https://brand-a.com"`
+	// AllowedDestinations limits links to these hosts and their subdomains.
+	AllowedDestinations []string `json:"allowed_destinations,omitempty" example:"brand-a.com"`
+}
+
+type domainResponse struct {
+	Name                string    `json:"name" example:"brand-a.link"`
+	RedirectCode        int       `json:"redirect_code,omitempty" example:"302"`
+	FallbackURL         string    `json:"fallback_url,omitempty" example:"https://brand-a.com"`
+	AllowedDestinations []string  `json:"allowed_destinations,omitempty" example:"brand-a.com"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+func newDomainResponse(d repository.Domain) domainResponse {
+	return domainResponse{
+		Name:                d.Name,
+		RedirectCode:        d.RedirectCode,
+		FallbackURL:         d.FallbackURL,
+		AllowedDestinations: d.AllowedDestinations,
+		CreatedAt:           d.CreatedAt,
+	}
+}
+
+// requestHost is the lower-case host of the request without its port.
+func requestHost(ctx *gin.Context) string {
+	host := ctx.Request.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// resolveDomain scopes the request to the registered domain named by its
+// Host header. The default hosts keep the unscoped namespace and any other
+// host is rejected.
+func (c *Controller) resolveDomain(ctx *gin.Context) {
+	host := requestHost(ctx)
+	if slices.Contains(c.defaultHosts, host) {
+		ctx.Next()
+		return
+	}
+
+	d, err := c.service.GetDomain(ctx, host)
+	switch {
+	case errors.Is(err, service.ErrUnknownDomain):
+		ctx.AbortWithStatusJSON(http.StatusMisdirectedRequest, errorResponse{Error: "unknown domain"})
+		return
+	case err != nil:
+		_ = ctx.Error(err)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse{Error: "internal error"})
+		return
+	}
+	ctx.Request = ctx.Request.WithContext(service.WithDomain(ctx.Request.Context(), d))
+	ctx.Next()
+}
+
+// saveDomain godoc
+//
+//	@Summary		Register domain
+//	@Description	register a custom domain with its own namespace of keys, or change its settings
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			name	path		string			true	"Host name"
+//	@Param			request	body		domainRequest	true	"Settings"
+//	@Success		200		{object}	domainResponse
+//	@Failure		400		{object}	errorResponse
+//	@Failure		401		{object}	errorResponse
+//	@Failure		403		{object}	errorResponse
+//	@Failure		422		{object}	errorResponse
+//	@Router			/api/v1/admin/domains/{name} [put]
+func (c *Controller) saveDomain(ctx *gin.Context) {
+	var req domainRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: "invalid request"})
+		return
+	}
+
+	d, err := c.service.SaveDomain(ctx, repository.Domain{
+		Name:                strings.ToLower(ctx.Param("name")),
+		RedirectCode:        req.RedirectCode,
+		FallbackURL:         req.FallbackURL,
+		AllowedDestinations: req.AllowedDestinations,
+	})
+	if err != nil {
+		c.domainError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newDomainResponse(d))
+}
+
+// listDomains godoc
+//
+//	@Summary		List domains
+//	@Description	list the registered custom domains
+//	@Tags			admin
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{array}		domainResponse
+//	@Failure		401	{object}	errorResponse
+//	@Failure		403	{object}	errorResponse
+//	@Router			/api/v1/admin/domains [get]
+func (c *Controller) listDomains(ctx *gin.Context) {
+	domains, err := c.service.ListDomains(ctx)
+	if err != nil {
+		c.domainError(ctx, err)
+		return
+	}
+	resp := make([]domainResponse, 0, len(domains))
+	for _, d := range domains {
+		resp = append(resp, newDomainResponse(d))
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// deleteDomain godoc
+//
+//	@Summary		Remove domain
+//	@Description	unregister a custom domain; its links stay stored but cannot be resolved
+//	@Tags			admin
+//	@Security		ApiKeyAuth
+//	@Param			name	path	string	true	"Host name"
+//	@Success		204
+//	@Failure		401	{object}	errorResponse
+//	@Failure		403	{object}	errorResponse
+//	@Failure		404	{object}	errorResponse
+//	@Router			/api/v1/admin/domains/{name} [delete]
+func (c *Controller) deleteDomain(ctx *gin.Context) {
+	if err := c.service.DeleteDomain(ctx, strings.ToLower(ctx.Param("name"))); err != nil {
+		c.domainError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (c *Controller) domainError(ctx *gin.Context, err error) {
+	var policyErr *service.PolicyError
+	switch {
+	case errors.Is(err, service.ErrInvalidDomain):
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: "invalid domain"})
+	case errors.Is(err, service.ErrInvalidURL):
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: "invalid fallback url"})
+	case errors.As(err, &policyErr):
+		ctx.JSON(http.StatusUnprocessableEntity, errorResponse{Error: "destination blocked", Rule: policyErr.Rule})
+	case errors.Is(err, service.ErrUnknownDomain):
+		ctx.JSON(http.StatusNotFound, errorResponse{Error: "domain not found"})
+	default:
+		_ = ctx.Error(err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse{Error: "internal error"})
+	}
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"os"
+	"time"
+	"url-shortener/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed templates/*.html
+var embeddedTemplates embed.FS
+
+var defaultTemplates = template.Must(LoadTemplates(""))
+
+// LoadTemplates parses the built-in HTML pages. Files in dir named like a
+// built-in page (preview.html, password.html, disabled.html) replace it, so
+// a deployment can restyle pages without rebuilding.
+func LoadTemplates(dir string) (*template.Template, error) {
+	t, err := template.ParseFS(embeddedTemplates, "templates/*.html")
+	if err != nil || dir == "" {
+		return t, err
+	}
+
+	overrides := os.DirFS(dir)
+	matches, err := fs.Glob(overrides, "*.html")
+	if err != nil || len(matches) == 0 {
+		return t, err
+	}
+	return t.ParseFS(overrides, matches...)
+}
+
+type pageData struct {
+	Key       string
+	URL       string
+	CreatedAt time.Time
+	Clicks    int64
+	// Countdown is the number of seconds before the page redirects on its
+	// own. Zero leaves it to the visitor.
+	Countdown int
+	// Error is shown on forms after a failed submission.
+	Error string
+}
+
+func linkPage(link repository.Link) pageData {
+	return pageData{
+		Key:       link.Key,
+		URL:       link.URL,
+		CreatedAt: link.CreatedAt,
+		Clicks:    link.Clicks,
+		Countdown: int(link.Interstitial / time.Second),
+	}
+}
+
+func (c *Controller) render(ctx *gin.Context, status int, name string, data pageData) {
+	ctx.Header("Content-Type", "text/html; charset=utf-8")
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Status(status)
+	if err := c.templates.ExecuteTemplate(ctx.Writer, name, data); err != nil {
+		_ = ctx.Error(err)
+	}
+}
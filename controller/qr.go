@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"url-shortener/qr"
+	"url-shortener/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+var qrContentTypes = map[qr.Format]string{
+	qr.FormatPNG: "image/png",
+	qr.FormatSVG: "image/svg+xml",
+}
+
+// qrCode godoc
+//
+//	@Summary		QR code
+//	@Description	render a QR code of the full short URL
+//	@Tags			urls
+//	@Produce		png,image/svg+xml
+//	@Param			key		path		string	true	"Short URL key"
+//	@Param			format	query		string	false	"Image format"						Enums(png, svg)	default(png)
+//	@Param			size	query		int		false	"Width and height in pixels"		minimum(64)		maximum(2048)	default(256)
+//	@Param			level	query		string	false	"Error correction level"			Enums(L, M, Q, H)	default(M)
+//	@Param			margin	query		int		false	"Quiet zone in modules"				minimum(0)		maximum(16)		default(4)
+//	@Param			fg		query		string	false	"Foreground color as hex RGB(A)"	default(000000)
+//	@Param			bg		query		string	false	"Background color as hex RGB(A)"	default(ffffff)
+//	@Success		200		{file}		file	"QR code"
+//	@Success		304
+//	@Failure		400		{object}	errorResponse
+//	@Failure		404		{object}	errorResponse
+//	@Failure		410		{object}	errorResponse
+//	@Failure		451		{object}	errorResponse
+//	@Router			/api/v1/{key}/qr [get]
+func (c *Controller) qrCode(ctx *gin.Context) {
+	segment := ctx.Param("key")
+	key, signed, ok := c.linkKey(ctx, segment)
+	if !ok {
+		return
+	}
+
+	opts, err := qrOptions(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	// Protected links still get a code; it only leads to the password form.
+	// So do scheduled links, whose codes are usually printed before launch.
+	_, err = c.service.PreviewLink(ctx, key, service.Visit{Signed: signed})
+	var inactive *service.InactiveError
+	switch {
+	case err == nil, errors.Is(err, service.ErrPasswordRequired):
+	case errors.As(err, &inactive) && (!inactive.Ended || inactive.FallbackURL != ""):
+	case errors.As(err, &inactive):
+		ctx.JSON(http.StatusGone, errorResponse{Error: "link is no longer active"})
+		return
+	case errors.Is(err, service.ErrDisabled):
+		ctx.JSON(http.StatusUnavailableForLegalReasons, errorResponse{Error: "link disabled"})
+		return
+	case errors.Is(err, service.ErrExhausted):
+		ctx.JSON(http.StatusGone, errorResponse{Error: "link has no uses left"})
+		return
+	default:
+		ctx.JSON(http.StatusNotFound, errorResponse{Error: "url not found"})
+		return
+	}
+
+	// Signed links keep their signature in the code.
+	content := c.shortURL(ctx, segment)
+	sum := sha256.Sum256([]byte(content + "\n" + opts.String()))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	ctx.Header("ETag", etag)
+	ctx.Header("Cache-Control", "public, max-age=86400")
+	if etagMatches(ctx.GetHeader("If-None-Match"), etag) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := qr.Write(&buf, content, opts); err != nil {
+		_ = ctx.Error(err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse{Error: "failed to render qr code"})
+		return
+	}
+	ctx.Data(http.StatusOK, qrContentTypes[opts.Format], buf.Bytes())
+}
+
+func qrOptions(ctx *gin.Context) (qr.Options, error) {
+	opts := qr.Defaults()
+
+	if format := ctx.Query("format"); format != "" {
+		opts.Format = qr.Format(strings.ToLower(format))
+	}
+	if level := ctx.Query("level"); level != "" {
+		opts.Level = strings.ToUpper(level)
+	}
+	if size := ctx.Query("size"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			return qr.Options{}, qr.ErrInvalidSize
+		}
+		opts.Size = n
+	}
+	if margin := ctx.Query("margin"); margin != "" {
+		n, err := strconv.Atoi(margin)
+		if err != nil {
+			return qr.Options{}, qr.ErrInvalidMargin
+		}
+		opts.Margin = n
+	}
+	if fg := ctx.Query("fg"); fg != "" {
+		c, err := qr.ParseColor(fg)
+		if err != nil {
+			return qr.Options{}, err
+		}
+		opts.Foreground = c
+	}
+	if bg := ctx.Query("bg"); bg != "" {
+		c, err := qr.ParseColor(bg)
+		if err != nil {
+			return qr.Options{}, err
+		}
+		opts.Background = c
+	}
+
+	return opts, opts.Validate()
+}
+
+// shortURL is the absolute URL that resolves key. Links of a custom domain
+// are served from that domain.
+func (c *Controller) shortURL(ctx *gin.Context, key string) string {
+	base := c.baseURL
+	if d, ok := service.DomainFrom(ctx); ok {
+		scheme, _, found := strings.Cut(base, "://")
+		if !found {
+			scheme = "http"
+			if ctx.Request.TLS != nil {
+				scheme = "https"
+			}
+		}
+		base = scheme + "://" + d.Name
+	} else if base == "" {
+		scheme := "http"
+		if ctx.Request.TLS != nil {
+			scheme = "https"
+		}
+		base = scheme + "://" + ctx.Request.Host
+	}
+	return base + "/api/v1/" + key
+}
+
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == etag || candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
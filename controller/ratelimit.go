@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	"url-shortener/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimit enforces l per API key, or per client IP for anonymous callers.
+// It must run after authenticate so that only valid keys get their own budget.
+// When the limiter itself fails the request is let through.
+func rateLimit(l ratelimit.Limiter) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		res, err := l.Allow(ctx, rateLimitKey(ctx))
+		if err != nil {
+			ctx.Next()
+			return
+		}
+
+		ctx.Header("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+		ctx.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		ctx.Header("X-RateLimit-Reset", seconds(res.Reset))
+
+		if !res.Allowed {
+			ctx.Header("Retry-After", seconds(res.RetryAfter))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, errorResponse{Error: "rate limit exceeded"})
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// scanGuard refuses clients blocked for requesting too many unknown keys,
+// before anything is looked up, and records the 404s of everyone else,
+// including unknown keys sent to a domain's fallback URL. Like
+// rateLimit it lets requests through when the guard itself fails.
+func scanGuard(g *ratelimit.ScanGuard) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		client := rateLimitKey(ctx)
+		if d, err := g.Blocked(ctx, client); err == nil && d > 0 {
+			ctx.Header("Retry-After", seconds(d))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, errorResponse{Error: "too many unknown keys"})
+			return
+		}
+
+		ctx.Next()
+
+		if ctx.Writer.Status() != http.StatusNotFound && !ctx.GetBool(missKey) {
+			return
+		}
+		blocked, err := g.Miss(ctx, client)
+		if err != nil {
+			slog.WarnContext(ctx, "failed to record unknown key", "error", err)
+			return
+		}
+		if blocked {
+			slog.WarnContext(ctx, "blocked client scanning for keys", "client", client)
+		}
+	}
+}
+
+func rateLimitKey(ctx *gin.Context) string {
+	if _, ok := identityFrom(ctx); ok {
+		sum := sha256.Sum256([]byte(ctx.GetHeader(apiKeyHeader)))
+		return "key:" + hex.EncodeToString(sum[:8])
+	}
+	return "ip:" + ctx.ClientIP()
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
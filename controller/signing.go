@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"net/http"
+	"url-shortener/service"
+	"url-shortener/signing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const errSigningDisabled = "signed links are not enabled"
+
+// WithSigner enables signed links, see package signing.
+func WithSigner(s *signing.Signer) Option {
+	return func(c *Controller) {
+		c.signer = s
+	}
+}
+
+// signRequest signs every link when signatures are required. It reports
+// false for a request asking for a signed link while signing is off.
+func (c *Controller) signRequest(req *service.ShortenRequest) bool {
+	if c.signer == nil {
+		return !req.Signed
+	}
+	if c.signer.Required() {
+		req.Signed = true
+	}
+	return true
+}
+
+// linkSegment is the path segment a new link is served under.
+func (c *Controller) linkSegment(key string, signed bool) string {
+	if signed {
+		return c.signer.Sign(key)
+	}
+	return key
+}
+
+// linkKey checks the signature of a key segment before anything is looked
+// up and returns the bare key and whether it was signed. Bad signatures get
+// the same 404 as unknown keys.
+func (c *Controller) linkKey(ctx *gin.Context, segment string) (string, bool, bool) {
+	if c.signer == nil {
+		return segment, false, true
+	}
+	key, signed, err := c.signer.Verify(segment)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, errorResponse{Error: "url not found"})
+		return "", false, false
+	}
+	return key, signed, true
+}
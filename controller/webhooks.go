@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"url-shortener/service"
+	"url-shortener/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deadLetterLimit is how many dead-lettered deliveries are listed.
+const deadLetterLimit = 100
+
+type webhookRequest struct {
+	URL string `json:"url" binding:"required" example:"https://cms.example.com/hooks/links"`
+	// Events limits the subscription to these event types; empty means all.
+	Events []service.EventType `json:"events,omitempty" example:"link.created,link.deleted"`
+	// AllLinks subscribes to events of every link, not only the caller's.
+	// Admin only.
+	AllLinks bool `json:"all_links,omitempty"`
+}
+
+type webhookResponse struct {
+	ID        string              `json:"id" example:"9f86d081884c7d65"`
+	URL       string              `json:"url" example:"https://cms.example.com/hooks/links"`
+	Owner     string              `json:"owner" example:"alice"`
+	Events    []service.EventType `json:"events,omitempty" example:"link.created,link.deleted"`
+	AllLinks  bool                `json:"all_links,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+	// Secret signs the payloads and is only returned when subscribing.
+	Secret string `json:"secret,omitempty"`
+}
+
+func newWebhookResponse(sub webhook.Subscription) webhookResponse {
+	return webhookResponse{
+		ID:        sub.ID,
+		URL:       sub.URL,
+		Owner:     sub.Owner,
+		Events:    sub.Events,
+		AllLinks:  sub.All,
+		CreatedAt: sub.CreatedAt,
+	}
+}
+
+type deadLetterResponse struct {
+	ID           string        `json:"id" example:"1b4f0e9851971998"`
+	Subscription string        `json:"subscription" example:"9f86d081884c7d65"`
+	Event        service.Event `json:"event"`
+	Attempts     int           `json:"attempts" example:"8"`
+	LastError    string        `json:"last_error" example:"unexpected status 503"`
+}
+
+// createWebhook godoc
+//
+//	@Summary		Subscribe to link events
+//	@Description	register a URL that receives link events as signed POST requests
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			request	body		webhookRequest	true	"Subscription"
+//	@Success		201		{object}	webhookResponse
+//	@Failure		400		{object}	errorResponse
+//	@Failure		401		{object}	errorResponse
+//	@Failure		403		{object}	errorResponse
+//	@Router			/api/v1/webhooks [post]
+func (c *Controller) createWebhook(ctx *gin.Context) {
+	var req webhookRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: "invalid request"})
+		return
+	}
+
+	id, _ := identityFrom(ctx)
+	if req.AllLinks && !id.Admin {
+		ctx.JSON(http.StatusForbidden, errorResponse{Error: "admin access required"})
+		return
+	}
+
+	sub, err := c.webhooks.Subscribe(ctx, webhook.Subscription{
+		Owner:  id.Owner,
+		URL:    req.URL,
+		Events: req.Events,
+		All:    req.AllLinks,
+	})
+	switch {
+	case errors.Is(err, webhook.ErrInvalidURL), errors.Is(err, webhook.ErrInvalidEvent):
+		ctx.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		_ = ctx.Error(err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse{Error: "failed to subscribe"})
+		return
+	}
+
+	resp := newWebhookResponse(sub)
+	resp.Secret = sub.Secret
+	ctx.JSON(http.StatusCreated, resp)
+}
+
+// listWebhooks godoc
+//
+//	@Summary		List webhook subscriptions
+//	@Description	list the caller's subscriptions, or all of them for admins
+//	@Tags			webhooks
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{array}		webhookResponse
+//	@Failure		401	{object}	errorResponse
+//	@Router			/api/v1/webhooks [get]
+func (c *Controller) listWebhooks(ctx *gin.Context) {
+	id, _ := identityFrom(ctx)
+	owner := id.Owner
+	if id.Admin {
+		owner = ""
+	}
+
+	subs, err := c.webhooks.Subscriptions(ctx, owner)
+	if err != nil {
+		_ = ctx.Error(err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse{Error: "failed to list webhooks"})
+		return
+	}
+
+	resp := make([]webhookResponse, len(subs))
+	for i, sub := range subs {
+		resp[i] = newWebhookResponse(sub)
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// deleteWebhook godoc
+//
+//	@Summary		Unsubscribe webhook
+//	@Description	delete a subscription of the caller; queued deliveries are dropped
+//	@Tags			webhooks
+//	@Security		ApiKeyAuth
+//	@Param			id	path	string	true	"Subscription ID"
+//	@Success		204
+//	@Failure		401	{object}	errorResponse
+//	@Failure		404	{object}	errorResponse
+//	@Router			/api/v1/webhooks/{id} [delete]
+func (c *Controller) deleteWebhook(ctx *gin.Context) {
+	id, _ := identityFrom(ctx)
+	owner := id.Owner
+	if id.Admin {
+		owner = ""
+	}
+
+	err := c.webhooks.Unsubscribe(ctx, owner, ctx.Param("id"))
+	switch {
+	case errors.Is(err, webhook.ErrNotFound):
+		ctx.JSON(http.StatusNotFound, errorResponse{Error: "webhook not found"})
+		return
+	case err != nil:
+		_ = ctx.Error(err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse{Error: "failed to delete webhook"})
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// listDeadWebhooks godoc
+//
+//	@Summary		List dead-lettered deliveries
+//	@Description	list the most recent webhook deliveries that failed every attempt
+//	@Tags			admin
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{array}		deadLetterResponse
+//	@Failure		401	{object}	errorResponse
+//	@Failure		403	{object}	errorResponse
+//	@Router			/api/v1/admin/webhooks/dead [get]
+func (c *Controller) listDeadWebhooks(ctx *gin.Context) {
+	deliveries, err := c.webhooks.DeadLetters(ctx, deadLetterLimit)
+	if err != nil {
+		_ = ctx.Error(err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse{Error: "failed to list dead letters"})
+		return
+	}
+
+	resp := make([]deadLetterResponse, len(deliveries))
+	for i, d := range deliveries {
+		resp[i] = deadLetterResponse{
+			ID:           d.ID,
+			Subscription: d.Subscription,
+			Event:        d.Event,
+			Attempts:     d.Attempts,
+			LastError:    d.LastError,
+		}
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
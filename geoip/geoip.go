@@ -0,0 +1,42 @@
+// Package geoip resolves visitor countries from a local MaxMind database,
+// such as GeoLite2-Country or GeoLite2-City. Lookups never leave the host.
+package geoip
+
+import (
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+type DB struct {
+	reader *maxminddb.Reader
+}
+
+// Open memory maps the database file at path.
+func Open(path string) (*DB, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{reader: reader}, nil
+}
+
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// Country returns the ISO 3166-1 alpha-2 code of the country ip is located
+// in, or "" if the database does not know it.
+func (db *DB) Country(ip net.IP) (string, error) {
+	var rec record
+	if err := db.reader.Lookup(ip, &rec); err != nil {
+		return "", err
+	}
+	return rec.Country.ISOCode, nil
+}
+
+func (db *DB) Close() error {
+	return db.reader.Close()
+}
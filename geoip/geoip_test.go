@@ -0,0 +1,21 @@
+package geoip
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpen_Invalid(t *testing.T) {
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.mmdb")); err == nil {
+		t.Error("expected an error for a missing database")
+	}
+
+	path := filepath.Join(t.TempDir(), "garbage.mmdb")
+	if err := os.WriteFile(path, []byte("not a database"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Error("expected an error for a file that is not a MaxMind database")
+	}
+}
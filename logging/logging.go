@@ -0,0 +1,121 @@
+// Package logging builds the structured slog logger shared by the server and
+// its packages. Records logged with a context carry the request ID and trace
+// stored in it, and URLs under well-known keys have their query values
+// redacted.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatText Format = "text"
+)
+
+type Config struct {
+	// Level is debug, info, warn or error.
+	Level  string `yaml:"level"`
+	Format Format `yaml:"format"`
+}
+
+// urlKeys are attribute keys whose values are URLs. Their query strings can
+// carry tokens, so only the parameter names are logged.
+var urlKeys = map[string]bool{
+	"url":          true,
+	"path":         true,
+	"destination":  true,
+	"fallback_url": true,
+}
+
+// New returns a logger writing to w as configured.
+func New(cfg Config, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", cfg.Level)
+	}
+
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+	var handler slog.Handler
+	switch cfg.Format {
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	case FormatText:
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q", cfg.Format)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID stored in ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler adds the request ID and trace of the record's context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", span.TraceID().String()),
+			slog.String("span_id", span.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if urlKeys[a.Key] && a.Value.Kind() == slog.KindString {
+		a.Value = slog.StringValue(RedactURL(a.Value.String()))
+	}
+	return a
+}
+
+// RedactURL replaces the values in the query string of raw. Strings that do
+// not parse as URLs lose everything after the first "?".
+func RedactURL(raw string) string {
+	base, query, ok := strings.Cut(raw, "?")
+	if !ok {
+		return raw
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return base + "?REDACTED"
+	}
+	for key := range values {
+		values[key] = []string{"REDACTED"}
+	}
+	return base + "?" + values.Encode()
+}
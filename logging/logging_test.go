@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNew_Invalid(t *testing.T) {
+	if _, err := New(Config{Level: "loud", Format: FormatJSON}, &bytes.Buffer{}); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if _, err := New(Config{Level: "info", Format: "xml"}, &bytes.Buffer{}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestNew_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(Config{Level: "info", Format: FormatJSON}, &buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	logger.DebugContext(ctx, "hidden")
+	logger.With("component", "test").InfoContext(ctx, "visited", "url", "https://example.com/a?token=secret&b=1", "key", "abc")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected debug to be filtered, got %d lines: %s", len(lines), buf.String())
+	}
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("expected JSON, got %s", lines[0])
+	}
+	if record["request_id"] != "req-1" || record["component"] != "test" || record["key"] != "abc" {
+		t.Errorf("unexpected record: %v", record)
+	}
+	if record["url"] != "https://example.com/a?b=REDACTED&token=REDACTED" {
+		t.Errorf("expected the query to be redacted, got %v", record["url"])
+	}
+}
+
+func TestNew_Trace(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(Config{Level: "info", Format: FormatJSON}, &buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	span := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+	logger.InfoContext(trace.ContextWithSpanContext(context.Background(), span), "traced")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected JSON, got %s", buf.String())
+	}
+	if record["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" || record["span_id"] != "00f067aa0ba902b7" {
+		t.Errorf("unexpected record: %v", record)
+	}
+}
+
+func TestNew_Text(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(Config{Level: "warn", Format: FormatText}, &buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	logger.Warn("careful", slog.Int("n", 3))
+
+	if !strings.Contains(buf.String(), "level=WARN msg=careful n=3") {
+		t.Errorf("unexpected output: %s", buf.String())
+	}
+	if strings.Contains(buf.String(), "request_id") {
+		t.Errorf("expected no request ID without one in the context: %s", buf.String())
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/":  "https://example.com/",
+		"/api/v1/abc?preview=1": "/api/v1/abc?preview=REDACTED",
+		"/api/v1/abc?a=1&a=2":   "/api/v1/abc?a=REDACTED",
+		"/api/v1/abc?bad=%zz":   "/api/v1/abc?REDACTED",
+		"https://example.com/?": "https://example.com/?",
+	}
+	for raw, want := range tests {
+		if got := RedactURL(raw); got != want {
+			t.Errorf("RedactURL(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
@@ -2,11 +2,23 @@ package main
 
 import (
 	"context"
-	"time"
+	"expvar"
+	"flag"
+	"log/slog"
+	"os"
+	"url-shortener/clickstream"
+	"url-shortener/config"
 	"url-shortener/controller"
 	_ "url-shortener/docs"
+	"url-shortener/geoip"
+	"url-shortener/logging"
+	"url-shortener/policy"
+	"url-shortener/ratelimit"
 	"url-shortener/repository"
 	"url-shortener/service"
+	"url-shortener/signing"
+	"url-shortener/tracing"
+	"url-shortener/webhook"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -30,29 +42,177 @@ import (
 
 //	@securityDefinitions.basic	BasicAuth
 
+//	@securityDefinitions.apikey	ApiKeyAuth
+//	@in							header
+//	@name						X-API-Key
+
 // @externalDocs.description	OpenAPI
 // @externalDocs.url			https://swagger.io/resources/open-api/
 func main() {
-	cfg := repository.Config{
-		Addr:        "localhost:6379",
-		Password:    "test1234",
-		User:        "default",
-		DB:          0,
-		MaxRetries:  5,
-		DialTimeout: 10 * time.Second,
-		Timeout:     5 * time.Second,
+	configPath := flag.String("config", "config.yaml", "path to the YAML config file")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fatal("failed to load config", err)
+	}
+
+	logger, err := logging.New(cfg.Log, os.Stderr)
+	if err != nil {
+		fatal("failed to set up logging", err)
+	}
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("failed to set up tracing", err)
 	}
+	defer shutdownTracing(context.Background())
 
-	rdb, _ := repository.NewClient(context.Background(), cfg)
+	rdb, err := repository.NewClient(context.Background(), cfg.Redis)
+	if err != nil {
+		fatal("failed to connect to redis", err)
+	}
+	keys := repository.NewKeyspace(cfg.Redis.Prefix)
 
-	repo := repository.NewRedisRepository(rdb)
-	svc := service.NewShortenerService(repo)
-	h := controller.NewController(svc)
+	repo := repository.NewTracedRepository(repository.NewRedisRepository(rdb,
+		repository.WithRetention(cfg.Timeseries),
+		repository.WithKeyPrefix(cfg.Redis.Prefix),
+	))
 
-	router := gin.Default()
+	destinations, err := policy.New(cfg.Policy, repo)
+	if err != nil {
+		fatal("failed to load destination policy", err)
+	}
+
+	svcOpts := []service.Option{
+		service.WithPolicy(destinations),
+		service.WithPasswordAttempts(cfg.Passwords.MaxAttempts, cfg.Passwords.Window),
+	}
+	if cfg.Keys.Random {
+		svcOpts = append(svcOpts, service.WithRandomKeys(cfg.Keys.Length))
+	}
+	if cfg.GeoIP.Database != "" {
+		countries, err := geoip.Open(cfg.GeoIP.Database)
+		if err != nil {
+			fatal("failed to open GeoIP database", err)
+		}
+		defer countries.Close()
+		svcOpts = append(svcOpts, service.WithCountryResolver(countries))
+	}
+
+	var webhooks *webhook.Dispatcher
+	if wh := cfg.Webhooks; wh.Enabled {
+		webhooks = webhook.NewDispatcher(webhook.NewRedisStore(rdb, keys),
+			webhook.WithTimeout(wh.Timeout),
+			webhook.WithPrivateAddresses(wh.AllowPrivateAddresses),
+			webhook.WithRetries(wh.MaxAttempts, wh.Backoff, wh.MaxBackoff),
+		)
+		svcOpts = append(svcOpts,
+			service.WithEventHandler(webhooks),
+			service.WithClickMilestones(wh.Milestones...),
+		)
+	}
+
+	svc := service.NewTracedService(service.NewShortenerService(repo, svcOpts...))
+
+	switch flag.Arg(0) {
+	case "export":
+		if err := runExport(context.Background(), svc, flag.Args()[1:]); err != nil {
+			fatal("export failed", err)
+		}
+		return
+	case "import":
+		if err := runImport(context.Background(), svc, flag.Args()[1:]); err != nil {
+			fatal("import failed", err)
+		}
+		return
+	}
+
+	templates, err := controller.LoadTemplates(cfg.HTTP.TemplatesDir)
+	if err != nil {
+		fatal("failed to load templates", err)
+	}
+
+	opts := []controller.Option{
+		controller.WithMaxBatchSize(cfg.Batch.MaxSize),
+		controller.WithTemplates(templates),
+		controller.WithBaseURL(cfg.HTTP.PublicURL),
+	}
+	if rl := cfg.RateLimit; rl.Enabled {
+		if rl.Create.Limit > 0 {
+			opts = append(opts, controller.WithCreateLimiter(ratelimit.New(rl, "create", rl.Create, rdb, keys)))
+		}
+		if rl.Redirect.Limit > 0 {
+			opts = append(opts, controller.WithRedirectLimiter(ratelimit.New(rl, "redirect", rl.Redirect, rdb, keys)))
+		}
+	}
+	if rl := cfg.RateLimit; rl.Scan.Enabled {
+		guard := ratelimit.NewScanGuard(rl, rdb, keys)
+		expvar.Publish("scan_guard", expvar.Func(func() any { return guard.Stats() }))
+		opts = append(opts, controller.WithScanGuard(guard))
+	}
+	if rl := cfg.RateLimit; rl.Auth.Enabled {
+		guard := ratelimit.NewAuthGuard(rl, rdb, keys)
+		expvar.Publish("auth_guard", expvar.Func(func() any { return guard.Stats() }))
+		opts = append(opts, controller.WithAuthGuard(guard))
+	}
+	if webhooks != nil {
+		opts = append(opts, controller.WithWebhooks(webhooks))
+	}
+	if cc := cfg.Clicks; cc.Enabled {
+		clicks, err := clickstream.NewBuffered(repository.NewClickStream(rdb, keys.Key(cc.Stream), cc.MaxLen), cc)
+		if err != nil {
+			fatal("failed to set up click stream", err)
+		}
+		go clicks.Run(context.Background())
+		opts = append(opts, controller.WithClickPublisher(clicks))
+	}
+	if sc := cfg.Signing; sc.Enabled || sc.Required {
+		signer, err := signing.New(sc)
+		if err != nil {
+			fatal("failed to set up link signing", err)
+		}
+		opts = append(opts, controller.WithSigner(signer))
+	}
+	if cfg.Domains.Enabled {
+		opts = append(opts, controller.WithDomains(cfg.Domains.DefaultHosts...))
+	}
+	h := controller.NewController(svc, opts...)
+
+	if cfg.Policy.RecheckInterval > 0 {
+		go recheckLinks(context.Background(), svc, cfg.Policy.RecheckInterval)
+	}
+	if webhooks != nil {
+		go webhooks.Run(context.Background(), cfg.Webhooks.PollInterval)
+		if cfg.Webhooks.ExpiryInterval > 0 {
+			go purgeExpired(context.Background(), svc, cfg.Webhooks.ExpiryInterval)
+		}
+	}
+
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.HTTP.TrustedProxies); err != nil {
+		fatal("invalid trusted proxies", err)
+	}
+	router.Use(
+		controller.Tracing(cfg.Tracing.ServiceName),
+		controller.RequestID(),
+		controller.AccessLog(logger),
+		gin.Recovery(),
+	)
 	h.RegisterRoutes(router)
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	router.Run(":8080")
+	if err := router.Run(cfg.HTTP.Addr); err != nil {
+		fatal("server stopped", err)
+	}
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,102 @@
+package policy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"url-shortener/service"
+)
+
+// domainRules is the parsed form of a domain list file. Each line is
+// "block <domain>" or "allow <domain>"; blank lines and lines starting with
+// '#' are ignored. A domain also matches all of its subdomains. As soon as
+// one allow rule exists, only allowed domains may be shortened.
+type domainRules struct {
+	block map[string]bool
+	allow map[string]bool
+}
+
+// DomainList blocks or allows destinations by host name, reading its rules
+// from a file that is reloaded when it changes.
+type DomainList struct {
+	rules *reloader[domainRules]
+}
+
+func NewDomainList(path string, reloadInterval time.Duration) (*DomainList, error) {
+	rules, err := newReloader(path, reloadInterval, parseDomainRules)
+	if err != nil {
+		return nil, err
+	}
+	return &DomainList{rules: rules}, nil
+}
+
+func (d *DomainList) Check(_ context.Context, u *url.URL) error {
+	rules := d.rules.get()
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	for _, domain := range parentDomains(host) {
+		if rules.block[domain] {
+			return &service.PolicyError{Rule: "blocklist:" + domain}
+		}
+	}
+
+	if len(rules.allow) == 0 {
+		return nil
+	}
+	for _, domain := range parentDomains(host) {
+		if rules.allow[domain] {
+			return nil
+		}
+	}
+	return &service.PolicyError{Rule: "allowlist"}
+}
+
+// parentDomains returns host and every domain it is a subdomain of,
+// for example a.b.com, b.com and com.
+func parentDomains(host string) []string {
+	var domains []string
+	for host != "" {
+		domains = append(domains, host)
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			break
+		}
+		host = host[i+1:]
+	}
+	return domains
+}
+
+func parseDomainRules(f *os.File) (domainRules, error) {
+	rules := domainRules{block: make(map[string]bool), allow: make(map[string]bool)}
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return domainRules{}, fmt.Errorf("%s:%d: expected \"block <domain>\" or \"allow <domain>\"", f.Name(), line)
+		}
+		domain := strings.TrimSuffix(strings.ToLower(fields[1]), ".")
+
+		switch fields[0] {
+		case "block":
+			rules.block[domain] = true
+		case "allow":
+			rules.allow[domain] = true
+		default:
+			return domainRules{}, fmt.Errorf("%s:%d: unknown action %q", f.Name(), line, fields[0])
+		}
+	}
+
+	return rules, scanner.Err()
+}
@@ -0,0 +1,62 @@
+package policy
+
+import (
+	"context"
+	"net/url"
+	"time"
+	"url-shortener/service"
+)
+
+type Config struct {
+	// SelfHosts are the hosts the shortener is reachable on. Links pointing
+	// at them, or at a registered custom domain, are rejected as redirect
+	// loops.
+	SelfHosts []string `yaml:"self_hosts"`
+	// DomainsFile is a block/allow list, see DomainList.
+	DomainsFile string `yaml:"domains_file"`
+	// SafeBrowsingFile is a Safe Browsing update response, see HashPrefixList.
+	SafeBrowsingFile string `yaml:"safe_browsing_file"`
+	// ReloadInterval is how often the files are checked for changes. Zero
+	// disables reloading.
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+	// RecheckInterval is how often stored links are run through the policy
+	// again. Zero disables rechecking.
+	RecheckInterval time.Duration `yaml:"recheck_interval"`
+}
+
+// Chain applies policies in order and returns the first rejection.
+type Chain []service.DestinationPolicy
+
+// New builds the chain described by cfg. Policies without configuration are
+// left out. domains, if not nil, holds the custom domains that are rejected
+// as redirect loops along with cfg.SelfHosts.
+func New(cfg Config, domains DomainStore) (Chain, error) {
+	var chain Chain
+	if len(cfg.SelfHosts) > 0 || domains != nil {
+		chain = append(chain, NewSelfHost(domains, cfg.SelfHosts...))
+	}
+	if cfg.DomainsFile != "" {
+		domains, err := NewDomainList(cfg.DomainsFile, cfg.ReloadInterval)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, domains)
+	}
+	if cfg.SafeBrowsingFile != "" {
+		prefixes, err := NewHashPrefixList(cfg.SafeBrowsingFile, cfg.ReloadInterval)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, prefixes)
+	}
+	return chain, nil
+}
+
+func (c Chain) Check(ctx context.Context, u *url.URL) error {
+	for _, p := range c {
+		if err := p.Check(ctx, u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package policy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"url-shortener/repository"
+	"url-shortener/service"
+)
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func writeFile(t *testing.T, path, data string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ruleOf(err error) string {
+	var policyErr *service.PolicyError
+	if errors.As(err, &policyErr) {
+		return policyErr.Rule
+	}
+	return ""
+}
+
+func TestDomainList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	writeFile(t, path, "# phishing\nblock evil.example\n\nblock Bad.Example.\n", time.Now())
+
+	list, err := NewDomainList(path, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		url  string
+		rule string
+	}{
+		{"https://evil.example/login", "blocklist:evil.example"},
+		{"https://login.evil.example/", "blocklist:evil.example"},
+		{"https://BAD.example:8443/", "blocklist:bad.example"},
+		{"https://notevil.example/", ""},
+		{"https://example.com/", ""},
+	}
+	for _, tt := range tests {
+		if got := ruleOf(list.Check(context.Background(), mustParse(t, tt.url))); got != tt.rule {
+			t.Errorf("Check(%s) rule = %q, want %q", tt.url, got, tt.rule)
+		}
+	}
+}
+
+func TestDomainList_Allowlist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	writeFile(t, path, "allow example.com\nblock private.example.com\n", time.Now())
+
+	list, err := NewDomainList(path, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := list.Check(ctx, mustParse(t, "https://docs.example.com/")); err != nil {
+		t.Errorf("expected allowed subdomain to pass, got %v", err)
+	}
+	if got := ruleOf(list.Check(ctx, mustParse(t, "https://private.example.com/"))); got != "blocklist:private.example.com" {
+		t.Errorf("expected block to win over allow, got %q", got)
+	}
+	if got := ruleOf(list.Check(ctx, mustParse(t, "https://example.org/"))); got != "allowlist" {
+		t.Errorf("expected unlisted domain to be rejected, got %q", got)
+	}
+}
+
+func TestDomainList_InvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	writeFile(t, path, "deny evil.example\n", time.Now())
+
+	if _, err := NewDomainList(path, 0); err == nil {
+		t.Error("expected an error for an unknown action")
+	}
+}
+
+func TestDomainList_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	start := time.Now().Add(-time.Hour)
+	writeFile(t, path, "block old.example\n", start)
+
+	list, err := NewDomainList(path, time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := start
+	list.rules.now = func() time.Time { return now }
+	list.rules.checked = now
+
+	ctx := context.Background()
+	writeFile(t, path, "block new.example\n", start.Add(time.Second))
+
+	// The file is not looked at again before the interval has passed.
+	if err := list.Check(ctx, mustParse(t, "https://old.example/")); err == nil {
+		t.Error("expected the old rules to apply within the interval")
+	}
+
+	now = now.Add(time.Minute)
+	if err := list.Check(ctx, mustParse(t, "https://old.example/")); err != nil {
+		t.Errorf("expected old rule to be gone after reload, got %v", err)
+	}
+	if err := list.Check(ctx, mustParse(t, "https://new.example/")); err == nil {
+		t.Error("expected new rule to apply after reload")
+	}
+
+	// A broken file keeps the last good rules.
+	writeFile(t, path, "nonsense\n", start.Add(2*time.Second))
+	now = now.Add(time.Minute)
+	if err := list.Check(ctx, mustParse(t, "https://new.example/")); err == nil {
+		t.Error("expected last good rules to stay in effect")
+	}
+}
+
+func TestSelfHost(t *testing.T) {
+	self := NewSelfHost(nil, "sho.rt", "localhost:8080")
+	ctx := context.Background()
+
+	tests := []struct {
+		url     string
+		blocked bool
+	}{
+		{"https://sho.rt/abc", true},
+		{"http://SHO.RT:8080/abc", true},
+		{"http://localhost:8080/abc", true},
+		{"http://localhost:9090/abc", false},
+		{"https://example.com/", false},
+	}
+	for _, tt := range tests {
+		err := self.Check(ctx, mustParse(t, tt.url))
+		if blocked := ruleOf(err) == "redirect-loop"; blocked != tt.blocked {
+			t.Errorf("Check(%s) = %v, want blocked %v", tt.url, err, tt.blocked)
+		}
+	}
+
+	port := NewSelfHost(nil, "sho.rt:443")
+	if err := port.Check(ctx, mustParse(t, "https://sho.rt/abc")); err == nil {
+		t.Error("expected default port to match a host configured with :443")
+	}
+}
+
+type domainMap map[string]error
+
+func (m domainMap) GetDomain(ctx context.Context, name string) (repository.Domain, error) {
+	err, ok := m[name]
+	if !ok {
+		return repository.Domain{}, repository.ErrNotFound
+	}
+	return repository.Domain{Name: name}, err
+}
+
+func TestSelfHost_CustomDomains(t *testing.T) {
+	lookupErr := errors.New("connection refused")
+	self := NewSelfHost(domainMap{"brand.link": nil, "broken.link": lookupErr}, "sho.rt")
+	ctx := context.Background()
+
+	if got := ruleOf(self.Check(ctx, mustParse(t, "https://BRAND.link:8443/abc"))); got != "redirect-loop" {
+		t.Errorf("expected a registered domain to be a redirect loop, got %q", got)
+	}
+	if err := self.Check(ctx, mustParse(t, "https://example.com/")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := self.Check(ctx, mustParse(t, "https://broken.link/")); !errors.Is(err, lookupErr) {
+		t.Errorf("expected the lookup error, got %v", err)
+	}
+}
+
+// prefixFile builds a Safe Browsing update file holding 4-byte prefixes of
+// the given expressions.
+func prefixFile(t *testing.T, threatType string, checksumOverride string, exprs ...string) string {
+	t.Helper()
+	var raw []byte
+	var prefixes []string
+	for _, expr := range exprs {
+		sum := sha256.Sum256([]byte(expr))
+		raw = append(raw, sum[:4]...)
+		prefixes = append(prefixes, string(sum[:4]))
+	}
+	sum := checksum(prefixes)
+	if checksumOverride != "" {
+		sum = checksumOverride
+	}
+
+	file := map[string]any{
+		"listUpdateResponses": []map[string]any{{
+			"threatType":   threatType,
+			"responseType": "FULL_UPDATE",
+			"additions": []map[string]any{{
+				"compressionType": "RAW",
+				"rawHashes": map[string]any{
+					"prefixSize": 4,
+					"rawHashes":  base64.StdEncoding.EncodeToString(raw),
+				},
+			}},
+			"checksum": map[string]string{"sha256": sum},
+		}},
+	}
+	data, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "safebrowsing.json")
+	writeFile(t, path, string(data), time.Now())
+	return path
+}
+
+func TestHashPrefixList(t *testing.T) {
+	path := prefixFile(t, "SOCIAL_ENGINEERING", "", "evil.example/", "phish.example/login/")
+
+	list, err := NewHashPrefixList(path, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		url     string
+		blocked bool
+	}{
+		{"https://evil.example/", true},
+		{"https://www.evil.example/some/page?x=1", true},
+		{"http://phish.example/login/form.html", true},
+		{"http://phish.example/", false},
+		{"https://example.com/", false},
+	}
+	for _, tt := range tests {
+		err := list.Check(context.Background(), mustParse(t, tt.url))
+		if blocked := ruleOf(err) == "safe-browsing:social_engineering"; blocked != tt.blocked {
+			t.Errorf("Check(%s) = %v, want blocked %v", tt.url, err, tt.blocked)
+		}
+	}
+}
+
+func TestHashPrefixList_ChecksumMismatch(t *testing.T) {
+	path := prefixFile(t, "MALWARE", base64.StdEncoding.EncodeToString(make([]byte, 32)), "evil.example/")
+
+	if _, err := NewHashPrefixList(path, 0); err == nil {
+		t.Error("expected a checksum error")
+	}
+}
+
+func TestChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	writeFile(t, path, "block evil.example\n", time.Now())
+
+	chain, err := New(Config{SelfHosts: []string{"sho.rt"}, DomainsFile: path}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(chain) != 2 {
+		t.Fatalf("expected two policies, got %d", len(chain))
+	}
+
+	ctx := context.Background()
+	if got := ruleOf(chain.Check(ctx, mustParse(t, "https://sho.rt/x"))); got != "redirect-loop" {
+		t.Errorf("unexpected rule %q", got)
+	}
+	if got := ruleOf(chain.Check(ctx, mustParse(t, "https://evil.example/"))); got != "blocklist:evil.example" {
+		t.Errorf("unexpected rule %q", got)
+	}
+	if err := chain.Check(ctx, mustParse(t, "https://example.com/")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := New(Config{DomainsFile: filepath.Join(t.TempDir(), "missing")}, nil); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
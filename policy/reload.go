@@ -0,0 +1,90 @@
+package policy
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// reloader keeps the parsed contents of a file and re-parses it when its
+// modification time changes. The file is looked at no more than once per
+// interval, from whichever caller happens to need the data, so no goroutine
+// has to be managed.
+type reloader[T any] struct {
+	path     string
+	interval time.Duration
+	parse    func(*os.File) (T, error)
+	now      func() time.Time
+
+	value   atomic.Pointer[T]
+	mu      sync.Mutex
+	modTime time.Time
+	checked time.Time
+}
+
+func newReloader[T any](path string, interval time.Duration, parse func(*os.File) (T, error)) (*reloader[T], error) {
+	r := &reloader[T]{path: path, interval: interval, parse: parse, now: time.Now}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *reloader[T]) get() T {
+	r.maybeReload()
+	return *r.value.Load()
+}
+
+func (r *reloader[T]) maybeReload() {
+	if r.interval <= 0 {
+		return
+	}
+	if !r.mu.TryLock() {
+		// Another caller is already checking; use the current value.
+		return
+	}
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if now.Sub(r.checked) < r.interval {
+		return
+	}
+	r.checked = now
+
+	info, err := os.Stat(r.path)
+	if err != nil || info.ModTime().Equal(r.modTime) {
+		return
+	}
+	// A file that fails to parse keeps the last good version in effect.
+	_ = r.loadLocked()
+}
+
+func (r *reloader[T]) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.loadLocked()
+}
+
+func (r *reloader[T]) loadLocked() error {
+	f, err := os.Open(r.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	v, err := r.parse(f)
+	if err != nil {
+		return err
+	}
+
+	r.value.Store(&v)
+	r.modTime = info.ModTime()
+	r.checked = r.now()
+	return nil
+}
@@ -0,0 +1,220 @@
+package policy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"url-shortener/service"
+)
+
+// HashPrefixList checks destinations against a local copy of Safe Browsing
+// hash prefix lists. The file is the JSON body returned by the Safe Browsing
+// v4 threatListUpdates:fetch method, holding full updates with RAW
+// compression, and is reloaded when it changes.
+//
+// Without the full-hash lookup that the online API offers, any prefix match
+// is treated as a hit. This errs towards blocking, which is the right side to
+// err on for a public shortener.
+type HashPrefixList struct {
+	lists *reloader[prefixLists]
+}
+
+type prefixLists []prefixList
+
+type prefixList struct {
+	threatType string
+	sizes      []int
+	prefixes   map[string]bool
+}
+
+func NewHashPrefixList(path string, reloadInterval time.Duration) (*HashPrefixList, error) {
+	lists, err := newReloader(path, reloadInterval, parsePrefixLists)
+	if err != nil {
+		return nil, err
+	}
+	return &HashPrefixList{lists: lists}, nil
+}
+
+func (h *HashPrefixList) Check(_ context.Context, u *url.URL) error {
+	lists := h.lists.get()
+	if len(lists) == 0 {
+		return nil
+	}
+
+	for _, expr := range urlExpressions(u) {
+		sum := sha256.Sum256([]byte(expr))
+		for _, list := range lists {
+			for _, size := range list.sizes {
+				if list.prefixes[string(sum[:size])] {
+					return &service.PolicyError{Rule: "safe-browsing:" + strings.ToLower(list.threatType)}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+type updateFile struct {
+	ListUpdateResponses []struct {
+		ThreatType   string `json:"threatType"`
+		ResponseType string `json:"responseType"`
+		Additions    []struct {
+			CompressionType string `json:"compressionType"`
+			RawHashes       struct {
+				PrefixSize int    `json:"prefixSize"`
+				RawHashes  string `json:"rawHashes"`
+			} `json:"rawHashes"`
+		} `json:"additions"`
+		Checksum struct {
+			SHA256 string `json:"sha256"`
+		} `json:"checksum"`
+	} `json:"listUpdateResponses"`
+}
+
+func parsePrefixLists(f *os.File) (prefixLists, error) {
+	var file updateFile
+	if err := json.NewDecoder(f).Decode(&file); err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name(), err)
+	}
+
+	lists := make(prefixLists, 0, len(file.ListUpdateResponses))
+	for _, resp := range file.ListUpdateResponses {
+		if resp.ResponseType != "" && resp.ResponseType != "FULL_UPDATE" {
+			return nil, fmt.Errorf("%s: %s list is a %s, only full updates are supported", f.Name(), resp.ThreatType, resp.ResponseType)
+		}
+
+		list := prefixList{threatType: resp.ThreatType, prefixes: make(map[string]bool)}
+		var all []string
+		for _, add := range resp.Additions {
+			if add.CompressionType != "" && add.CompressionType != "RAW" {
+				return nil, fmt.Errorf("%s: %s compression is not supported", f.Name(), add.CompressionType)
+			}
+
+			size := add.RawHashes.PrefixSize
+			raw, err := base64.StdEncoding.DecodeString(add.RawHashes.RawHashes)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Name(), err)
+			}
+			if size < 4 || size > sha256.Size || len(raw)%size != 0 {
+				return nil, fmt.Errorf("%s: invalid prefix size %d", f.Name(), size)
+			}
+
+			for i := 0; i < len(raw); i += size {
+				prefix := string(raw[i : i+size])
+				list.prefixes[prefix] = true
+				all = append(all, prefix)
+			}
+			list.sizes = appendSize(list.sizes, size)
+		}
+
+		if want := resp.Checksum.SHA256; want != "" {
+			if got := checksum(all); got != want {
+				return nil, fmt.Errorf("%s: checksum mismatch for %s list", f.Name(), resp.ThreatType)
+			}
+		}
+		lists = append(lists, list)
+	}
+
+	return lists, nil
+}
+
+func appendSize(sizes []int, size int) []int {
+	for _, s := range sizes {
+		if s == size {
+			return sizes
+		}
+	}
+	return append(sizes, size)
+}
+
+// checksum is the base64 SHA-256 of the lexicographically sorted prefixes,
+// as sent along with every Safe Browsing list update.
+func checksum(prefixes []string) string {
+	sorted := append([]string(nil), prefixes...)
+	sort.Strings(sorted)
+
+	h := sha256.New()
+	for _, p := range sorted {
+		h.Write([]byte(p))
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// urlExpressions returns the host suffix and path prefix combinations that
+// Safe Browsing hashes for a URL, after a simplified canonicalization.
+func urlExpressions(u *url.URL) []string {
+	host := strings.ToLower(strings.Trim(u.Hostname(), "."))
+	for strings.Contains(host, "..") {
+		host = strings.ReplaceAll(host, "..", ".")
+	}
+
+	var hosts []string
+	hosts = append(hosts, host)
+	if net.ParseIP(host) == nil {
+		labels := strings.Split(host, ".")
+		// Up to four suffixes built from the last five labels, skipping the
+		// top-level domain on its own.
+		start := len(labels) - 5
+		if start < 1 {
+			start = 1
+		}
+		for i := start; i < len(labels)-1; i++ {
+			hosts = append(hosts, strings.Join(labels[i:], "."))
+		}
+	}
+
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	trailing := strings.HasSuffix(p, "/")
+	p = path.Clean(p)
+	if trailing && p != "/" {
+		p += "/"
+	}
+
+	var paths []string
+	if u.RawQuery != "" {
+		paths = append(paths, p+"?"+u.RawQuery)
+	}
+	paths = append(paths, p)
+
+	// Up to four prefixes starting at the root, each ending in a slash.
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	prefix := "/"
+	for i := 0; i < len(segments) && len(paths) < 6; i++ {
+		if prefix != p {
+			paths = appendUnique(paths, prefix)
+		}
+		if segments[i] == "" {
+			break
+		}
+		prefix += segments[i] + "/"
+	}
+
+	exprs := make([]string, 0, len(hosts)*len(paths))
+	for _, h := range hosts {
+		for _, p := range paths {
+			exprs = append(exprs, h+p)
+		}
+	}
+	return exprs
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
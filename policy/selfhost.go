@@ -0,0 +1,65 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"url-shortener/repository"
+	"url-shortener/service"
+)
+
+// DomainStore looks up the custom domains short links are served from.
+type DomainStore interface {
+	GetDomain(ctx context.Context, name string) (repository.Domain, error)
+}
+
+// SelfHost rejects links that point back at the shortener, which would make
+// the short link redirect to itself or to another short link.
+type SelfHost struct {
+	hosts   map[string]bool
+	domains DomainStore
+}
+
+// NewSelfHost takes the hosts the shortener is served from. A host given
+// with a port only matches that port; without one it matches any port.
+// Custom domains registered in domains, if not nil, count as self hosts
+// too, on any port.
+func NewSelfHost(domains DomainStore, hosts ...string) *SelfHost {
+	s := &SelfHost{hosts: make(map[string]bool, len(hosts)), domains: domains}
+	for _, host := range hosts {
+		s.hosts[strings.ToLower(host)] = true
+	}
+	return s
+}
+
+func (s *SelfHost) Check(ctx context.Context, u *url.URL) error {
+	host := strings.ToLower(u.Host)
+	hostname := strings.ToLower(u.Hostname())
+
+	if s.hosts[host] || s.hosts[hostname] {
+		return &service.PolicyError{Rule: "redirect-loop"}
+	}
+	// Default ports are usually left out of configured hosts.
+	if port := u.Port(); port == "" {
+		for _, p := range []string{"80", "443"} {
+			if s.hosts[net.JoinHostPort(hostname, p)] {
+				return &service.PolicyError{Rule: "redirect-loop"}
+			}
+		}
+	}
+
+	if s.domains == nil {
+		return nil
+	}
+	_, err := s.domains.GetDomain(ctx, hostname)
+	switch {
+	case err == nil:
+		return &service.PolicyError{Rule: "redirect-loop"}
+	case errors.Is(err, repository.ErrNotFound):
+		return nil
+	default:
+		return err
+	}
+}
@@ -0,0 +1,214 @@
+// Package qr renders QR codes as PNG or SVG.
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+type Format string
+
+const (
+	FormatPNG Format = "png"
+	FormatSVG Format = "svg"
+)
+
+const (
+	DefaultSize   = 256
+	MinSize       = 64
+	MaxSize       = 2048
+	DefaultMargin = 4
+	MaxMargin     = 16
+)
+
+var (
+	ErrInvalidFormat = errors.New("format must be png or svg")
+	ErrInvalidSize   = fmt.Errorf("size must be between %d and %d", MinSize, MaxSize)
+	ErrInvalidLevel  = errors.New("level must be one of L, M, Q, H")
+	ErrInvalidMargin = fmt.Errorf("margin must be between 0 and %d", MaxMargin)
+	ErrInvalidColor  = errors.New("colors must be hex RGB or RGBA values")
+)
+
+var levels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// Options controls how a code is rendered. The zero value is not usable;
+// start from Defaults.
+type Options struct {
+	Format Format
+	// Size is the width and height of the image in pixels.
+	Size int
+	// Level is the error correction level: L, M, Q or H.
+	Level string
+	// Margin is the quiet zone around the code, in modules.
+	Margin     int
+	Foreground color.NRGBA
+	Background color.NRGBA
+}
+
+func Defaults() Options {
+	return Options{
+		Format:     FormatPNG,
+		Size:       DefaultSize,
+		Level:      "M",
+		Margin:     DefaultMargin,
+		Foreground: color.NRGBA{A: 0xff},
+		Background: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+}
+
+func (o Options) Validate() error {
+	switch {
+	case o.Format != FormatPNG && o.Format != FormatSVG:
+		return ErrInvalidFormat
+	case o.Size < MinSize || o.Size > MaxSize:
+		return ErrInvalidSize
+	case !validLevel(o.Level):
+		return ErrInvalidLevel
+	case o.Margin < 0 || o.Margin > MaxMargin:
+		return ErrInvalidMargin
+	}
+	return nil
+}
+
+func validLevel(level string) bool {
+	_, ok := levels[level]
+	return ok
+}
+
+// String is a canonical form of the options, suitable as a cache key.
+func (o Options) String() string {
+	return fmt.Sprintf("%s:%d:%s:%d:%s:%s", o.Format, o.Size, o.Level, o.Margin, Hex(o.Foreground), Hex(o.Background))
+}
+
+// ParseColor accepts RGB or RGBA in hex, with three, four, six or eight
+// digits and an optional leading '#'.
+func ParseColor(s string) (color.NRGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 || len(s) == 4 {
+		var long strings.Builder
+		for _, r := range s {
+			long.WriteRune(r)
+			long.WriteRune(r)
+		}
+		s = long.String()
+	}
+	if len(s) == 6 {
+		s += "ff"
+	}
+	if len(s) != 8 {
+		return color.NRGBA{}, ErrInvalidColor
+	}
+
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, ErrInvalidColor
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// Hex formats c as eight hex digits without a leading '#'.
+func Hex(c color.NRGBA) string {
+	return fmt.Sprintf("%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
+
+// Write renders content as a QR code to w.
+func Write(w io.Writer, content string, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	code, err := qrcode.New(content, levels[opts.Level])
+	if err != nil {
+		return err
+	}
+	code.DisableBorder = true
+	modules := code.Bitmap()
+
+	if opts.Format == FormatSVG {
+		return writeSVG(w, modules, opts)
+	}
+	return png.Encode(w, render(modules, opts))
+}
+
+// render draws the modules with whole-pixel module sizes so edges stay sharp.
+// Pixels left over from the division widen the margin.
+func render(modules [][]bool, opts Options) image.Image {
+	total := len(modules) + 2*opts.Margin
+	scale := opts.Size / total
+	if scale < 1 {
+		scale = 1
+	}
+	size := opts.Size
+	if size < total*scale {
+		size = total * scale
+	}
+	offset := (size - len(modules)*scale) / 2
+
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{opts.Background, opts.Foreground})
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				start := img.PixOffset(offset+x*scale, offset+y*scale+dy)
+				for dx := 0; dx < scale; dx++ {
+					img.Pix[start+dx] = 1
+				}
+			}
+		}
+	}
+	return img
+}
+
+func writeSVG(w io.Writer, modules [][]bool, opts Options) error {
+	total := len(modules) + 2*opts.Margin
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, opts.Size, total, total)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" %s/>`, total, total, svgFill(opts.Background))
+	fmt.Fprintf(&b, `<path %s d="`, svgFill(opts.Foreground))
+	for y, row := range modules {
+		// One rectangle per horizontal run of dark modules.
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			run := 1
+			for x+run < len(row) && row[x+run] {
+				run++
+			}
+			fmt.Fprintf(&b, "M%d %dh%dv1h-%dz", x+opts.Margin, y+opts.Margin, run, run)
+			x += run
+		}
+	}
+	b.WriteString(`"/></svg>`)
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// svgFill uses a separate opacity because not every SVG consumer understands
+// eight digit hex colors.
+func svgFill(c color.NRGBA) string {
+	fill := fmt.Sprintf(`fill="#%02x%02x%02x"`, c.R, c.G, c.B)
+	if c.A != 0xff {
+		fill += fmt.Sprintf(` fill-opacity="%.3g"`, float64(c.A)/0xff)
+	}
+	return fill
+}
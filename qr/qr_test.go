@@ -0,0 +1,112 @@
+package qr
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestWrite_PNG(t *testing.T) {
+	opts := Defaults()
+	opts.Size = 300
+	opts.Foreground = color.NRGBA{R: 0x11, G: 0x22, B: 0x33, A: 0xff}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "abc", opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("invalid png: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 300 || b.Dy() != 300 {
+		t.Fatalf("expected 300x300, got %v", b)
+	}
+
+	// A version 1 code is 21 modules wide; with a margin of 4 that is 29
+	// modules of 10px, and the 10px left over are split around the code.
+	offset := (300 - 21*10) / 2
+	if got := color.NRGBAModel.Convert(img.At(0, 0)); got != opts.Background {
+		t.Errorf("expected background in the margin, got %v", got)
+	}
+	if got := color.NRGBAModel.Convert(img.At(offset, offset)); got != opts.Foreground {
+		t.Errorf("expected the finder pattern at %d, got %v", offset, got)
+	}
+	if got := color.NRGBAModel.Convert(img.At(offset-1, offset-1)); got != opts.Background {
+		t.Errorf("expected the quiet zone right before the code, got %v", got)
+	}
+}
+
+func TestWrite_SVG(t *testing.T) {
+	opts := Defaults()
+	opts.Format = FormatSVG
+	opts.Margin = 2
+	opts.Background = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0x80}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "abc", opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	svg := buf.String()
+	for _, want := range []string{
+		`width="256" height="256" viewBox="0 0 25 25"`,
+		`<rect width="25" height="25" fill="#ffffff" fill-opacity="0.502"/>`,
+		`<path fill="#000000" d="M2 2h7v1h-7z`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("expected svg to contain %q, got %s", want, svg)
+		}
+	}
+}
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Options)
+		err    error
+	}{
+		{name: "defaults", modify: func(o *Options) {}},
+		{name: "format", modify: func(o *Options) { o.Format = "gif" }, err: ErrInvalidFormat},
+		{name: "too small", modify: func(o *Options) { o.Size = 10 }, err: ErrInvalidSize},
+		{name: "too large", modify: func(o *Options) { o.Size = 5000 }, err: ErrInvalidSize},
+		{name: "low level", modify: func(o *Options) { o.Level = "L" }},
+		{name: "level", modify: func(o *Options) { o.Level = "X" }, err: ErrInvalidLevel},
+		{name: "margin", modify: func(o *Options) { o.Margin = -1 }, err: ErrInvalidMargin},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Defaults()
+			tt.modify(&opts)
+			if err := opts.Validate(); err != tt.err {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		input string
+		want  color.NRGBA
+		err   bool
+	}{
+		{input: "000", want: color.NRGBA{A: 0xff}},
+		{input: "#1a2b3c", want: color.NRGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 0xff}},
+		{input: "1a2b3c80", want: color.NRGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 0x80}},
+		{input: "f008", want: color.NRGBA{R: 0xff, A: 0x88}},
+		{input: "red", err: true},
+		{input: "12345", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseColor(tt.input)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseColor(%q) = %v, %v", tt.input, got, err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle buckets are dropped from memory.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// memoryLimiter is a token bucket per key holding up to Limit tokens and
+// refilling Limit tokens per Window. It is only suitable for single-node
+// deployments.
+type memoryLimiter struct {
+	mu        sync.Mutex
+	rule      Rule
+	rate      float64
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryLimiter(rule Rule) Limiter {
+	return newMemoryLimiter(rule, time.Now)
+}
+
+func newMemoryLimiter(rule Rule, now func() time.Time) *memoryLimiter {
+	return &memoryLimiter{
+		rule:      rule,
+		rate:      float64(rule.Limit) / rule.Window.Seconds(),
+		buckets:   make(map[string]*bucket),
+		lastSweep: now(),
+		now:       now,
+	}
+}
+
+func (l *memoryLimiter) Allow(_ context.Context, key string) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	capacity := float64(l.rule.Limit)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	res := Result{Limit: l.rule.Limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = l.duration(1 - b.tokens)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = l.duration(capacity - b.tokens)
+	return res, nil
+}
+
+func (l *memoryLimiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep drops buckets that have been idle long enough to be full again, since
+// a full bucket behaves exactly like a missing one.
+func (l *memoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.rule.Window {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// memoryBlocklist keeps block expiry times in memory, for single-node
+// deployments.
+type memoryBlocklist struct {
+	mu        sync.Mutex
+	until     map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryBlocklist() Blocklist {
+	return newMemoryBlocklist(time.Now)
+}
+
+func newMemoryBlocklist(now func() time.Time) *memoryBlocklist {
+	return &memoryBlocklist{until: make(map[string]time.Time), lastSweep: now(), now: now}
+}
+
+func (b *memoryBlocklist) Block(_ context.Context, key string, d time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.sweep(now)
+	b.until[key] = now.Add(d)
+	return nil
+}
+
+func (b *memoryBlocklist) Blocked(_ context.Context, key string) (time.Duration, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if left := b.until[key].Sub(b.now()); left > 0 {
+		return left, nil
+	}
+	return 0, nil
+}
+
+// sweep drops blocks that have run out.
+func (b *memoryBlocklist) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < sweepInterval {
+		return
+	}
+	b.lastSweep = now
+
+	for key, until := range b.until {
+		if !until.After(now) {
+			delete(b.until, key)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestMemoryLimiter_Allow(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	limiter := newMemoryLimiter(Rule{Limit: 3, Window: 3 * time.Second}, clock.Now)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		res, err := limiter.Allow(ctx, "client")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !res.Allowed {
+			t.Fatalf("request %d should be allowed", i+1)
+		}
+		if res.Remaining != 2-i {
+			t.Errorf("request %d: remaining = %d, want %d", i+1, res.Remaining, 2-i)
+		}
+	}
+
+	res, _ := limiter.Allow(ctx, "client")
+	if res.Allowed {
+		t.Fatal("fourth request should be limited")
+	}
+	if res.RetryAfter != time.Second {
+		t.Errorf("retry after = %s, want 1s", res.RetryAfter)
+	}
+	if res.Reset != 3*time.Second {
+		t.Errorf("reset = %s, want 3s", res.Reset)
+	}
+
+	other, _ := limiter.Allow(ctx, "other")
+	if !other.Allowed {
+		t.Error("other clients should have their own bucket")
+	}
+
+	clock.Advance(time.Second)
+	res, _ = limiter.Allow(ctx, "client")
+	if !res.Allowed {
+		t.Error("request should be allowed after a token is refilled")
+	}
+}
+
+func TestMemoryLimiter_BurstIsCapped(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	limiter := newMemoryLimiter(Rule{Limit: 2, Window: time.Second}, clock.Now)
+	ctx := context.Background()
+
+	limiter.Allow(ctx, "client")
+	clock.Advance(time.Hour)
+
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		if res, _ := limiter.Allow(ctx, "client"); res.Allowed {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("allowed %d requests after idling, want 2", allowed)
+	}
+}
+
+func TestMemoryLimiter_SweepsIdleBuckets(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	limiter := newMemoryLimiter(Rule{Limit: 1, Window: time.Second}, clock.Now)
+	ctx := context.Background()
+
+	limiter.Allow(ctx, "a")
+	limiter.Allow(ctx, "b")
+	clock.Advance(sweepInterval)
+	limiter.Allow(ctx, "c")
+
+	if len(limiter.buckets) != 1 {
+		t.Errorf("expected idle buckets to be dropped, have %d", len(limiter.buckets))
+	}
+}
@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+	"url-shortener/repository"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// Rule allows Limit requests per Window for every client.
+type Rule struct {
+	Limit  int           `yaml:"limit"`
+	Window time.Duration `yaml:"window"`
+}
+
+type Config struct {
+	Enabled  bool   `yaml:"enabled"`
+	Backend  string `yaml:"backend"`
+	Create   Rule   `yaml:"create"`
+	Redirect Rule   `yaml:"redirect"`
+	// Scan is applied to redirects independently of Enabled.
+	Scan ScanConfig `yaml:"scan"`
+	// Auth blocks clients sending too many invalid API keys, counted as
+	// misses, independently of Enabled.
+	Auth ScanConfig `yaml:"auth"`
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the client has its full budget again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed. It is zero
+	// when the request was allowed.
+	RetryAfter time.Duration
+}
+
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+// New builds the limiter for one route budget. Budgets sharing a backend are
+// kept apart by name. client and keys are only used by the Redis backend.
+func New(cfg Config, name string, rule Rule, client *redis.Client, keys repository.Keyspace) Limiter {
+	if cfg.Backend == BackendRedis {
+		return NewRedisLimiter(client, keys, name, rule)
+	}
+	return NewMemoryLimiter(rule)
+}
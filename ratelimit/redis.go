@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+	"url-shortener/repository"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingWindow keeps the timestamps of accepted requests in a sorted set and
+// admits a request while fewer than the limit fall inside the window. The
+// Redis clock is used so that all instances agree on the current time.
+var slidingWindow = redis.NewScript(`
+local key = KEYS[1]
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, now .. '-' .. ARGV[3])
+	redis.call('PEXPIRE', key, window)
+	count = count + 1
+	allowed = 1
+end
+
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+local retry = 0
+if allowed == 0 then
+	retry = tonumber(oldest[2]) + window - now
+end
+local reset = tonumber(newest[2]) + window - now
+
+return {allowed, limit - count, reset, retry}
+`)
+
+type redisLimiter struct {
+	client *redis.Client
+	keys   repository.Keyspace
+	name   string
+	rule   Rule
+}
+
+// NewRedisLimiter returns a sliding window limiter shared by every instance
+// connected to the same Redis. Its windows are kept under "ratelimit:" in
+// keys.
+func NewRedisLimiter(client *redis.Client, keys repository.Keyspace, name string, rule Rule) Limiter {
+	return &redisLimiter{client: client, keys: keys, name: name, rule: rule}
+}
+
+func (l *redisLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	member := make([]byte, 8)
+	if _, err := rand.Read(member); err != nil {
+		return Result{}, err
+	}
+
+	vals, err := slidingWindow.Run(ctx, l.client,
+		[]string{l.keys.Key("ratelimit", l.name, key)},
+		l.rule.Window.Milliseconds(), l.rule.Limit, hex.EncodeToString(member),
+	).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := Result{
+		Allowed:    vals[0] == 1,
+		Limit:      l.rule.Limit,
+		Remaining:  int(vals[1]),
+		Reset:      time.Duration(vals[2]) * time.Millisecond,
+		RetryAfter: time.Duration(vals[3]) * time.Millisecond,
+	}
+	return res, nil
+}
+
+type redisBlocklist struct {
+	client *redis.Client
+	keys   repository.Keyspace
+	name   string
+}
+
+// NewRedisBlocklist returns a blocklist shared by every instance connected
+// to the same Redis. Blocks are keys under "ratelimit:blocked:" in keys that
+// expire when they run out. Blocklists sharing a Redis are kept apart by
+// name.
+func NewRedisBlocklist(client *redis.Client, keys repository.Keyspace, name string) Blocklist {
+	return &redisBlocklist{client: client, keys: keys, name: name}
+}
+
+func (b *redisBlocklist) blockKey(key string) string {
+	return b.keys.Key("ratelimit", "blocked", b.name, key)
+}
+
+func (b *redisBlocklist) Block(ctx context.Context, key string, d time.Duration) error {
+	return b.client.Set(ctx, b.blockKey(key), 1, d).Err()
+}
+
+func (b *redisBlocklist) Blocked(ctx context.Context, key string) (time.Duration, error) {
+	left, err := b.client.PTTL(ctx, b.blockKey(key)).Result()
+	if err != nil || left < 0 {
+		// Missing keys report a negative TTL.
+		return 0, err
+	}
+	return left, nil
+}
@@ -0,0 +1,34 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+	"url-shortener/repository"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisBackend_KeysUnderPrefix(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	keys := repository.NewKeyspace("app")
+	ctx := context.Background()
+
+	limiter := NewRedisLimiter(client, keys, "create", Rule{Limit: 1, Window: time.Minute})
+	if res, err := limiter.Allow(ctx, "ip:203.0.113.7"); err != nil || !res.Allowed {
+		t.Fatalf("Allow() = %+v, %v", res, err)
+	}
+	blocks := NewRedisBlocklist(client, keys, "auth")
+	if err := blocks.Block(ctx, "ip:203.0.113.7", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, key := range []string{"app:ratelimit:create:ip:203.0.113.7", "app:ratelimit:blocked:auth:ip:203.0.113.7"} {
+		if !mr.Exists(key) {
+			t.Errorf("expected %s, got keys %v", key, mr.Keys())
+		}
+	}
+}
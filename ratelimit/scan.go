@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+	"url-shortener/repository"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ScanConfig blocks clients that look for valid keys by requesting many
+// unknown ones.
+type ScanConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Misses is how many unknown keys a client may request per window.
+	Misses Rule `yaml:"misses"`
+	// BlockFor is how long a client that exceeded Misses is refused.
+	BlockFor time.Duration `yaml:"block_for"`
+}
+
+// Blocklist remembers clients refused for a while.
+type Blocklist interface {
+	Block(ctx context.Context, key string, d time.Duration) error
+	// Blocked returns how long key stays blocked, zero if it is not.
+	Blocked(ctx context.Context, key string) (time.Duration, error)
+}
+
+// ScanStats counts what a ScanGuard did since it was created.
+type ScanStats struct {
+	// BlockedClients is how many times a client was blocked.
+	BlockedClients int64 `json:"blocked_clients"`
+	// RejectedRequests is how many requests of blocked clients were refused.
+	RejectedRequests int64 `json:"rejected_requests"`
+}
+
+// ScanGuard detects key enumeration: every request for an unknown key uses
+// up one of the client's misses, and a client without misses left is
+// blocked.
+type ScanGuard struct {
+	misses   Limiter
+	blocks   Blocklist
+	blockFor time.Duration
+
+	blockedClients   atomic.Int64
+	rejectedRequests atomic.Int64
+}
+
+// NewScanGuard keeps misses and blocks in the backend chosen by cfg.
+func NewScanGuard(cfg Config, client *redis.Client, keys repository.Keyspace) *ScanGuard {
+	return newGuard(cfg, "misses", cfg.Scan, client, keys)
+}
+
+// NewAuthGuard returns a guard against API key guessing: its misses are
+// invalid API keys. Its misses and blocks are kept apart from the scan
+// guard's.
+func NewAuthGuard(cfg Config, client *redis.Client, keys repository.Keyspace) *ScanGuard {
+	return newGuard(cfg, "auth", cfg.Auth, client, keys)
+}
+
+func newGuard(cfg Config, name string, sc ScanConfig, client *redis.Client, keys repository.Keyspace) *ScanGuard {
+	if cfg.Backend == BackendRedis {
+		return newScanGuard(NewRedisLimiter(client, keys, name, sc.Misses), NewRedisBlocklist(client, keys, name), sc.BlockFor)
+	}
+	return newScanGuard(NewMemoryLimiter(sc.Misses), NewMemoryBlocklist(), sc.BlockFor)
+}
+
+func newScanGuard(misses Limiter, blocks Blocklist, blockFor time.Duration) *ScanGuard {
+	return &ScanGuard{misses: misses, blocks: blocks, blockFor: blockFor}
+}
+
+// Blocked returns how long client stays blocked, zero if it may go on. A
+// non-zero result counts as a rejected request.
+func (g *ScanGuard) Blocked(ctx context.Context, client string) (time.Duration, error) {
+	d, err := g.blocks.Blocked(ctx, client)
+	if err != nil {
+		return 0, err
+	}
+	if d > 0 {
+		g.rejectedRequests.Add(1)
+	}
+	return d, nil
+}
+
+// Miss records a request for an unknown key and reports whether it got the
+// client blocked.
+func (g *ScanGuard) Miss(ctx context.Context, client string) (bool, error) {
+	res, err := g.misses.Allow(ctx, client)
+	if err != nil || res.Allowed {
+		return false, err
+	}
+	if err := g.blocks.Block(ctx, client, g.blockFor); err != nil {
+		return false, err
+	}
+	g.blockedClients.Add(1)
+	return true, nil
+}
+
+func (g *ScanGuard) Stats() ScanStats {
+	return ScanStats{
+		BlockedClients:   g.blockedClients.Load(),
+		RejectedRequests: g.rejectedRequests.Load(),
+	}
+}
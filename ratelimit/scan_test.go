@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestScanGuard(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	guard := newScanGuard(
+		newMemoryLimiter(Rule{Limit: 3, Window: time.Minute}, clock.Now),
+		newMemoryBlocklist(clock.Now),
+		10*time.Minute,
+	)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if blocked, err := guard.Miss(ctx, "scanner"); err != nil || blocked {
+			t.Fatalf("miss %d: blocked = %v, err = %v", i+1, blocked, err)
+		}
+	}
+	if d, _ := guard.Blocked(ctx, "scanner"); d != 0 {
+		t.Fatalf("client blocked before using up its misses")
+	}
+
+	if blocked, _ := guard.Miss(ctx, "scanner"); !blocked {
+		t.Fatal("expected the fourth miss to block the client")
+	}
+	if d, _ := guard.Blocked(ctx, "scanner"); d != 10*time.Minute {
+		t.Errorf("blocked for %v, want 10m", d)
+	}
+	if d, _ := guard.Blocked(ctx, "visitor"); d != 0 {
+		t.Errorf("other clients must not be blocked, got %v", d)
+	}
+
+	clock.Advance(10 * time.Minute)
+	if d, _ := guard.Blocked(ctx, "scanner"); d != 0 {
+		t.Errorf("block should have run out, %v left", d)
+	}
+
+	want := ScanStats{BlockedClients: 1, RejectedRequests: 1}
+	if got := guard.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ClickEvent is one redirect as published to the click stream.
+type ClickEvent struct {
+	Key string
+	At  time.Time
+	// IPHash identifies the visitor without storing their address.
+	IPHash    string
+	Referrer  string
+	UserAgent string
+	// Variant is the 1-based A/B variant the visitor was sent to, or 0.
+	Variant int
+}
+
+func (e ClickEvent) values() map[string]any {
+	return map[string]any{
+		"key":      e.Key,
+		"at":       e.At.UnixMilli(),
+		"ip_hash":  e.IPHash,
+		"referrer": e.Referrer,
+		"ua":       e.UserAgent,
+		"variant":  e.Variant,
+	}
+}
+
+func clickEventFromValues(values map[string]any) ClickEvent {
+	str := func(name string) string {
+		s, _ := values[name].(string)
+		return s
+	}
+	e := ClickEvent{
+		Key:       str("key"),
+		IPHash:    str("ip_hash"),
+		Referrer:  str("referrer"),
+		UserAgent: str("ua"),
+	}
+	if ms, err := strconv.ParseInt(str("at"), 10, 64); err == nil {
+		e.At = time.UnixMilli(ms).UTC()
+	}
+	e.Variant, _ = strconv.Atoi(str("variant"))
+	return e
+}
+
+// ClickStream appends click events to a Redis Stream.
+type ClickStream struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+// NewClickStream writes to stream, trimming it to roughly maxLen entries.
+// Trimming is approximate so Redis can drop whole nodes at a time.
+func NewClickStream(client *redis.Client, stream string, maxLen int64) *ClickStream {
+	return &ClickStream{client: client, stream: stream, maxLen: maxLen}
+}
+
+// WriteClicks appends events in one round trip.
+func (s *ClickStream) WriteClicks(ctx context.Context, events []ClickEvent) error {
+	pipe := s.client.Pipeline()
+	for _, e := range events {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: s.stream,
+			MaxLen: s.maxLen,
+			Approx: true,
+			Values: e.values(),
+		})
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ClickMessage is a click event read from the stream. Its ID must be
+// acknowledged once the event has been processed.
+type ClickMessage struct {
+	ID string
+	ClickEvent
+}
+
+// ClickGroup reads the click stream as one consumer of a consumer group, so
+// several aggregator instances can share the work. Messages that are read
+// but never acknowledged stay pending and can be claimed by another
+// consumer.
+type ClickGroup struct {
+	client   *redis.Client
+	stream   string
+	group    string
+	consumer string
+}
+
+// NewClickGroup joins group as consumer, creating the group (and the stream)
+// if needed. A new group starts with the oldest event still in the stream.
+func NewClickGroup(ctx context.Context, client *redis.Client, stream, group, consumer string) (*ClickGroup, error) {
+	err := client.XGroupCreateMkStream(ctx, stream, group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, err
+	}
+	return &ClickGroup{client: client, stream: stream, group: group, consumer: consumer}, nil
+}
+
+// Read returns up to count events no consumer of the group has seen,
+// waiting up to block for the first one; zero waits forever and a negative
+// block not at all. It returns no events and no error if none arrived in
+// time.
+func (g *ClickGroup) Read(ctx context.Context, count int, block time.Duration) ([]ClickMessage, error) {
+	streams, err := g.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    g.group,
+		Consumer: g.consumer,
+		Streams:  []string{g.stream, ">"},
+		Count:    int64(count),
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []ClickMessage
+	for _, stream := range streams {
+		messages = append(messages, clickMessages(stream.Messages)...)
+	}
+	return messages, nil
+}
+
+// Claim takes over up to count events that other consumers read but did
+// not acknowledge within minIdle, for example because they crashed.
+func (g *ClickGroup) Claim(ctx context.Context, minIdle time.Duration, count int) ([]ClickMessage, error) {
+	msgs, _, err := g.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   g.stream,
+		Group:    g.group,
+		Consumer: g.consumer,
+		MinIdle:  minIdle,
+		Start:    "0",
+		Count:    int64(count),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	return clickMessages(msgs), nil
+}
+
+// Ack marks events as processed so they are not handed out again.
+func (g *ClickGroup) Ack(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return g.client.XAck(ctx, g.stream, g.group, ids...).Err()
+}
+
+func clickMessages(msgs []redis.XMessage) []ClickMessage {
+	messages := make([]ClickMessage, len(msgs))
+	for i, msg := range msgs {
+		messages[i] = ClickMessage{ID: msg.ID, ClickEvent: clickEventFromValues(msg.Values)}
+	}
+	return messages
+}
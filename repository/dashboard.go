@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Ranked is a link key or referrer domain with its clicks in a window.
+type Ranked struct {
+	Name  string
+	Count int64
+}
+
+// days returns the UTC start of every day overlapping [from, to).
+func days(from, to time.Time) []time.Time {
+	var out []time.Time
+	for day, _ := seriesPeriod(GranularityHour, from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		out = append(out, day)
+	}
+	return out
+}
+
+// incrRankings counts a click at t in the day's link and referrer rankings.
+// An empty referrer is not ranked.
+func (rr *redisRepo) incrRankings(ctx context.Context, pipe redis.Pipeliner, key, referrer string, t time.Time) {
+	day, end := seriesPeriod(GranularityHour, t)
+	expireAt := end.Add(rr.retention.Rankings)
+	pipe.ZIncrBy(ctx, rr.keys.topLinks(day), 1, key)
+	pipe.ExpireAt(ctx, rr.keys.topLinks(day), expireAt)
+	if referrer != "" {
+		pipe.ZIncrBy(ctx, rr.keys.topReferrers(day), 1, referrer)
+		pipe.ExpireAt(ctx, rr.keys.topReferrers(day), expireAt)
+	}
+}
+
+// incrCreated counts n links created at t.
+func (rr *redisRepo) incrCreated(ctx context.Context, pipe redis.Pipeliner, n int, t time.Time) {
+	day, end := seriesPeriod(GranularityHour, t)
+	pipe.IncrBy(ctx, rr.keys.created(day), int64(n))
+	pipe.ExpireAt(ctx, rr.keys.created(day), end.Add(rr.retention.Daily))
+}
+
+// rankingKeys returns the link rankings that may still hold key at now.
+func (rr *redisRepo) rankingKeys(now time.Time) []string {
+	var keys []string
+	day, end := seriesPeriod(GranularityHour, now)
+	for ; end.Add(rr.retention.Rankings).After(now); day, end = day.AddDate(0, 0, -1), day {
+		keys = append(keys, rr.keys.topLinks(day))
+	}
+	return keys
+}
+
+func (rr *redisRepo) TopLinks(ctx context.Context, from, to time.Time, n int) ([]Ranked, error) {
+	return rr.top(ctx, rr.keys.Key("top", "links", "union"), rr.keys.topLinks, from, to, n)
+}
+
+func (rr *redisRepo) TopReferrers(ctx context.Context, from, to time.Time, n int) ([]Ranked, error) {
+	return rr.top(ctx, rr.keys.Key("top", "referrers", "union"), rr.keys.topReferrers, from, to, n)
+}
+
+// top merges the daily rankings of [from, to) and returns the n highest.
+func (rr *redisRepo) top(ctx context.Context, union string, dayKey func(time.Time) string, from, to time.Time, n int) ([]Ranked, error) {
+	var keys []string
+	for _, day := range days(from, to) {
+		keys = append(keys, dayKey(day))
+	}
+	if len(keys) == 0 || n <= 0 {
+		return nil, nil
+	}
+
+	// The union only lives inside the transaction, so concurrent queries
+	// can share its key.
+	var ranked *redis.ZSliceCmd
+	_, err := rr.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZUnionStore(ctx, union, &redis.ZStore{Keys: keys})
+		ranked = pipe.ZRevRangeWithScores(ctx, union, 0, int64(n-1))
+		pipe.Del(ctx, union)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Ranked, 0, len(ranked.Val()))
+	for _, z := range ranked.Val() {
+		name, _ := z.Member.(string)
+		out = append(out, Ranked{Name: unscoped(ctx, name), Count: int64(z.Score)})
+	}
+	return out, nil
+}
+
+func (rr *redisRepo) LinksCreated(ctx context.Context, from, to time.Time) (map[time.Time]int64, error) {
+	periods := days(from, to)
+	if len(periods) == 0 {
+		return map[time.Time]int64{}, nil
+	}
+	keys := make([]string, len(periods))
+	for i, day := range periods {
+		keys[i] = rr.keys.created(day)
+	}
+
+	values, err := rr.client.MGet(ctx, keys...).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	created := make(map[time.Time]int64)
+	for i, value := range values {
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			created[periods[i]] = n
+		}
+	}
+	return created, nil
+}
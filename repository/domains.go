@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Domain is a host serving its own namespace of keys, so brand-a.link/xyz
+// and brand-b.link/xyz are different links.
+type Domain struct {
+	Name string
+	// RedirectCode answers plain redirects; zero means 301.
+	RedirectCode int
+	// FallbackURL receives visitors of unknown keys instead of a 404.
+	FallbackURL string
+	// AllowedDestinations limits links to these hosts and their subdomains.
+	// Empty allows every destination.
+	AllowedDestinations []string
+	CreatedAt           time.Time
+}
+
+type domainContextKey struct{}
+
+// WithDomain makes the repository work on the links of domain. Without it
+// the default namespace is used.
+func WithDomain(ctx context.Context, domain string) context.Context {
+	return context.WithValue(ctx, domainContextKey{}, domain)
+}
+
+// DomainFrom returns the domain set by WithDomain, empty for the default
+// namespace.
+func DomainFrom(ctx context.Context) string {
+	domain, _ := ctx.Value(domainContextKey{}).(string)
+	return domain
+}
+
+// scoped returns the stored key of key in the context's domain. Keys of
+// other domains are stored as "<domain>/<key>"; keys never contain "/", so
+// a key that does is already scoped and returned as is.
+func scoped(ctx context.Context, key string) string {
+	domain := DomainFrom(ctx)
+	if domain == "" || strings.Contains(key, "/") {
+		return key
+	}
+	return domain + "/" + key
+}
+
+// unscoped reverses scoped for keys of the context's domain. Keys of other
+// domains keep their prefix.
+func unscoped(ctx context.Context, key string) string {
+	if domain := DomainFrom(ctx); domain != "" {
+		return strings.TrimPrefix(key, domain+"/")
+	}
+	return key
+}
+
+func (rr *redisRepo) SaveDomain(ctx context.Context, d Domain) error {
+	allowed, _ := json.Marshal(d.AllowedDestinations)
+	_, err := rr.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		key := rr.keys.domain(d.Name)
+		pipe.HSet(ctx, key,
+			"redirect_code", d.RedirectCode,
+			"fallback_url", d.FallbackURL,
+			"allowed_destinations", allowed,
+		)
+		pipe.HSetNX(ctx, key, "created_at", d.CreatedAt.Unix())
+		pipe.SAdd(ctx, rr.keys.domainsIndex(), d.Name)
+		return nil
+	})
+	return err
+}
+
+func (rr *redisRepo) GetDomain(ctx context.Context, name string) (Domain, error) {
+	fields, err := rr.client.HGetAll(ctx, rr.keys.domain(name)).Result()
+	if err != nil {
+		return Domain{}, err
+	}
+	if len(fields) == 0 {
+		return Domain{}, ErrNotFound
+	}
+	return domainFromFields(name, fields), nil
+}
+
+func (rr *redisRepo) DeleteDomain(ctx context.Context, name string) error {
+	var del *redis.IntCmd
+	_, err := rr.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		del = pipe.Del(ctx, rr.keys.domain(name))
+		pipe.SRem(ctx, rr.keys.domainsIndex(), name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if del.Val() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (rr *redisRepo) ListDomains(ctx context.Context) ([]Domain, error) {
+	names, err := rr.client.SMembers(ctx, rr.keys.domainsIndex()).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := rr.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(names))
+	for i, name := range names {
+		cmds[i] = pipe.HGetAll(ctx, rr.keys.domain(name))
+	}
+	if len(names) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	domains := make([]Domain, 0, len(names))
+	for i, name := range names {
+		if fields := cmds[i].Val(); len(fields) > 0 {
+			domains = append(domains, domainFromFields(name, fields))
+		}
+	}
+	return domains, nil
+}
+
+func domainFromFields(name string, fields map[string]string) Domain {
+	d := Domain{Name: name, FallbackURL: fields["fallback_url"]}
+	d.RedirectCode, _ = strconv.Atoi(fields["redirect_code"])
+	_ = json.Unmarshal([]byte(fields["allowed_destinations"]), &d.AllowedDestinations)
+	if created, err := strconv.ParseInt(fields["created_at"], 10, 64); err == nil {
+		d.CreatedAt = time.Unix(created, 0).UTC()
+	}
+	return d
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultKeyPrefix is the prefix of every key the repository stores unless
+// WithKeyPrefix sets another one.
+const DefaultKeyPrefix = "shortener"
+
+// WithKeyPrefix stores every key under prefix, so that several applications
+// or deployments can share a Redis database. An empty prefix keeps
+// DefaultKeyPrefix.
+func WithKeyPrefix(prefix string) Option {
+	return func(rr *redisRepo) {
+		rr.keys = NewKeyspace(prefix)
+	}
+}
+
+// Keyspace names the Redis keys of the shortener. They are all
+// "<prefix>:<kind>:..." so they can be told apart from the keys of other
+// applications and scanned by kind. The webhook store, the rate limiter and
+// the click stream name their keys with it too, so that one prefix covers
+// everything the shortener stores. Sets and sorted sets of the repository
+// hold link keys as stored, see scoped, never full Redis keys.
+type Keyspace struct {
+	prefix string
+}
+
+// NewKeyspace returns the keyspace under prefix, DefaultKeyPrefix if empty.
+func NewKeyspace(prefix string) Keyspace {
+	if prefix == "" {
+		prefix = DefaultKeyPrefix
+	}
+	return Keyspace{prefix: prefix}
+}
+
+// Key joins parts into a key of the keyspace.
+func (k Keyspace) Key(parts ...string) string {
+	return k.prefix + ":" + strings.Join(parts, ":")
+}
+
+// match is the SCAN pattern of every key of kind.
+func (k Keyspace) match(kind string) string {
+	return globEscaper.Replace(k.prefix) + ":" + kind + ":*"
+}
+
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// link holds the destination URL and, through its TTL, the link's lifetime.
+func (k Keyspace) link(key string) string {
+	return k.Key("link", key)
+}
+
+// meta is the hash with everything else stored about a link.
+func (k Keyspace) meta(key string) string {
+	return k.Key("meta", key)
+}
+
+// ownerIndex names the owner's index of links in domain. Indexes of the
+// default namespace have no domain suffix.
+func (k Keyspace) ownerIndex(owner string, sort SortField, domain string) string {
+	if domain != "" {
+		return k.Key("owner", owner, string(sort), domain)
+	}
+	return k.Key("owner", owner, string(sort))
+}
+
+func (k Keyspace) passwordFailures(key string) string {
+	return k.Key("pwfail", key)
+}
+
+// disabledIndex is the set of disabled link keys.
+func (k Keyspace) disabledIndex() string {
+	return k.Key("disabled")
+}
+
+func (k Keyspace) apiKey(id string) string {
+	return k.Key("apikey", id)
+}
+
+func (k Keyspace) apiKeysIndex() string {
+	return k.Key("apikeys")
+}
+
+func (k Keyspace) domain(name string) string {
+	return k.Key("domain", name)
+}
+
+// domainsIndex is the set of registered domain names.
+func (k Keyspace) domainsIndex() string {
+	return k.Key("domains")
+}
+
+func (k Keyspace) series(key string, g Granularity, periodStart time.Time) string {
+	if g == GranularityHour {
+		return k.Key("stats", "hour", key, periodStart.Format("2006-01-02"))
+	}
+	return k.Key("stats", "day", key, periodStart.Format("2006-01"))
+}
+
+func (k Keyspace) created(day time.Time) string {
+	return k.Key("stats", "created", day.Format("2006-01-02"))
+}
+
+func (k Keyspace) topLinks(day time.Time) string {
+	return k.Key("top", "links", day.Format("2006-01-02"))
+}
+
+func (k Keyspace) topReferrers(day time.Time) string {
+	return k.Key("top", "referrers", day.Format("2006-01-02"))
+}
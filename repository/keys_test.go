@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyspace(t *testing.T) {
+	day := time.Date(2025, 6, 1, 13, 0, 0, 0, time.UTC)
+	keys := NewKeyspace("app")
+
+	tests := []struct {
+		got, want string
+	}{
+		{NewKeyspace("").Key("link", "abc"), "shortener:link:abc"},
+		{keys.Key("webhook", "queue"), "app:webhook:queue"},
+		{keys.link("brand.link/abc"), "app:link:brand.link/abc"},
+		{keys.meta("abc"), "app:meta:abc"},
+		{keys.ownerIndex("alice", SortByClicks, ""), "app:owner:alice:clicks"},
+		{keys.ownerIndex("alice", SortByCreated, "brand.link"), "app:owner:alice:created:brand.link"},
+		{keys.series("abc", GranularityHour, day), "app:stats:hour:abc:2025-06-01"},
+		{keys.series("abc", GranularityDay, day), "app:stats:day:abc:2025-06"},
+		{keys.topLinks(day), "app:top:links:2025-06-01"},
+		{keys.disabledIndex(), "app:disabled"},
+		{NewKeyspace("a*[b]").match("meta"), `a\*\[b\]:meta:*`},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+}
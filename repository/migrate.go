@@ -0,0 +1,267 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// legacyKinds maps the namespaces of the unprefixed layout to their kinds
+// in the keyspace. Keys of the unprefixed layout without a namespace are
+// link URLs, except for the sets in legacyIndexes.
+var legacyKinds = map[string]string{
+	"meta:":          "meta:",
+	"owner:":         "owner:",
+	"pwfail:":        "pwfail:",
+	"apikey:":        "apikey:",
+	"domain:":        "domain:",
+	"ts:hour:":       "stats:hour:",
+	"ts:day:":        "stats:day:",
+	"stats:created:": "stats:created:",
+	"top:links:":     "top:links:",
+	"top:referrers:": "top:referrers:",
+	"webhook:":       "webhook:",
+}
+
+var legacyIndexes = map[string]bool{
+	"apikeys":  true,
+	"disabled": true,
+	"domains":  true,
+}
+
+// legacyLinkPattern matches the link keys of the unprefixed layout: a key,
+// optionally scoped to a domain that may carry a port.
+var legacyLinkPattern = regexp.MustCompile(`^([A-Za-z0-9.-]+(:[0-9]+)?/)?[A-Za-z0-9_.~-]{1,64}$`)
+
+// KeyMigration is one key MigrateKeys moved or would have moved.
+type KeyMigration struct {
+	From string
+	To   string
+	// Conflict is set when To already existed, in which case From is left
+	// where it is.
+	Conflict bool
+}
+
+// renameNX renames KEYS[1] to KEYS[2] unless KEYS[2] exists. It returns 1
+// when the key was renamed, 0 on a conflict and -1 when KEYS[1] is gone,
+// which happens when SCAN returns a key twice or a key given explicitly was
+// moved before.
+var renameNX = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+return redis.call('RENAMENX', KEYS[1], KEYS[2])
+`)
+
+// MigrateOptions describes a MigrateKeys run.
+type MigrateOptions struct {
+	// Prefix is the prefix of the repository keys are moved to, empty for
+	// DefaultKeyPrefix.
+	Prefix string
+	// DryRun only reports what would be moved.
+	DryRun bool
+	// Links are link keys to move even though nothing else shows they
+	// belong to the shortener, see MigrateKeys.
+	Links []string
+	// AllLinks takes every string that looks like a link key for one, see
+	// MigrateKeys.
+	AllLinks bool
+	// ClickStream is the name of the click stream, which is moved under
+	// the prefix too when set.
+	ClickStream string
+}
+
+// MigrateKeys moves the keys of the unprefixed layout used before
+// WithKeyPrefix to the keys of a repository with opts.Prefix. It walks the
+// database with SCAN and moves keys with RENAMENX, so it never blocks Redis,
+// never overwrites a key and can be interrupted and run again. fn is called
+// for every key that was, or would be, moved and for every conflict.
+//
+// Namespaced keys are recognized by their namespace. Link keys have none, so
+// a string without one is only taken for a link when its metadata hash or an
+// owner index, under either layout, says so, or when it is listed in
+// opts.Links. Links created before metadata was kept have neither; with
+// opts.AllLinks every string made of key characters, optionally after a
+// "<domain>/" with or without a port, is moved instead, so it should only
+// be used on a database the shortener has to itself. Everything else, such
+// as the keys of other applications, is left alone, as are the rate
+// limiter's keys, which expire within minutes anyway.
+//
+// The server must not run the old layout while keys are moved, or it keeps
+// writing keys that are no longer read.
+func MigrateKeys(ctx context.Context, client *redis.Client, opts MigrateOptions, fn func(KeyMigration) error) error {
+	keys := NewKeyspace(opts.Prefix)
+
+	owned, err := ownedLinks(ctx, client, keys)
+	if err != nil {
+		return err
+	}
+
+	var cursor uint64
+	for {
+		batch, next, err := client.Scan(ctx, cursor, "*", scanBatch).Result()
+		if err != nil {
+			return err
+		}
+		moves, err := legacyMoves(ctx, client, keys, owned, opts.AllLinks, batch)
+		if err != nil {
+			return err
+		}
+		if err := applyMoves(ctx, client, moves, opts.DryRun, fn); err != nil {
+			return err
+		}
+
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+
+	var moves []KeyMigration
+	for _, key := range opts.Links {
+		moves = append(moves, KeyMigration{From: key, To: keys.link(key)})
+	}
+	if opts.ClickStream != "" {
+		moves = append(moves, KeyMigration{From: opts.ClickStream, To: keys.Key(opts.ClickStream)})
+	}
+	return applyMoves(ctx, client, moves, opts.DryRun, fn)
+}
+
+// ownedLinks returns the link keys held by owner indexes of either layout.
+func ownedLinks(ctx context.Context, client *redis.Client, keys Keyspace) (map[string]bool, error) {
+	owned := make(map[string]bool)
+	for _, match := range []string{"owner:*", keys.match("owner")} {
+		var cursor uint64
+		for {
+			batch, next, err := client.ScanType(ctx, cursor, match, scanBatch, "zset").Result()
+			if err != nil {
+				return nil, err
+			}
+			for _, index := range batch {
+				members, err := client.ZRange(ctx, index, 0, -1).Result()
+				if err != nil {
+					return nil, err
+				}
+				for _, member := range members {
+					owned[member] = true
+				}
+			}
+
+			cursor = next
+			if cursor == 0 {
+				break
+			}
+		}
+	}
+	return owned, nil
+}
+
+// legacyMoves returns where the keys of the unprefixed layout in batch go.
+// allLinks takes every string matching legacyLinkPattern for a link.
+func legacyMoves(ctx context.Context, client *redis.Client, keys Keyspace, owned map[string]bool, allLinks bool, batch []string) ([]KeyMigration, error) {
+	var moves []KeyMigration
+	var bare []string
+	for _, key := range batch {
+		if strings.HasPrefix(key, keys.prefix+":") {
+			continue
+		}
+		if legacyIndexes[key] {
+			moves = append(moves, KeyMigration{From: key, To: keys.Key(key)})
+			continue
+		}
+		if to, ok := legacyKey(keys, key); ok {
+			moves = append(moves, KeyMigration{From: key, To: to})
+			continue
+		}
+		if legacyLinkPattern.MatchString(key) {
+			bare = append(bare, key)
+		}
+	}
+	if len(bare) == 0 {
+		return moves, nil
+	}
+
+	// Only strings can be link URLs, and only those with metadata or an
+	// owner are known to be, unless allLinks vouches for all of them. The
+	// metadata may have been moved already.
+	pipe := client.Pipeline()
+	types := make([]*redis.StatusCmd, len(bare))
+	metas := make([]*redis.IntCmd, len(bare))
+	for i, key := range bare {
+		types[i] = pipe.Type(ctx, key)
+		metas[i] = pipe.Exists(ctx, "meta:"+key, keys.meta(key))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	for i, key := range bare {
+		if types[i].Val() == "string" && (allLinks || metas[i].Val() > 0 || owned[key]) {
+			moves = append(moves, KeyMigration{From: key, To: keys.link(key)})
+		}
+	}
+	return moves, nil
+}
+
+// legacyKey returns where a namespaced key of the unprefixed layout goes.
+func legacyKey(keys Keyspace, key string) (string, bool) {
+	for legacy, kind := range legacyKinds {
+		if rest, ok := strings.CutPrefix(key, legacy); ok {
+			return keys.prefix + ":" + kind + rest, true
+		}
+	}
+	return "", false
+}
+
+func applyMoves(ctx context.Context, client *redis.Client, moves []KeyMigration, dryRun bool, fn func(KeyMigration) error) error {
+	if len(moves) == 0 {
+		return nil
+	}
+	if dryRun {
+		pipe := client.Pipeline()
+		from := make([]*redis.IntCmd, len(moves))
+		to := make([]*redis.IntCmd, len(moves))
+		for i, move := range moves {
+			from[i] = pipe.Exists(ctx, move.From)
+			to[i] = pipe.Exists(ctx, move.To)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+		for i, move := range moves {
+			if from[i].Val() == 0 {
+				continue
+			}
+			move.Conflict = to[i].Val() > 0
+			if err := fn(move); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// A pipeline cannot fall back from EVALSHA when the script is not
+	// cached yet, so the script is sent along.
+	pipe := client.Pipeline()
+	cmds := make([]*redis.Cmd, len(moves))
+	for i, move := range moves {
+		cmds[i] = renameNX.Eval(ctx, pipe, []string{move.From, move.To})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	for i, move := range moves {
+		switch renamed, _ := cmds[i].Int64(); renamed {
+		case -1:
+			continue
+		case 0:
+			move.Conflict = true
+		}
+		if err := fn(move); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// legacyLayout fills mr with keys of the unprefixed layout and of another
+// application.
+func legacyLayout(t *testing.T, mr *miniredis.Miniredis) {
+	t.Helper()
+	mr.Set("abc123", "https://example.com")
+	mr.HSet("meta:abc123", "owner", "alice", "clicks", "3")
+	mr.ZAdd("owner:alice:created", 1, "abc123")
+	mr.ZAdd("owner:alice:created", 2, "owned")
+	mr.Set("owned", "https://example.org")
+	mr.Set("listed", "https://example.net")
+	mr.Set("session", "someone else's")
+	mr.Set("cache:page", "someone else's")
+	mr.SAdd("disabled", "abc123")
+	mr.Set("webhook:subscription:s1", "x")
+	mr.XAdd("clicks", "*", []string{"key", "abc123"})
+}
+
+func migrate(t *testing.T, client *redis.Client, opts MigrateOptions) []KeyMigration {
+	t.Helper()
+	var moves []KeyMigration
+	err := MigrateKeys(context.Background(), client, opts, func(m KeyMigration) error {
+		moves = append(moves, m)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("MigrateKeys failed: %v", err)
+	}
+	slices.SortFunc(moves, func(a, b KeyMigration) int {
+		return strings.Compare(a.From, b.From)
+	})
+	return moves
+}
+
+func TestMigrateKeys(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	legacyLayout(t, mr)
+	opts := MigrateOptions{Prefix: "app", Links: []string{"listed"}, ClickStream: "clicks"}
+
+	want := []KeyMigration{
+		{From: "abc123", To: "app:link:abc123"},
+		{From: "clicks", To: "app:clicks"},
+		{From: "disabled", To: "app:disabled"},
+		{From: "listed", To: "app:link:listed"},
+		{From: "meta:abc123", To: "app:meta:abc123"},
+		{From: "owned", To: "app:link:owned"},
+		{From: "owner:alice:created", To: "app:owner:alice:created"},
+		{From: "webhook:subscription:s1", To: "app:webhook:subscription:s1"},
+	}
+
+	dryRun := opts
+	dryRun.DryRun = true
+	if got := migrate(t, client, dryRun); !slices.Equal(got, want) {
+		t.Errorf("dry run reported\n%v\nwant\n%v", got, want)
+	}
+	if !mr.Exists("abc123") || mr.Exists("app:link:abc123") {
+		t.Fatal("expected a dry run to leave the keys alone")
+	}
+
+	if got := migrate(t, client, opts); !slices.Equal(got, want) {
+		t.Errorf("migration moved\n%v\nwant\n%v", got, want)
+	}
+	left := slices.DeleteFunc(mr.Keys(), func(key string) bool {
+		return strings.HasPrefix(key, "app:")
+	})
+	if want := []string{"cache:page", "session"}; !slices.Equal(left, want) {
+		t.Errorf("expected only the other application's keys to stay, got %v", left)
+	}
+
+	rr := NewRedisRepository(client, WithKeyPrefix("app")).(*redisRepo)
+	link, err := rr.GetLink(context.Background(), "abc123")
+	if err != nil || link.URL != "https://example.com" || link.Owner != "alice" || link.Clicks != 3 {
+		t.Errorf("GetLink() = %+v, %v", link, err)
+	}
+
+	// Running again finds nothing left to do.
+	if got := migrate(t, client, opts); len(got) != 0 {
+		t.Errorf("expected a second run to move nothing, got %v", got)
+	}
+}
+
+func TestMigrateKeys_MetadataAlreadyMoved(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	// An interrupted run moved the metadata but not the link.
+	mr.Set("abc123", "https://example.com")
+	mr.HSet("shortener:meta:abc123", "owner", "")
+
+	got := migrate(t, client, MigrateOptions{})
+	if want := []KeyMigration{{From: "abc123", To: "shortener:link:abc123"}}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMigrateKeys_Conflict(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	mr.Set("abc123", "https://old.example")
+	mr.HSet("meta:abc123", "owner", "")
+	mr.Set("shortener:link:abc123", "https://new.example")
+
+	for _, dryRun := range []bool{true, false} {
+		got := migrate(t, client, MigrateOptions{DryRun: dryRun})
+		want := []KeyMigration{
+			{From: "abc123", To: "shortener:link:abc123", Conflict: true},
+			{From: "meta:abc123", To: "shortener:meta:abc123"},
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("dry run %v: got %v, want %v", dryRun, got, want)
+		}
+	}
+	if v, _ := mr.Get("shortener:link:abc123"); v != "https://new.example" {
+		t.Errorf("expected the existing key to be kept, got %q", v)
+	}
+	if !mr.Exists("abc123") {
+		t.Error("expected the conflicting key to stay where it was")
+	}
+}
+
+func TestMigrateKeys_DomainWithPort(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	mr.Set("go.example:8080/abc123", "https://example.com")
+	mr.HSet("meta:go.example:8080/abc123", "owner", "")
+
+	got := migrate(t, client, MigrateOptions{})
+	want := []KeyMigration{
+		{From: "go.example:8080/abc123", To: "shortener:link:go.example:8080/abc123"},
+		{From: "meta:go.example:8080/abc123", To: "shortener:meta:go.example:8080/abc123"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMigrateKeys_AllLinks(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	// Links of the baseline layout had neither metadata nor an owner.
+	mr.Set("abc123", "https://example.com")
+	mr.Set("go.example/abc123", "https://example.org")
+	mr.Set("go.example:8080/xyz", "https://example.net")
+	mr.Set("cache:page", "someone else's")
+	mr.Set("not a key", "someone else's")
+	mr.SAdd("tags", "someone else's")
+
+	if got := migrate(t, client, MigrateOptions{}); len(got) != 0 {
+		t.Errorf("expected nothing to move without AllLinks, got %v", got)
+	}
+
+	got := migrate(t, client, MigrateOptions{AllLinks: true})
+	want := []KeyMigration{
+		{From: "abc123", To: "shortener:link:abc123"},
+		{From: "go.example/abc123", To: "shortener:link:go.example/abc123"},
+		{From: "go.example:8080/xyz", To: "shortener:link:go.example:8080/xyz"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -2,31 +2,1020 @@ package repository
 
 import (
 	"context"
-	"fmt"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+var (
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a key is already taken by another URL,
+	// or by the same URL with other options.
+	ErrConflict = errors.New("key already exists")
+	// ErrExhausted is returned when a click-limited link has no uses left.
+	ErrExhausted = errors.New("link has no uses left")
+)
+
+type Link struct {
+	Key       string
+	URL       string
+	Owner     string
+	CreatedAt time.Time
+	// ExpiresAt is zero for links that never expire. It is filled in by
+	// GetLink and ScanLinks.
+	ExpiresAt time.Time
+	Clicks    int64
+	// DisabledReason is set when the link was taken out of service, usually
+	// because its destination started failing the destination policy.
+	DisabledReason string
+	DisabledAt     time.Time
+	// ApprovedURL is the destination an admin last re-enabled the link for.
+	// Rechecks leave the link alone while it still points there.
+	ApprovedURL string
+	// Interstitial is how long visitors see a preview page before being
+	// sent on. Zero redirects straight away.
+	Interstitial time.Duration
+	// PasswordHash is the bcrypt hash of the password protecting the link,
+	// if any.
+	PasswordHash string
+	// MaxClicks limits how often the link can be followed. Zero means no
+	// limit; RemainingClicks is only meaningful when it is set.
+	MaxClicks       int64
+	RemainingClicks int64
+	// NotBefore and NotAfter bound when the link may be followed. Either
+	// may be zero to leave that side of the window open.
+	NotBefore time.Time
+	NotAfter  time.Time
+	// FallbackURL is where visitors outside the activation window are sent
+	// instead of the destination.
+	FallbackURL string
+	// Rules send visitors elsewhere than URL. The first matching rule wins.
+	Rules []Rule
+	// Variants split redirects between several destinations by weight.
+	// URL stays the link's canonical destination, shown in previews.
+	Variants []Variant
+	// Variant is the 1-based index of the variant a visit was sent to. It
+	// is set by the service, never stored.
+	Variant int
+	// UTM parameters are added to the destination unless it sets them.
+	UTM UTM
+	// ForwardQuery decides how the visitor's query string is merged into
+	// the destination; empty drops it.
+	ForwardQuery QueryForwarding
+	// ForwardPath appends anything after the key in the short URL to the
+	// destination's path.
+	ForwardPath bool
+	// Signed links only resolve through a URL carrying their signature.
+	Signed bool
+}
+
+type UTM struct {
+	Source   string `json:"utm_source,omitempty"`
+	Medium   string `json:"utm_medium,omitempty"`
+	Campaign string `json:"utm_campaign,omitempty"`
+	Term     string `json:"utm_term,omitempty"`
+	Content  string `json:"utm_content,omitempty"`
+}
+
+// QueryForwarding is how incoming query parameters that the destination
+// already has are resolved.
+type QueryForwarding string
+
+const (
+	// ForwardKeep only adds parameters the destination does not set.
+	ForwardKeep QueryForwarding = "keep"
+	// ForwardOverride replaces the destination's values.
+	ForwardOverride QueryForwarding = "override"
+	// ForwardAppend keeps both values.
+	ForwardAppend QueryForwarding = "append"
+)
+
+// Variant is one destination of an A/B split.
+type Variant struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+	// Clicks counts the redirects to this variant. It is kept apart from
+	// the stored definition and filled in by GetLink and ScanLinks.
+	Clicks int64 `json:"-"`
+}
+
+// Rule sends visitors that match all of its conditions to URL. Conditions
+// left empty match every visitor.
+type Rule struct {
+	Platform string `json:"platform,omitempty"`
+	Language string `json:"language,omitempty"`
+	Country  string `json:"country,omitempty"`
+	// From and To bound the time of day as HH:MM in Timezone, UTC if empty.
+	// A From later than To spans midnight.
+	From     string `json:"from,omitempty"`
+	To       string `json:"to,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+	URL      string `json:"url"`
+}
+
+type APIKey struct {
+	// ID identifies the key without revealing it. It is the SHA-256 of the
+	// token, which is what the key is stored under.
+	ID        string
+	Owner     string
+	Admin     bool
+	CreatedAt time.Time
+}
+
+type SaveRequest struct {
+	Link Link
+	TTL  time.Duration
+}
+
+type SortField string
+
+const (
+	SortByCreated SortField = "created"
+	SortByClicks  SortField = "clicks"
+)
+
+type ListOptions struct {
+	Sort      SortField
+	Ascending bool
+	Offset    int64
+	Limit     int64
+}
+
 type Repository interface {
-	Save(ctx context.Context, key string, url string, ttl time.Duration) error
+	Save(ctx context.Context, link Link, ttl time.Duration) error
+	// SaveBatch saves all links in two round trips and returns one error per
+	// request, in order.
+	SaveBatch(ctx context.Context, reqs []SaveRequest) []error
 	Get(ctx context.Context, key string) (string, error)
+	GetLink(ctx context.Context, key string) (Link, error)
+	Update(ctx context.Context, key string, url string) error
+	Delete(ctx context.Context, key string) error
+	// IncrClicks counts a click in the link's total, its hourly and daily
+	// time-series buckets and the day's rankings. referrer is the referring
+	// domain, empty when unknown. It returns the link's total including
+	// this click.
+	IncrClicks(ctx context.Context, key string, referrer string) (int64, error)
+	// IncrVariantClicks counts a redirect to the variant with the given
+	// 0-based index.
+	IncrVariantClicks(ctx context.Context, key string, variant int) error
+	// ConsumeClick atomically uses up one click of a click-limited link and
+	// returns how many are left, or ErrExhausted when none were or the
+	// count is missing.
+	ConsumeClick(ctx context.Context, key string) (int64, error)
+	// ClickSeries returns the non-empty buckets of granularity g starting in
+	// [from, to), keyed by their start in UTC.
+	ClickSeries(ctx context.Context, key string, g Granularity, from, to time.Time) (map[time.Time]int64, error)
+	// TopLinks and TopReferrers return the n most clicked link keys and
+	// referring domains of the UTC days overlapping [from, to).
+	TopLinks(ctx context.Context, from, to time.Time, n int) ([]Ranked, error)
+	TopReferrers(ctx context.Context, from, to time.Time, n int) ([]Ranked, error)
+	// LinksCreated returns the number of links created on each UTC day
+	// overlapping [from, to), leaving out days without any.
+	LinksCreated(ctx context.Context, from, to time.Time) (map[time.Time]int64, error)
+	// AddPasswordAttempt atomically counts a password attempt for a link
+	// before it is checked and returns the attempts in the current window,
+	// including this one. The count resets once window has passed since the
+	// first attempt.
+	AddPasswordAttempt(ctx context.Context, key string, window time.Duration) (int64, error)
+	// ResetPasswordFailures clears the count after a right password.
+	ResetPasswordFailures(ctx context.Context, key string) error
+	// ListByOwner returns a page of the owner's links and the offset of the
+	// next page, which is zero once the listing is exhausted.
+	ListByOwner(ctx context.Context, owner string, opts ListOptions) ([]Link, int64, error)
+	GetAPIKey(ctx context.Context, token string) (APIKey, error)
+	CreateAPIKey(ctx context.Context, token string, key APIKey) (APIKey, error)
+	DeleteAPIKey(ctx context.Context, id string) error
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	// PurgeOrphans removes metadata and owner index entries left behind by
+	// expired links and returns the links cleaned up. Only their Key and,
+	// where it is still known, Owner are set.
+	PurgeOrphans(ctx context.Context) ([]Link, error)
+	// ScanLinks calls fn for every stored link using SCAN, so Redis is never
+	// blocked. Links created or deleted during the scan may be missed or
+	// reported twice.
+	ScanLinks(ctx context.Context, fn func(Link) error) error
+	Disable(ctx context.Context, key string, reason string, at time.Time) error
+	// Enable puts a disabled link back into service and marks its current
+	// URL as approved.
+	Enable(ctx context.Context, key string) error
+	ListDisabled(ctx context.Context) ([]Link, error)
+	// SaveDomain registers a domain or updates its settings.
+	SaveDomain(ctx context.Context, d Domain) error
+	GetDomain(ctx context.Context, name string) (Domain, error)
+	// DeleteDomain unregisters a domain. Its links stay stored but cannot
+	// be reached until it is registered again.
+	DeleteDomain(ctx context.Context, name string) error
+	ListDomains(ctx context.Context) ([]Domain, error)
 }
 
 type redisRepo struct {
-	client *redis.Client
+	client    *redis.Client
+	retention Retention
+	keys      Keyspace
+}
+
+// keyDomain returns the domain of a stored key, see scoped.
+func keyDomain(key string) string {
+	domain, _, found := strings.Cut(key, "/")
+	if !found {
+		return ""
+	}
+	return domain
+}
+
+func apiKeyID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 func (rr *redisRepo) Get(ctx context.Context, key string) (string, error) {
-	return rr.client.Get(ctx, key).Result()
+	url, err := rr.client.Get(ctx, rr.keys.link(scoped(ctx, key))).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrNotFound
+	}
+	return url, err
+}
+
+func (rr *redisRepo) Save(ctx context.Context, link Link, ttl time.Duration) error {
+	return rr.SaveBatch(ctx, []SaveRequest{{Link: link, TTL: ttl}})[0]
 }
 
-func (rr *redisRepo) Save(ctx context.Context, key string, url string, ttl time.Duration) error {
-	return rr.client.Set(ctx, key, url, ttl).Err()
+func (rr *redisRepo) SaveBatch(ctx context.Context, reqs []SaveRequest) []error {
+	errs := make([]error, len(reqs))
+
+	// Claim every key first. A key that already holds the same URL is not a
+	// conflict, so shortening a URL twice stays idempotent, as long as the
+	// link was saved with the same options. Its metadata belongs to whoever
+	// created it and is left alone.
+	pipe := rr.client.Pipeline()
+	cmds := make([]*redis.StatusCmd, len(reqs))
+	for i, req := range reqs {
+		cmds[i] = pipe.SetArgs(ctx, rr.keys.link(scoped(ctx, req.Link.Key)), req.Link.URL, redis.SetArgs{
+			Mode: "NX",
+			TTL:  req.TTL,
+			Get:  true,
+		})
+	}
+	// Exec only reports the first failure; each command is checked below.
+	_, _ = pipe.Exec(ctx)
+
+	var same []int
+	for i, req := range reqs {
+		existing, err := cmds[i].Result()
+		switch {
+		case errors.Is(err, redis.Nil):
+		case err != nil:
+			errs[i] = err
+		case existing != req.Link.URL:
+			errs[i] = ErrConflict
+		default:
+			same = append(same, i)
+		}
+	}
+	if err := rr.checkSameOptions(ctx, reqs, same, errs); err != nil {
+		for _, i := range same {
+			errs[i] = err
+		}
+	}
+
+	pipe = rr.client.TxPipeline()
+	created := 0
+	for i, req := range reqs {
+		if errs[i] != nil || slices.Contains(same, i) {
+			continue
+		}
+		created++
+		link := req.Link
+		link.Key = scoped(ctx, link.Key)
+		rr.writeMeta(ctx, pipe, link, req.TTL)
+	}
+	if created > 0 {
+		rr.incrCreated(ctx, pipe, created, time.Now())
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		for i := range errs {
+			if errs[i] == nil {
+				errs[i] = err
+			}
+		}
+	}
+
+	return errs
 }
 
-func NewRedisRepository(client *redis.Client) Repository {
-	return &redisRepo{client: client}
+// checkSameOptions sets errs[i] to ErrConflict for the requests at indexes
+// whose key already holds their URL but was saved with other options, so that
+// a caller asking for a password, a schedule or an expiry is never handed an
+// existing link without them.
+func (rr *redisRepo) checkSameOptions(ctx context.Context, reqs []SaveRequest, indexes []int, errs []error) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+	pipe := rr.client.Pipeline()
+	metas := make([]*redis.MapStringStringCmd, len(indexes))
+	ttls := make([]*redis.DurationCmd, len(indexes))
+	for j, i := range indexes {
+		key := scoped(ctx, reqs[i].Link.Key)
+		metas[j] = pipe.HGetAll(ctx, rr.keys.meta(key))
+		ttls[j] = pipe.PTTL(ctx, rr.keys.link(key))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	for j, i := range indexes {
+		req := reqs[i]
+		existing := linkFromMeta(req.Link.Key, req.Link.URL, metas[j].Val())
+		// A remaining lifetime cannot be compared with a requested one, only
+		// whether the link expires at all.
+		if !sameOptions(existing, req.Link) || (ttls[j].Val() > 0) != (req.TTL > 0) {
+			errs[i] = ErrConflict
+		}
+	}
+	return nil
+}
+
+// sameOptions reports whether a and b behave the same for visitors and
+// belong to the same owner. Counters and timestamps are not compared.
+func sameOptions(a, b Link) bool {
+	return a.Owner == b.Owner &&
+		a.Interstitial == b.Interstitial &&
+		a.PasswordHash == b.PasswordHash &&
+		a.MaxClicks == b.MaxClicks &&
+		a.NotBefore.Equal(b.NotBefore) &&
+		a.NotAfter.Equal(b.NotAfter) &&
+		a.FallbackURL == b.FallbackURL &&
+		slices.Equal(a.Rules, b.Rules) &&
+		slices.EqualFunc(a.Variants, b.Variants, func(x, y Variant) bool {
+			return x.URL == y.URL && x.Weight == y.Weight
+		}) &&
+		a.UTM == b.UTM &&
+		a.ForwardQuery == b.ForwardQuery &&
+		a.ForwardPath == b.ForwardPath &&
+		a.Signed == b.Signed
+}
+
+func (rr *redisRepo) writeMeta(ctx context.Context, pipe redis.Pipeliner, link Link, ttl time.Duration) {
+	meta := rr.keys.meta(link.Key)
+	pipe.HSet(ctx, meta, "owner", link.Owner)
+	pipe.HSetNX(ctx, meta, "created_at", link.CreatedAt.Unix())
+	if ttl > 0 {
+		pipe.Expire(ctx, meta, ttl)
+	}
+	if link.Owner != "" {
+		domain := keyDomain(link.Key)
+		pipe.ZAddNX(ctx, rr.keys.ownerIndex(link.Owner, SortByCreated, domain), redis.Z{
+			Score:  float64(link.CreatedAt.Unix()),
+			Member: link.Key,
+		})
+		pipe.ZAddNX(ctx, rr.keys.ownerIndex(link.Owner, SortByClicks, domain), redis.Z{
+			Score:  float64(link.Clicks),
+			Member: link.Key,
+		})
+	}
+	if link.Clicks > 0 {
+		pipe.HSetNX(ctx, meta, "clicks", link.Clicks)
+	}
+	if link.Interstitial > 0 {
+		pipe.HSet(ctx, meta, "interstitial", int64(link.Interstitial/time.Second))
+	}
+	if link.PasswordHash != "" {
+		pipe.HSet(ctx, meta, "password_hash", link.PasswordHash)
+	}
+	if link.MaxClicks > 0 {
+		pipe.HSetNX(ctx, meta, "max_clicks", link.MaxClicks)
+		pipe.HSetNX(ctx, meta, "remaining", link.RemainingClicks)
+	}
+	if !link.NotBefore.IsZero() {
+		pipe.HSet(ctx, meta, "not_before", link.NotBefore.Unix())
+	}
+	if !link.NotAfter.IsZero() {
+		pipe.HSet(ctx, meta, "not_after", link.NotAfter.Unix())
+	}
+	if link.FallbackURL != "" {
+		pipe.HSet(ctx, meta, "fallback_url", link.FallbackURL)
+	}
+	if len(link.Rules) > 0 {
+		// Rule is plain data, so marshalling it cannot fail.
+		rules, _ := json.Marshal(link.Rules)
+		pipe.HSet(ctx, meta, "rules", rules)
+	}
+	if len(link.Variants) > 0 {
+		variants, _ := json.Marshal(link.Variants)
+		pipe.HSet(ctx, meta, "variants", variants)
+	}
+	if link.UTM != (UTM{}) {
+		utm, _ := json.Marshal(link.UTM)
+		pipe.HSet(ctx, meta, "utm", utm)
+	}
+	if link.ForwardQuery != "" {
+		pipe.HSet(ctx, meta, "forward_query", string(link.ForwardQuery))
+	}
+	if link.ForwardPath {
+		pipe.HSet(ctx, meta, "forward_path", 1)
+	}
+	if link.Signed {
+		pipe.HSet(ctx, meta, "signed", 1)
+	}
+	if link.ApprovedURL != "" {
+		pipe.HSet(ctx, meta, "approved_url", link.ApprovedURL)
+	}
+	if link.DisabledReason != "" {
+		pipe.HSet(ctx, meta, "disabled_reason", link.DisabledReason, "disabled_at", link.DisabledAt.Unix())
+		pipe.SAdd(ctx, rr.keys.disabledIndex(), link.Key)
+	}
+}
+
+func (rr *redisRepo) GetLink(ctx context.Context, key string) (Link, error) {
+	stored := scoped(ctx, key)
+	pipe := rr.client.Pipeline()
+	urlCmd := pipe.Get(ctx, rr.keys.link(stored))
+	metaCmd := pipe.HGetAll(ctx, rr.keys.meta(stored))
+	ttlCmd := pipe.PTTL(ctx, rr.keys.link(stored))
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return Link{}, err
+	}
+
+	url, err := urlCmd.Result()
+	if errors.Is(err, redis.Nil) {
+		return Link{}, ErrNotFound
+	}
+	if err != nil {
+		return Link{}, err
+	}
+
+	link := linkFromMeta(key, url, metaCmd.Val())
+	if ttl := ttlCmd.Val(); ttl > 0 {
+		link.ExpiresAt = time.Now().UTC().Add(ttl).Truncate(time.Second)
+	}
+	return link, nil
+}
+
+func linkFromMeta(key string, url string, meta map[string]string) Link {
+	link := Link{Key: key, URL: url, Owner: meta["owner"]}
+	if created, err := strconv.ParseInt(meta["created_at"], 10, 64); err == nil {
+		link.CreatedAt = time.Unix(created, 0).UTC()
+	}
+	link.Clicks, _ = strconv.ParseInt(meta["clicks"], 10, 64)
+	link.DisabledReason = meta["disabled_reason"]
+	if disabled, err := strconv.ParseInt(meta["disabled_at"], 10, 64); err == nil {
+		link.DisabledAt = time.Unix(disabled, 0).UTC()
+	}
+	link.ApprovedURL = meta["approved_url"]
+	if seconds, err := strconv.ParseInt(meta["interstitial"], 10, 64); err == nil {
+		link.Interstitial = time.Duration(seconds) * time.Second
+	}
+	link.PasswordHash = meta["password_hash"]
+	link.MaxClicks, _ = strconv.ParseInt(meta["max_clicks"], 10, 64)
+	link.RemainingClicks, _ = strconv.ParseInt(meta["remaining"], 10, 64)
+	if notBefore, err := strconv.ParseInt(meta["not_before"], 10, 64); err == nil {
+		link.NotBefore = time.Unix(notBefore, 0).UTC()
+	}
+	if notAfter, err := strconv.ParseInt(meta["not_after"], 10, 64); err == nil {
+		link.NotAfter = time.Unix(notAfter, 0).UTC()
+	}
+	link.FallbackURL = meta["fallback_url"]
+	if rules := meta["rules"]; rules != "" {
+		_ = json.Unmarshal([]byte(rules), &link.Rules)
+	}
+	if variants := meta["variants"]; variants != "" {
+		_ = json.Unmarshal([]byte(variants), &link.Variants)
+		for i := range link.Variants {
+			link.Variants[i].Clicks, _ = strconv.ParseInt(meta[variantClicksField(i)], 10, 64)
+		}
+	}
+	if utm := meta["utm"]; utm != "" {
+		_ = json.Unmarshal([]byte(utm), &link.UTM)
+	}
+	link.ForwardQuery = QueryForwarding(meta["forward_query"])
+	link.ForwardPath = meta["forward_path"] == "1"
+	link.Signed = meta["signed"] == "1"
+	return link
+}
+
+func (rr *redisRepo) Update(ctx context.Context, key string, url string) error {
+	err := rr.client.SetArgs(ctx, rr.keys.link(scoped(ctx, key)), url, redis.SetArgs{Mode: "XX", KeepTTL: true}).Err()
+	if errors.Is(err, redis.Nil) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (rr *redisRepo) Delete(ctx context.Context, key string) error {
+	key = scoped(ctx, key)
+	owner, err := rr.client.HGet(ctx, rr.keys.meta(key), "owner").Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+
+	_, err = rr.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, rr.keys.link(key), rr.keys.meta(key))
+		// A new link under the same key must not inherit the old clicks.
+		pipe.Del(ctx, rr.seriesKeys(key, time.Now())...)
+		for _, ranking := range rr.rankingKeys(time.Now()) {
+			pipe.ZRem(ctx, ranking, key)
+		}
+		pipe.SRem(ctx, rr.keys.disabledIndex(), key)
+		if owner != "" {
+			pipe.ZRem(ctx, rr.keys.ownerIndex(owner, SortByCreated, keyDomain(key)), key)
+			pipe.ZRem(ctx, rr.keys.ownerIndex(owner, SortByClicks, keyDomain(key)), key)
+		}
+		return nil
+	})
+	return err
+}
+
+func (rr *redisRepo) IncrClicks(ctx context.Context, key string, referrer string) (int64, error) {
+	key = scoped(ctx, key)
+	now := time.Now()
+	pipe := rr.client.Pipeline()
+	clicksCmd := pipe.HIncrBy(ctx, rr.keys.meta(key), "clicks", 1)
+	rr.incrSeries(ctx, pipe, key, now)
+	rr.incrRankings(ctx, pipe, key, referrer, now)
+	ownerCmd := pipe.HGet(ctx, rr.keys.meta(key), "owner")
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return 0, err
+	}
+
+	if owner := ownerCmd.Val(); owner != "" {
+		if err := rr.client.ZIncrBy(ctx, rr.keys.ownerIndex(owner, SortByClicks, keyDomain(key)), 1, key).Err(); err != nil {
+			return 0, err
+		}
+	}
+	return clicksCmd.Val(), nil
+}
+
+func (rr *redisRepo) IncrVariantClicks(ctx context.Context, key string, variant int) error {
+	return rr.client.HIncrBy(ctx, rr.keys.meta(scoped(ctx, key)), variantClicksField(variant), 1).Err()
+}
+
+func variantClicksField(variant int) string {
+	return "variant_clicks:" + strconv.Itoa(variant)
+}
+
+// consumeClick returns the uses left after taking one and -2 when none were
+// left. It is only run for click-limited links, so a missing count means the
+// metadata was lost, and the link fails closed rather than becoming
+// unlimited.
+var consumeClick = redis.NewScript(`
+local left = tonumber(redis.call('HGET', KEYS[1], 'remaining'))
+if not left or left <= 0 then
+	return -2
+end
+redis.call('HINCRBY', KEYS[1], 'remaining', -1)
+return left - 1
+`)
+
+func (rr *redisRepo) ConsumeClick(ctx context.Context, key string) (int64, error) {
+	left, err := consumeClick.Run(ctx, rr.client, []string{rr.keys.meta(scoped(ctx, key))}).Int64()
+	if err != nil {
+		return 0, err
+	}
+	if left == -2 {
+		return 0, ErrExhausted
+	}
+	return left, nil
+}
+
+func (rr *redisRepo) AddPasswordAttempt(ctx context.Context, key string, window time.Duration) (int64, error) {
+	key = scoped(ctx, key)
+	pipe := rr.client.TxPipeline()
+	incr := pipe.Incr(ctx, rr.keys.passwordFailures(key))
+	pipe.ExpireNX(ctx, rr.keys.passwordFailures(key), window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (rr *redisRepo) ResetPasswordFailures(ctx context.Context, key string) error {
+	return rr.client.Del(ctx, rr.keys.passwordFailures(scoped(ctx, key))).Err()
+}
+
+func (rr *redisRepo) ListByOwner(ctx context.Context, owner string, opts ListOptions) ([]Link, int64, error) {
+	sort := opts.Sort
+	if sort == "" {
+		sort = SortByCreated
+	}
+
+	// Fetch one extra member to find out whether another page follows.
+	keys, err := rr.client.ZRangeArgs(ctx, redis.ZRangeArgs{
+		Key:   rr.keys.ownerIndex(owner, sort, DomainFrom(ctx)),
+		Start: opts.Offset,
+		Stop:  opts.Offset + opts.Limit,
+		Rev:   !opts.Ascending,
+	}).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var next int64
+	if int64(len(keys)) > opts.Limit {
+		keys = keys[:opts.Limit]
+		next = opts.Offset + opts.Limit
+	}
+
+	pipe := rr.client.Pipeline()
+	urlCmds := make([]*redis.StringCmd, len(keys))
+	metaCmds := make([]*redis.MapStringStringCmd, len(keys))
+	for i, key := range keys {
+		urlCmds[i] = pipe.Get(ctx, rr.keys.link(key))
+		metaCmds[i] = pipe.HGetAll(ctx, rr.keys.meta(key))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, 0, err
+	}
+
+	links := make([]Link, 0, len(keys))
+	for i, key := range keys {
+		url, err := urlCmds[i].Result()
+		if err != nil {
+			// The link expired but is still referenced by the owner index.
+			continue
+		}
+		links = append(links, linkFromMeta(unscoped(ctx, key), url, metaCmds[i].Val()))
+	}
+
+	return links, next, nil
+}
+
+func (rr *redisRepo) GetAPIKey(ctx context.Context, token string) (APIKey, error) {
+	return rr.getAPIKey(ctx, apiKeyID(token))
+}
+
+func (rr *redisRepo) getAPIKey(ctx context.Context, id string) (APIKey, error) {
+	fields, err := rr.client.HGetAll(ctx, rr.keys.apiKey(id)).Result()
+	if err != nil {
+		return APIKey{}, err
+	}
+	if len(fields) == 0 {
+		return APIKey{}, ErrNotFound
+	}
+	return apiKeyFromFields(id, fields), nil
+}
+
+func apiKeyFromFields(id string, fields map[string]string) APIKey {
+	key := APIKey{ID: id, Owner: fields["owner"]}
+	key.Admin, _ = strconv.ParseBool(fields["admin"])
+	if created, err := strconv.ParseInt(fields["created_at"], 10, 64); err == nil {
+		key.CreatedAt = time.Unix(created, 0).UTC()
+	}
+	return key
+}
+
+func (rr *redisRepo) CreateAPIKey(ctx context.Context, token string, key APIKey) (APIKey, error) {
+	key.ID = apiKeyID(token)
+	_, err := rr.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, rr.keys.apiKey(key.ID),
+			"owner", key.Owner,
+			"admin", strconv.FormatBool(key.Admin),
+			"created_at", key.CreatedAt.Unix(),
+		)
+		pipe.SAdd(ctx, rr.keys.apiKeysIndex(), key.ID)
+		return nil
+	})
+	if err != nil {
+		return APIKey{}, err
+	}
+	return key, nil
+}
+
+func (rr *redisRepo) DeleteAPIKey(ctx context.Context, id string) error {
+	var del *redis.IntCmd
+	_, err := rr.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		del = pipe.Del(ctx, rr.keys.apiKey(id))
+		pipe.SRem(ctx, rr.keys.apiKeysIndex(), id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if del.Val() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (rr *redisRepo) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	ids, err := rr.client.SMembers(ctx, rr.keys.apiKeysIndex()).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := rr.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.HGetAll(ctx, rr.keys.apiKey(id))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	keys := make([]APIKey, 0, len(ids))
+	for i, id := range ids {
+		if fields := cmds[i].Val(); len(fields) > 0 {
+			keys = append(keys, apiKeyFromFields(id, fields))
+		}
+	}
+	return keys, nil
+}
+
+func (rr *redisRepo) PurgeOrphans(ctx context.Context) ([]Link, error) {
+	// purged maps the keys cleaned up so far to their owners.
+	purged := make(map[string]string)
+
+	// Metadata of links whose URL key expired.
+	err := rr.scanKeys(ctx, rr.keys.match("meta"), "hash", func(metas []string) error {
+		links := make([]string, len(metas))
+		for i, meta := range metas {
+			links[i] = strings.TrimPrefix(meta, rr.keys.meta(""))
+		}
+
+		orphans, err := rr.missing(ctx, links)
+		if err != nil {
+			return err
+		}
+
+		pipe := rr.client.Pipeline()
+		owners := make([]*redis.StringCmd, len(orphans))
+		for i, key := range orphans {
+			owners[i] = pipe.HGet(ctx, rr.keys.meta(key), "owner")
+			pipe.Del(ctx, rr.keys.meta(key))
+		}
+		if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+
+		pipe = rr.client.Pipeline()
+		for i, key := range orphans {
+			owner := owners[i].Val()
+			purged[key] = owner
+			if owner != "" {
+				pipe.ZRem(ctx, rr.keys.ownerIndex(owner, SortByCreated, keyDomain(key)), key)
+				pipe.ZRem(ctx, rr.keys.ownerIndex(owner, SortByClicks, keyDomain(key)), key)
+			}
+		}
+		_, err = pipe.Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Owner index entries of links whose metadata expired with them.
+	err = rr.scanKeys(ctx, rr.keys.match("owner"), "zset", func(indexes []string) error {
+		for _, index := range indexes {
+			if err := rr.purgeIndex(ctx, index, purged); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	disabled, err := rr.client.SMembers(ctx, rr.keys.disabledIndex()).Result()
+	if err != nil {
+		return nil, err
+	}
+	orphans, err := rr.missing(ctx, disabled)
+	if err != nil {
+		return nil, err
+	}
+	if len(orphans) > 0 {
+		if err := rr.client.SRem(ctx, rr.keys.disabledIndex(), toAny(orphans)...).Err(); err != nil {
+			return nil, err
+		}
+		for _, key := range orphans {
+			if _, ok := purged[key]; !ok {
+				purged[key] = ""
+			}
+		}
+	}
+
+	links := make([]Link, 0, len(purged))
+	for key, owner := range purged {
+		links = append(links, Link{Key: key, Owner: owner})
+	}
+	return links, nil
+}
+
+func (rr *redisRepo) purgeIndex(ctx context.Context, index string, purged map[string]string) error {
+	// Index keys are "<prefix>:owner:<owner>:<sort>", followed by ":<domain>"
+	// outside the default namespace, and owners never contain ":".
+	owner, _, _ := strings.Cut(strings.TrimPrefix(index, rr.keys.Key("owner", "")), ":")
+
+	var cursor uint64
+	for {
+		pairs, next, err := rr.client.ZScan(ctx, index, cursor, "*", scanBatch).Result()
+		if err != nil {
+			return err
+		}
+
+		// ZSCAN returns members and scores interleaved.
+		members := make([]string, 0, len(pairs)/2)
+		for i := 0; i < len(pairs); i += 2 {
+			members = append(members, pairs[i])
+		}
+
+		orphans, err := rr.missing(ctx, members)
+		if err != nil {
+			return err
+		}
+		if len(orphans) > 0 {
+			if err := rr.client.ZRem(ctx, index, toAny(orphans)...).Err(); err != nil {
+				return err
+			}
+			for _, key := range orphans {
+				purged[key] = owner
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// missing returns the link keys that no longer exist.
+func (rr *redisRepo) missing(ctx context.Context, keys []string) ([]string, error) {
+	pipe := rr.client.Pipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Exists(ctx, rr.keys.link(key))
+	}
+	if len(keys) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var missing []string
+	for i, key := range keys {
+		if cmds[i].Val() == 0 {
+			missing = append(missing, key)
+		}
+	}
+	return missing, nil
+}
+
+func toAny(keys []string) []any {
+	members := make([]any, len(keys))
+	for i, key := range keys {
+		members[i] = key
+	}
+	return members
+}
+
+// scanBatch is the COUNT hint passed to SCAN.
+const scanBatch = 500
+
+func (rr *redisRepo) ScanLinks(ctx context.Context, fn func(Link) error) error {
+	return rr.scanKeys(ctx, rr.keys.match("link"), "string", func(keys []string) error {
+		for i, key := range keys {
+			keys[i] = strings.TrimPrefix(key, rr.keys.link(""))
+		}
+		return rr.emitLinks(ctx, keys, fn)
+	})
+}
+
+// scanKeys calls fn with every batch of keys of the given type matching
+// pattern.
+func (rr *redisRepo) scanKeys(ctx context.Context, pattern string, keyType string, fn func([]string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := rr.client.ScanType(ctx, cursor, pattern, scanBatch, keyType).Result()
+		if err != nil {
+			return err
+		}
+
+		if len(keys) > 0 {
+			if err := fn(keys); err != nil {
+				return err
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// emitLinks calls fn for every link of keys that still exists.
+func (rr *redisRepo) emitLinks(ctx context.Context, links []string, fn func(Link) error) error {
+	if len(links) == 0 {
+		return nil
+	}
+
+	pipe := rr.client.Pipeline()
+	urlCmds := make([]*redis.StringCmd, len(links))
+	metaCmds := make([]*redis.MapStringStringCmd, len(links))
+	ttlCmds := make([]*redis.DurationCmd, len(links))
+	for i, key := range links {
+		urlCmds[i] = pipe.Get(ctx, rr.keys.link(key))
+		metaCmds[i] = pipe.HGetAll(ctx, rr.keys.meta(key))
+		ttlCmds[i] = pipe.PTTL(ctx, rr.keys.link(key))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for i, key := range links {
+		url, err := urlCmds[i].Result()
+		if err != nil {
+			// Expired or deleted since SCAN returned it.
+			continue
+		}
+
+		link := linkFromMeta(unscoped(ctx, key), url, metaCmds[i].Val())
+		if ttl := ttlCmds[i].Val(); ttl > 0 {
+			link.ExpiresAt = now.Add(ttl).Truncate(time.Second)
+		}
+		if err := fn(link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rr *redisRepo) Disable(ctx context.Context, key string, reason string, at time.Time) error {
+	key = scoped(ctx, key)
+	ttl, err := rr.client.PTTL(ctx, rr.keys.link(key)).Result()
+	if err != nil {
+		return err
+	}
+	// PTTL reports -2 for a missing key.
+	if ttl == -2 {
+		return ErrNotFound
+	}
+
+	_, err = rr.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, rr.keys.meta(key), "disabled_reason", reason, "disabled_at", at.Unix())
+		if ttl > 0 {
+			pipe.PExpire(ctx, rr.keys.meta(key), ttl)
+		}
+		pipe.SAdd(ctx, rr.keys.disabledIndex(), key)
+		return nil
+	})
+	return err
+}
+
+func (rr *redisRepo) Enable(ctx context.Context, key string) error {
+	key = scoped(ctx, key)
+	url, err := rr.Get(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		_ = rr.client.SRem(ctx, rr.keys.disabledIndex(), key).Err()
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = rr.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, rr.keys.meta(key), "disabled_reason", "disabled_at")
+		pipe.HSet(ctx, rr.keys.meta(key), "approved_url", url)
+		pipe.SRem(ctx, rr.keys.disabledIndex(), key)
+		return nil
+	})
+	return err
+}
+
+func (rr *redisRepo) ListDisabled(ctx context.Context) ([]Link, error) {
+	keys, err := rr.client.SMembers(ctx, rr.keys.disabledIndex()).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]Link, 0, len(keys))
+	err = rr.emitLinks(ctx, keys, func(link Link) error {
+		links = append(links, link)
+		return nil
+	})
+	return links, err
+}
+
+func NewRedisRepository(client *redis.Client, opts ...Option) Repository {
+	rr := &redisRepo{
+		client:    client,
+		retention: defaultRetention,
+		keys:      NewKeyspace(""),
+	}
+	for _, opt := range opts {
+		opt(rr)
+	}
+	return rr
 }
 
 type Config struct {
@@ -37,6 +1026,8 @@ type Config struct {
 	MaxRetries  int           `yaml:"max_retries"`
 	DialTimeout time.Duration `yaml:"dial_timeout"`
 	Timeout     time.Duration `yaml:"timeout"`
+	// Prefix starts every key of the shortener, see Keyspace.
+	Prefix string `yaml:"prefix"`
 }
 
 func NewClient(ctx context.Context, cfg Config) (*redis.Client, error) {
@@ -52,7 +1043,7 @@ func NewClient(ctx context.Context, cfg Config) (*redis.Client, error) {
 	})
 
 	if err := db.Ping(ctx).Err(); err != nil {
-		fmt.Printf("failed to connect to redis server: %s\n", err.Error())
+		slog.ErrorContext(ctx, "failed to connect to redis server", "addr", cfg.Addr, "error", err)
 		return nil, err
 	}
 
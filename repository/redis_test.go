@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRepo(t *testing.T, opts ...Option) (*redisRepo, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisRepository(client, opts...).(*redisRepo), mr
+}
+
+func TestSaveBatch_ExistingKey(t *testing.T) {
+	rr, mr := newTestRepo(t)
+	ctx := context.Background()
+	created := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	original := Link{
+		Key:             "abc123",
+		URL:             "https://example.com",
+		Owner:           "alice",
+		CreatedAt:       created,
+		PasswordHash:    "$2a$10$hash",
+		MaxClicks:       5,
+		RemainingClicks: 5,
+		Signed:          true,
+	}
+	if err := rr.Save(ctx, original, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mr.HSet(rr.keys.meta("abc123"), "remaining", "2")
+
+	// Saving the same link again is idempotent and leaves its state alone.
+	again := original
+	again.CreatedAt = time.Now()
+	again.RemainingClicks = 5
+	if err := rr.Save(ctx, again, 0); err != nil {
+		t.Fatalf("expected the same link to be accepted, got %v", err)
+	}
+	link, err := rr.GetLink(ctx, "abc123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !link.CreatedAt.Equal(created) || link.RemainingClicks != 2 {
+		t.Errorf("metadata was overwritten: %+v", link)
+	}
+
+	tests := []struct {
+		name   string
+		change func(*SaveRequest)
+	}{
+		{"owner", func(req *SaveRequest) { req.Link.Owner = "bob" }},
+		{"ttl", func(req *SaveRequest) { req.TTL = time.Minute }},
+		{"no password", func(req *SaveRequest) { req.Link.PasswordHash = "" }},
+		{"other password", func(req *SaveRequest) { req.Link.PasswordHash = "$2a$10$other" }},
+		{"unsigned", func(req *SaveRequest) { req.Link.Signed = false }},
+		{"schedule", func(req *SaveRequest) { req.Link.NotAfter = created.AddDate(1, 0, 0) }},
+		{"utm", func(req *SaveRequest) { req.Link.UTM.Source = "newsletter" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := SaveRequest{Link: original}
+			tt.change(&req)
+			if errs := rr.SaveBatch(ctx, []SaveRequest{req}); !errors.Is(errs[0], ErrConflict) {
+				t.Errorf("expected ErrConflict, got %v", errs[0])
+			}
+		})
+	}
+
+	link, err = rr.GetLink(ctx, "abc123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if link.Owner != "alice" || link.PasswordHash != original.PasswordHash || !link.Signed || !link.NotAfter.IsZero() {
+		t.Errorf("metadata was overwritten: %+v", link)
+	}
+	if ttl := mr.TTL(rr.keys.link("abc123")); ttl != 0 {
+		t.Errorf("expected the link to keep living, got TTL %s", ttl)
+	}
+	if ttl := mr.TTL(rr.keys.meta("abc123")); ttl != 0 {
+		t.Errorf("expected the metadata to keep living, got TTL %s", ttl)
+	}
+}
+
+func TestSaveBatch_Conflict(t *testing.T) {
+	rr, _ := newTestRepo(t)
+	ctx := context.Background()
+
+	errs := rr.SaveBatch(ctx, []SaveRequest{
+		{Link: Link{Key: "abc", URL: "https://example.com", Owner: "alice"}},
+		{Link: Link{Key: "def", URL: "https://example.org", Owner: "alice"}},
+	})
+	if errs[0] != nil || errs[1] != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	errs = rr.SaveBatch(ctx, []SaveRequest{
+		{Link: Link{Key: "abc", URL: "https://example.net", Owner: "bob"}},
+		{Link: Link{Key: "ghi", URL: "https://example.net", Owner: "bob"}},
+	})
+	if !errors.Is(errs[0], ErrConflict) || errs[1] != nil {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	if url, _ := rr.Get(ctx, "abc"); url != "https://example.com" {
+		t.Errorf("expected the first URL to stay, got %q", url)
+	}
+}
+
+func TestAddPasswordAttempt(t *testing.T) {
+	rr, mr := newTestRepo(t)
+	ctx := context.Background()
+
+	for want := int64(1); want <= 3; want++ {
+		got, err := rr.AddPasswordAttempt(ctx, "abc", time.Minute)
+		if err != nil || got != want {
+			t.Fatalf("attempt %d: got %d, %v", want, got, err)
+		}
+	}
+	if ttl := mr.TTL(rr.keys.passwordFailures("abc")); ttl != time.Minute {
+		t.Errorf("expected the window to start with the first attempt, got TTL %s", ttl)
+	}
+
+	if err := rr.ResetPasswordFailures(ctx, "abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, _ := rr.AddPasswordAttempt(ctx, "abc", time.Minute); got != 1 {
+		t.Errorf("expected the count to start over, got %d", got)
+	}
+}
+
+func TestSave_DisabledLink(t *testing.T) {
+	rr, _ := newTestRepo(t)
+	ctx := context.Background()
+	disabled := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+
+	err := rr.Save(ctx, Link{
+		Key:             "abc123",
+		URL:             "https://example.com",
+		CreatedAt:       disabled,
+		MaxClicks:       5,
+		RemainingClicks: 2,
+		DisabledReason:  "blocked by policy",
+		DisabledAt:      disabled,
+		ApprovedURL:     "https://example.org",
+	}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	link, err := rr.GetLink(ctx, "abc123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if link.RemainingClicks != 2 || link.DisabledReason != "blocked by policy" || !link.DisabledAt.Equal(disabled) || link.ApprovedURL != "https://example.org" {
+		t.Errorf("link not stored as given: %+v", link)
+	}
+	keys, err := rr.ListDisabled(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Errorf("expected the link in the disabled index, got %v", keys)
+	}
+}
+
+func TestConsumeClick(t *testing.T) {
+	rr, _ := newTestRepo(t)
+	ctx := context.Background()
+
+	if err := rr.Save(ctx, Link{Key: "once", URL: "https://example.com", MaxClicks: 2, RemainingClicks: 2}, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for want := int64(1); want >= 0; want-- {
+		left, err := rr.ConsumeClick(ctx, "once")
+		if err != nil || left != want {
+			t.Fatalf("expected %d uses left, got %d, %v", want, left, err)
+		}
+	}
+	if _, err := rr.ConsumeClick(ctx, "once"); !errors.Is(err, ErrExhausted) {
+		t.Errorf("expected ErrExhausted, got %v", err)
+	}
+}
+
+func TestConsumeClick_MissingCountFailsClosed(t *testing.T) {
+	rr, mr := newTestRepo(t)
+	ctx := context.Background()
+
+	if err := rr.Save(ctx, Link{Key: "once", URL: "https://example.com", MaxClicks: 1, RemainingClicks: 1}, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mr.HDel(rr.keys.meta("once"), "remaining")
+	if _, err := rr.ConsumeClick(ctx, "once"); !errors.Is(err, ErrExhausted) {
+		t.Errorf("expected a lost count to be exhausted, got %v", err)
+	}
+
+	mr.Del(rr.keys.meta("once"))
+	if _, err := rr.ConsumeClick(ctx, "once"); !errors.Is(err, ErrExhausted) {
+		t.Errorf("expected lost metadata to be exhausted, got %v", err)
+	}
+}
+
+func TestIncrClicks_ReturnsTotal(t *testing.T) {
+	rr, _ := newTestRepo(t)
+	ctx := context.Background()
+
+	if err := rr.Save(ctx, Link{Key: "abc123", URL: "https://example.com", Owner: "alice", Clicks: 9}, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	clicks, err := rr.IncrClicks(ctx, "abc123", "")
+	if err != nil || clicks != 10 {
+		t.Errorf("expected 10 clicks, got %d, %v", clicks, err)
+	}
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Granularity is the width of a click time-series bucket.
+type Granularity string
+
+const (
+	GranularityHour Granularity = "hour"
+	GranularityDay  Granularity = "day"
+)
+
+// Retention is how long click buckets are kept per granularity. Buckets are
+// stored in one hash per day (hourly) or month (daily) that expires as a
+// whole, so data is kept at least this long.
+type Retention struct {
+	Hourly time.Duration `yaml:"hourly"`
+	// Daily also applies to the links created per day.
+	Daily time.Duration `yaml:"daily"`
+	// Rankings is how long the daily top link and referrer rankings are
+	// kept.
+	Rankings time.Duration `yaml:"rankings"`
+}
+
+var defaultRetention = Retention{
+	Hourly:   7 * 24 * time.Hour,
+	Daily:    365 * 24 * time.Hour,
+	Rankings: 30 * 24 * time.Hour,
+}
+
+type Option func(*redisRepo)
+
+// WithRetention sets how long click time-series buckets and rankings are
+// kept.
+func WithRetention(r Retention) Option {
+	return func(rr *redisRepo) {
+		rr.retention = r
+	}
+}
+
+// seriesPeriod returns the start and end of the hash holding the bucket of
+// t at granularity g, in UTC.
+func seriesPeriod(g Granularity, t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	if g == GranularityHour {
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 1)
+	}
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
+
+func seriesField(g Granularity, t time.Time) string {
+	t = t.UTC()
+	if g == GranularityHour {
+		return strconv.Itoa(t.Hour())
+	}
+	return strconv.Itoa(t.Day())
+}
+
+func (rr *redisRepo) retentionOf(g Granularity) time.Duration {
+	if g == GranularityHour {
+		return rr.retention.Hourly
+	}
+	return rr.retention.Daily
+}
+
+// incrSeries counts a click at t in the hourly and daily buckets.
+func (rr *redisRepo) incrSeries(ctx context.Context, pipe redis.Pipeliner, key string, t time.Time) {
+	for _, g := range []Granularity{GranularityHour, GranularityDay} {
+		start, end := seriesPeriod(g, t)
+		hash := rr.keys.series(key, g, start)
+		pipe.HIncrBy(ctx, hash, seriesField(g, t), 1)
+		pipe.ExpireAt(ctx, hash, end.Add(rr.retentionOf(g)))
+	}
+}
+
+// seriesKeys returns every hash that may still hold buckets of key at now.
+func (rr *redisRepo) seriesKeys(key string, now time.Time) []string {
+	var keys []string
+	for _, g := range []Granularity{GranularityHour, GranularityDay} {
+		retention := rr.retentionOf(g)
+		for start, end := seriesPeriod(g, now); end.Add(retention).After(now); {
+			keys = append(keys, rr.keys.series(key, g, start))
+			end = start
+			start, _ = seriesPeriod(g, start.Add(-time.Nanosecond))
+		}
+	}
+	return keys
+}
+
+func (rr *redisRepo) ClickSeries(ctx context.Context, key string, g Granularity, from, to time.Time) (map[time.Time]int64, error) {
+	key = scoped(ctx, key)
+	var periods []time.Time
+	for start, _ := seriesPeriod(g, from); start.Before(to); _, start = seriesPeriod(g, start) {
+		periods = append(periods, start)
+	}
+
+	pipe := rr.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(periods))
+	for i, start := range periods {
+		cmds[i] = pipe.HGetAll(ctx, rr.keys.series(key, g, start))
+	}
+	if len(periods) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	series := make(map[time.Time]int64)
+	for i, start := range periods {
+		for field, value := range cmds[i].Val() {
+			n, err := strconv.Atoi(field)
+			if err != nil {
+				continue
+			}
+			var bucket time.Time
+			if g == GranularityHour {
+				bucket = start.Add(time.Duration(n) * time.Hour)
+			} else {
+				bucket = start.AddDate(0, 0, n-1)
+			}
+			if bucket.Before(from) || !bucket.Before(to) {
+				continue
+			}
+			clicks, _ := strconv.ParseInt(value, 10, 64)
+			series[bucket] = clicks
+		}
+	}
+	return series, nil
+}
@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "url-shortener/repository"
+
+// tracedRepo starts a span around every call of the wrapped repository.
+// Spans carry the link key but never the destination URL.
+type tracedRepo struct {
+	next Repository
+}
+
+// NewTracedRepository wraps repo so each call is traced with the global
+// tracer provider.
+func NewTracedRepository(repo Repository) Repository {
+	return &tracedRepo{next: repo}
+}
+
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("db.system", "redis"))
+	if domain := DomainFrom(ctx); domain != "" {
+		attrs = append(attrs, attribute.String("link.domain", domain))
+	}
+	return otel.Tracer(tracerName).Start(ctx, "repository."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+func keyAttr(key string) attribute.KeyValue {
+	return attribute.String("link.key", key)
+}
+
+func domainAttr(name string) attribute.KeyValue {
+	return attribute.String("domain.name", name)
+}
+
+// endSpan records err on span and ends it. Missing keys and used up links
+// are expected outcomes and do not mark the span as failed.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		if !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrExhausted) {
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}
+
+func (r *tracedRepo) Save(ctx context.Context, link Link, ttl time.Duration) (err error) {
+	ctx, span := startSpan(ctx, "Save", keyAttr(link.Key))
+	defer func() { endSpan(span, err) }()
+	return r.next.Save(ctx, link, ttl)
+}
+
+func (r *tracedRepo) SaveBatch(ctx context.Context, reqs []SaveRequest) []error {
+	ctx, span := startSpan(ctx, "SaveBatch", attribute.Int("batch.size", len(reqs)))
+	defer span.End()
+	return r.next.SaveBatch(ctx, reqs)
+}
+
+func (r *tracedRepo) Get(ctx context.Context, key string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "Get", keyAttr(key))
+	defer func() { endSpan(span, err) }()
+	return r.next.Get(ctx, key)
+}
+
+func (r *tracedRepo) GetLink(ctx context.Context, key string) (_ Link, err error) {
+	ctx, span := startSpan(ctx, "GetLink", keyAttr(key))
+	defer func() { endSpan(span, err) }()
+	return r.next.GetLink(ctx, key)
+}
+
+func (r *tracedRepo) Update(ctx context.Context, key string, url string) (err error) {
+	ctx, span := startSpan(ctx, "Update", keyAttr(key))
+	defer func() { endSpan(span, err) }()
+	return r.next.Update(ctx, key, url)
+}
+
+func (r *tracedRepo) Delete(ctx context.Context, key string) (err error) {
+	ctx, span := startSpan(ctx, "Delete", keyAttr(key))
+	defer func() { endSpan(span, err) }()
+	return r.next.Delete(ctx, key)
+}
+
+func (r *tracedRepo) IncrClicks(ctx context.Context, key string, referrer string) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "IncrClicks", keyAttr(key))
+	defer func() { endSpan(span, err) }()
+	return r.next.IncrClicks(ctx, key, referrer)
+}
+
+func (r *tracedRepo) IncrVariantClicks(ctx context.Context, key string, variant int) (err error) {
+	ctx, span := startSpan(ctx, "IncrVariantClicks", keyAttr(key), attribute.Int("link.variant", variant))
+	defer func() { endSpan(span, err) }()
+	return r.next.IncrVariantClicks(ctx, key, variant)
+}
+
+func (r *tracedRepo) ConsumeClick(ctx context.Context, key string) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "ConsumeClick", keyAttr(key))
+	defer func() { endSpan(span, err) }()
+	return r.next.ConsumeClick(ctx, key)
+}
+
+func (r *tracedRepo) ClickSeries(ctx context.Context, key string, g Granularity, from, to time.Time) (_ map[time.Time]int64, err error) {
+	ctx, span := startSpan(ctx, "ClickSeries", keyAttr(key), attribute.String("series.granularity", string(g)))
+	defer func() { endSpan(span, err) }()
+	return r.next.ClickSeries(ctx, key, g, from, to)
+}
+
+func (r *tracedRepo) TopLinks(ctx context.Context, from, to time.Time, n int) (_ []Ranked, err error) {
+	ctx, span := startSpan(ctx, "TopLinks")
+	defer func() { endSpan(span, err) }()
+	return r.next.TopLinks(ctx, from, to, n)
+}
+
+func (r *tracedRepo) TopReferrers(ctx context.Context, from, to time.Time, n int) (_ []Ranked, err error) {
+	ctx, span := startSpan(ctx, "TopReferrers")
+	defer func() { endSpan(span, err) }()
+	return r.next.TopReferrers(ctx, from, to, n)
+}
+
+func (r *tracedRepo) LinksCreated(ctx context.Context, from, to time.Time) (_ map[time.Time]int64, err error) {
+	ctx, span := startSpan(ctx, "LinksCreated")
+	defer func() { endSpan(span, err) }()
+	return r.next.LinksCreated(ctx, from, to)
+}
+
+func (r *tracedRepo) AddPasswordAttempt(ctx context.Context, key string, window time.Duration) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "AddPasswordAttempt", keyAttr(key))
+	defer func() { endSpan(span, err) }()
+	return r.next.AddPasswordAttempt(ctx, key, window)
+}
+
+func (r *tracedRepo) ResetPasswordFailures(ctx context.Context, key string) (err error) {
+	ctx, span := startSpan(ctx, "ResetPasswordFailures", keyAttr(key))
+	defer func() { endSpan(span, err) }()
+	return r.next.ResetPasswordFailures(ctx, key)
+}
+
+func (r *tracedRepo) ListByOwner(ctx context.Context, owner string, opts ListOptions) (_ []Link, _ int64, err error) {
+	ctx, span := startSpan(ctx, "ListByOwner")
+	defer func() { endSpan(span, err) }()
+	return r.next.ListByOwner(ctx, owner, opts)
+}
+
+func (r *tracedRepo) GetAPIKey(ctx context.Context, token string) (_ APIKey, err error) {
+	ctx, span := startSpan(ctx, "GetAPIKey")
+	defer func() { endSpan(span, err) }()
+	return r.next.GetAPIKey(ctx, token)
+}
+
+func (r *tracedRepo) CreateAPIKey(ctx context.Context, token string, key APIKey) (_ APIKey, err error) {
+	ctx, span := startSpan(ctx, "CreateAPIKey")
+	defer func() { endSpan(span, err) }()
+	return r.next.CreateAPIKey(ctx, token, key)
+}
+
+func (r *tracedRepo) DeleteAPIKey(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteAPIKey", attribute.String("api_key.id", id))
+	defer func() { endSpan(span, err) }()
+	return r.next.DeleteAPIKey(ctx, id)
+}
+
+func (r *tracedRepo) ListAPIKeys(ctx context.Context) (_ []APIKey, err error) {
+	ctx, span := startSpan(ctx, "ListAPIKeys")
+	defer func() { endSpan(span, err) }()
+	return r.next.ListAPIKeys(ctx)
+}
+
+func (r *tracedRepo) PurgeOrphans(ctx context.Context) (_ []Link, err error) {
+	ctx, span := startSpan(ctx, "PurgeOrphans")
+	defer func() { endSpan(span, err) }()
+	return r.next.PurgeOrphans(ctx)
+}
+
+func (r *tracedRepo) ScanLinks(ctx context.Context, fn func(Link) error) (err error) {
+	ctx, span := startSpan(ctx, "ScanLinks")
+	defer func() { endSpan(span, err) }()
+	return r.next.ScanLinks(ctx, fn)
+}
+
+func (r *tracedRepo) Disable(ctx context.Context, key string, reason string, at time.Time) (err error) {
+	ctx, span := startSpan(ctx, "Disable", keyAttr(key))
+	defer func() { endSpan(span, err) }()
+	return r.next.Disable(ctx, key, reason, at)
+}
+
+func (r *tracedRepo) Enable(ctx context.Context, key string) (err error) {
+	ctx, span := startSpan(ctx, "Enable", keyAttr(key))
+	defer func() { endSpan(span, err) }()
+	return r.next.Enable(ctx, key)
+}
+
+func (r *tracedRepo) ListDisabled(ctx context.Context) (_ []Link, err error) {
+	ctx, span := startSpan(ctx, "ListDisabled")
+	defer func() { endSpan(span, err) }()
+	return r.next.ListDisabled(ctx)
+}
+
+func (r *tracedRepo) SaveDomain(ctx context.Context, d Domain) (err error) {
+	ctx, span := startSpan(ctx, "SaveDomain", domainAttr(d.Name))
+	defer func() { endSpan(span, err) }()
+	return r.next.SaveDomain(ctx, d)
+}
+
+func (r *tracedRepo) GetDomain(ctx context.Context, name string) (_ Domain, err error) {
+	ctx, span := startSpan(ctx, "GetDomain", domainAttr(name))
+	defer func() { endSpan(span, err) }()
+	return r.next.GetDomain(ctx, name)
+}
+
+func (r *tracedRepo) DeleteDomain(ctx context.Context, name string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteDomain", domainAttr(name))
+	defer func() { endSpan(span, err) }()
+	return r.next.DeleteDomain(ctx, name)
+}
+
+func (r *tracedRepo) ListDomains(ctx context.Context) (_ []Domain, err error) {
+	ctx, span := startSpan(ctx, "ListDomains")
+	defer func() { endSpan(span, err) }()
+	return r.next.ListDomains(ctx)
+}
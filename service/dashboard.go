@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+	"url-shortener/repository"
+)
+
+var ErrInvalidWindow = errors.New("invalid statistics window")
+
+const (
+	defaultDashboardDays = 7
+	maxDashboardDays     = 366
+	defaultRankingLimit  = 10
+	maxRankingLimit      = 100
+)
+
+// DashboardRequest selects the window of an admin statistics query.
+type DashboardRequest struct {
+	// From defaults to 7 days before To, which defaults to now. Both are
+	// widened to whole UTC days.
+	From, To time.Time
+	// Limit is the length of rankings, 10 by default and at most 100.
+	Limit int
+}
+
+// Ranking lists the most clicked links or referring domains of a window,
+// busiest first.
+type Ranking struct {
+	From, To time.Time
+	Entries  []repository.Ranked
+}
+
+type DailyCount struct {
+	Day   time.Time
+	Count int64
+}
+
+// DailyCounts has one entry per day in [From, To), including empty ones.
+type DailyCounts struct {
+	From, To time.Time
+	Days     []DailyCount
+}
+
+func (s *service) TopLinks(ctx context.Context, req DashboardRequest) (Ranking, error) {
+	return s.ranking(ctx, req, s.repo.TopLinks)
+}
+
+func (s *service) TopReferrers(ctx context.Context, req DashboardRequest) (Ranking, error) {
+	return s.ranking(ctx, req, s.repo.TopReferrers)
+}
+
+func (s *service) ranking(ctx context.Context, req DashboardRequest, top func(context.Context, time.Time, time.Time, int) ([]repository.Ranked, error)) (Ranking, error) {
+	from, to, err := dashboardWindow(req)
+	if err != nil {
+		return Ranking{}, err
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultRankingLimit
+	}
+	if limit < 0 || limit > maxRankingLimit {
+		return Ranking{}, ErrInvalidWindow
+	}
+
+	entries, err := top(ctx, from, to, limit)
+	if err != nil {
+		return Ranking{}, err
+	}
+	if entries == nil {
+		entries = []repository.Ranked{}
+	}
+	return Ranking{From: from, To: to, Entries: entries}, nil
+}
+
+func (s *service) LinksCreated(ctx context.Context, req DashboardRequest) (DailyCounts, error) {
+	from, to, err := dashboardWindow(req)
+	if err != nil {
+		return DailyCounts{}, err
+	}
+	created, err := s.repo.LinksCreated(ctx, from, to)
+	if err != nil {
+		return DailyCounts{}, err
+	}
+
+	var days []DailyCount
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		days = append(days, DailyCount{Day: day, Count: created[day]})
+	}
+	return DailyCounts{From: from, To: to, Days: days}, nil
+}
+
+// dashboardWindow applies the defaults of req and widens it to whole days.
+func dashboardWindow(req DashboardRequest) (time.Time, time.Time, error) {
+	to := req.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	from := req.From
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -defaultDashboardDays)
+	}
+
+	g := repository.GranularityDay
+	from = bucketStart(g, from)
+	if start := bucketStart(g, to); start.Before(to) {
+		to = nextBucket(g, start)
+	} else {
+		to = start
+	}
+	if !from.Before(to) || to.Sub(from) > maxDashboardDays*24*time.Hour {
+		return time.Time{}, time.Time{}, ErrInvalidWindow
+	}
+	return from, to, nil
+}
+
+// referrerDomain returns the host a visitor came from, without a leading
+// "www.", or "" if the Referer header names none.
+func referrerDomain(referrer string) string {
+	u, err := url.Parse(referrer)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+	"url-shortener/repository"
+)
+
+func TestTopLinks(t *testing.T) {
+	base := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	var gotFrom, gotTo time.Time
+	var gotLimit int
+	repo := &MockRepository{
+		TopLinksFunc: func(ctx context.Context, from, to time.Time, n int) ([]repository.Ranked, error) {
+			gotFrom, gotTo, gotLimit = from, to, n
+			return []repository.Ranked{{Name: "abc", Count: 9}, {Name: "def", Count: 2}}, nil
+		},
+	}
+	svc := NewShortenerService(repo)
+
+	ranking, err := svc.TopLinks(context.Background(), DashboardRequest{
+		From: base.Add(5 * time.Hour),
+		To:   base.AddDate(0, 0, 2).Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The window is widened to whole days.
+	if !ranking.From.Equal(base) || !ranking.To.Equal(base.AddDate(0, 0, 3)) || !gotFrom.Equal(base) || !gotTo.Equal(ranking.To) {
+		t.Errorf("unexpected window %v - %v (repository %v - %v)", ranking.From, ranking.To, gotFrom, gotTo)
+	}
+	if gotLimit != defaultRankingLimit {
+		t.Errorf("expected the default limit, got %d", gotLimit)
+	}
+	if len(ranking.Entries) != 2 || ranking.Entries[0].Name != "abc" {
+		t.Errorf("unexpected entries: %+v", ranking.Entries)
+	}
+}
+
+func TestTopReferrers_Invalid(t *testing.T) {
+	svc := NewShortenerService(&MockRepository{})
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		req  DashboardRequest
+	}{
+		{"reversed", DashboardRequest{From: now, To: now.AddDate(0, 0, -2)}},
+		{"too long", DashboardRequest{From: now.AddDate(-2, 0, 0), To: now}},
+		{"negative limit", DashboardRequest{Limit: -1}},
+		{"limit too high", DashboardRequest{Limit: maxRankingLimit + 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.TopReferrers(context.Background(), tt.req); err != ErrInvalidWindow {
+				t.Errorf("expected ErrInvalidWindow, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLinksCreated(t *testing.T) {
+	base := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	repo := &MockRepository{
+		LinksCreatedFunc: func(ctx context.Context, from, to time.Time) (map[time.Time]int64, error) {
+			return map[time.Time]int64{base.AddDate(0, 0, 1): 5}, nil
+		},
+	}
+	svc := NewShortenerService(repo)
+
+	counts, err := svc.LinksCreated(context.Background(), DashboardRequest{From: base, To: base.AddDate(0, 0, 3)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []int64{0, 5, 0}
+	if len(counts.Days) != len(want) {
+		t.Fatalf("got %d days, want %d", len(counts.Days), len(want))
+	}
+	for i, day := range counts.Days {
+		if !day.Day.Equal(base.AddDate(0, 0, i)) || day.Count != want[i] {
+			t.Errorf("day %d = %+v, want %d links", i, day, want[i])
+		}
+	}
+}
+
+func TestGetOriginalURL_CountsReferrerDomain(t *testing.T) {
+	tests := []struct {
+		referrer string
+		want     string
+	}{
+		{"https://www.Example.com/page?q=1", "example.com"},
+		{"http://news.example.org", "news.example.org"},
+		{"android-app://com.example", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		var got string
+		repo := &MockRepository{
+			GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+				return repository.Link{Key: key, URL: "https://example.com"}, nil
+			},
+			IncrClicksFunc: func(ctx context.Context, key, referrer string) (int64, error) {
+				got = referrer
+				return 1, nil
+			},
+		}
+		svc := NewShortenerService(repo)
+		if _, err := svc.GetOriginalURL(context.Background(), "abc", Visit{Referrer: tt.referrer}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("referrer %q counted as %q, want %q", tt.referrer, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"url-shortener/repository"
+)
+
+var (
+	ErrInvalidDomain = errors.New("invalid domain")
+	ErrUnknownDomain = errors.New("unknown domain")
+)
+
+// domainPattern matches lower-case host names without a port.
+var domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z][a-z0-9-]{0,62}$`)
+
+var redirectCodes = []int{
+	http.StatusMovedPermanently,
+	http.StatusFound,
+	http.StatusTemporaryRedirect,
+	http.StatusPermanentRedirect,
+}
+
+type domainContextKey struct{}
+
+// WithDomain makes calls with the returned context work on the links of d:
+// keys are created and resolved in its namespace and destinations must be
+// allowed by it.
+func WithDomain(ctx context.Context, d repository.Domain) context.Context {
+	ctx = repository.WithDomain(ctx, d.Name)
+	return context.WithValue(ctx, domainContextKey{}, d)
+}
+
+// DomainFrom returns the domain set by WithDomain.
+func DomainFrom(ctx context.Context) (repository.Domain, bool) {
+	d, ok := ctx.Value(domainContextKey{}).(repository.Domain)
+	return d, ok
+}
+
+func (s *service) SaveDomain(ctx context.Context, d repository.Domain) (repository.Domain, error) {
+	if !domainPattern.MatchString(d.Name) {
+		return repository.Domain{}, ErrInvalidDomain
+	}
+	if d.RedirectCode != 0 && !slices.Contains(redirectCodes, d.RedirectCode) {
+		return repository.Domain{}, ErrInvalidDomain
+	}
+	if d.FallbackURL != "" {
+		if err := s.checkDestination(ctx, d.FallbackURL); err != nil {
+			return repository.Domain{}, err
+		}
+	}
+	for i, host := range d.AllowedDestinations {
+		host = strings.ToLower(host)
+		if !domainPattern.MatchString(host) {
+			return repository.Domain{}, ErrInvalidDomain
+		}
+		d.AllowedDestinations[i] = host
+	}
+
+	d.CreatedAt = time.Now().UTC()
+	if err := s.repo.SaveDomain(ctx, d); err != nil {
+		return repository.Domain{}, err
+	}
+	// The creation time of an existing domain is kept.
+	return s.GetDomain(ctx, d.Name)
+}
+
+func (s *service) GetDomain(ctx context.Context, name string) (repository.Domain, error) {
+	d, err := s.repo.GetDomain(ctx, name)
+	if errors.Is(err, repository.ErrNotFound) {
+		return repository.Domain{}, ErrUnknownDomain
+	}
+	return d, err
+}
+
+func (s *service) ListDomains(ctx context.Context) ([]repository.Domain, error) {
+	domains, err := s.repo.ListDomains(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(domains, func(a, b repository.Domain) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return domains, nil
+}
+
+func (s *service) DeleteDomain(ctx context.Context, name string) error {
+	err := s.repo.DeleteDomain(ctx, name)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrUnknownDomain
+	}
+	return err
+}
+
+// checkDomainDestination rejects destinations outside the allowed hosts of
+// the context's domain.
+func checkDomainDestination(ctx context.Context, u *url.URL) error {
+	d, ok := DomainFrom(ctx)
+	if !ok || len(d.AllowedDestinations) == 0 {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range d.AllowedDestinations {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return nil
+		}
+	}
+	return &PolicyError{Rule: "domain:" + d.Name}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"url-shortener/repository"
+)
+
+func TestSaveDomain(t *testing.T) {
+	var saved repository.Domain
+	repo := &MockRepository{
+		SaveDomainFunc: func(ctx context.Context, d repository.Domain) error {
+			saved = d
+			return nil
+		},
+		GetDomainFunc: func(ctx context.Context, name string) (repository.Domain, error) {
+			return saved, nil
+		},
+	}
+	svc := NewShortenerService(repo)
+
+	d, err := svc.SaveDomain(context.Background(), repository.Domain{
+		Name:                "brand-a.link",
+		RedirectCode:        302,
+		FallbackURL:         "https://brand-a.com",
+		AllowedDestinations: []string{"Brand-A.com"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.CreatedAt.IsZero() || len(d.AllowedDestinations) != 1 || d.AllowedDestinations[0] != "brand-a.com" {
+		t.Errorf("unexpected domain %+v", d)
+	}
+
+	tests := []struct {
+		name   string
+		domain repository.Domain
+		want   error
+	}{
+		{"port", repository.Domain{Name: "brand-a.link:8080"}, ErrInvalidDomain},
+		{"upper case", repository.Domain{Name: "Brand-A.link"}, ErrInvalidDomain},
+		{"no tld", repository.Domain{Name: "localhost"}, ErrInvalidDomain},
+		{"redirect code", repository.Domain{Name: "brand-a.link", RedirectCode: 200}, ErrInvalidDomain},
+		{"fallback", repository.Domain{Name: "brand-a.link", FallbackURL: "ftp://brand-a.com"}, ErrInvalidURL},
+		{"destination", repository.Domain{Name: "brand-a.link", AllowedDestinations: []string{"https://brand-a.com"}}, ErrInvalidDomain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.SaveDomain(context.Background(), tt.domain); !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestGetDomain_Unknown(t *testing.T) {
+	svc := NewShortenerService(&MockRepository{})
+	if _, err := svc.GetDomain(context.Background(), "brand-b.link"); err != ErrUnknownDomain {
+		t.Errorf("expected ErrUnknownDomain, got %v", err)
+	}
+	if err := svc.DeleteDomain(context.Background(), "brand-b.link"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestShortenURL_DomainDestinations(t *testing.T) {
+	var scopedTo string
+	repo := &MockRepository{
+		SaveFunc: func(ctx context.Context, link repository.Link, ttl time.Duration) error {
+			scopedTo = repository.DomainFrom(ctx)
+			return nil
+		},
+	}
+	svc := NewShortenerService(repo)
+	ctx := WithDomain(context.Background(), repository.Domain{
+		Name:                "brand-a.link",
+		AllowedDestinations: []string{"brand-a.com"},
+	})
+
+	for _, raw := range []string{"https://brand-a.com/sale", "https://shop.brand-a.com"} {
+		if _, err := svc.ShortenURL(ctx, ShortenRequest{URL: raw}); err != nil {
+			t.Errorf("%s: unexpected error: %v", raw, err)
+		}
+	}
+	if scopedTo != "brand-a.link" {
+		t.Errorf("expected the link to be saved in brand-a.link, got %q", scopedTo)
+	}
+
+	var policyErr *PolicyError
+	for _, raw := range []string{"https://example.com", "https://notbrand-a.com"} {
+		if _, err := svc.ShortenURL(ctx, ShortenRequest{URL: raw}); !errors.As(err, &policyErr) || policyErr.Rule != "domain:brand-a.link" {
+			t.Errorf("%s: expected a domain policy error, got %v", raw, err)
+		}
+	}
+	if _, err := svc.ShortenURL(context.Background(), ShortenRequest{URL: "https://example.com"}); err != nil {
+		t.Errorf("unexpected error outside the domain: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+	"url-shortener/repository"
+)
+
+type EventType string
+
+const (
+	EventLinkCreated   EventType = "link.created"
+	EventLinkUpdated   EventType = "link.updated"
+	EventLinkDeleted   EventType = "link.deleted"
+	EventLinkExpired   EventType = "link.expired"
+	EventLinkMilestone EventType = "link.milestone"
+)
+
+// EventTypes lists every event the service emits.
+var EventTypes = []EventType{
+	EventLinkCreated,
+	EventLinkUpdated,
+	EventLinkDeleted,
+	EventLinkExpired,
+	EventLinkMilestone,
+}
+
+// Event describes a change in a link's lifecycle.
+type Event struct {
+	Type EventType `json:"type"`
+	Key  string    `json:"key"`
+	// Domain is the custom domain of the link, empty for the default one.
+	Domain string `json:"domain,omitempty"`
+	Owner  string `json:"owner,omitempty"`
+	// URL is the destination after the change, for created and updated
+	// links.
+	URL string `json:"url,omitempty"`
+	// Clicks is the milestone a link reached.
+	Clicks int64     `json:"clicks,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// EventHandler receives the service's events. Handlers run on the request
+// path, so they should only queue work.
+type EventHandler interface {
+	HandleEvent(ctx context.Context, event Event) error
+}
+
+// DefaultMilestones are the click counts reported as milestones unless
+// WithClickMilestones says otherwise.
+var DefaultMilestones = []int64{100, 1000, 10000, 100000, 1000000}
+
+// WithEventHandler adds h to the handlers notified of link events.
+func WithEventHandler(h EventHandler) Option {
+	return func(s *service) {
+		s.handlers = append(s.handlers, h)
+	}
+}
+
+// WithClickMilestones sets the click counts that emit a milestone event.
+func WithClickMilestones(milestones ...int64) Option {
+	return func(s *service) {
+		s.milestones = milestones
+	}
+}
+
+// emit passes event to every handler. Failing handlers are logged but never
+// fail the operation that caused the event.
+func (s *service) emit(ctx context.Context, event Event) {
+	if event.At.IsZero() {
+		event.At = time.Now().UTC()
+	}
+	if event.Domain == "" {
+		event.Domain = repository.DomainFrom(ctx)
+	}
+	for _, h := range s.handlers {
+		if err := h.HandleEvent(ctx, event); err != nil {
+			slog.WarnContext(ctx, "failed to handle event", "event", event.Type, "key", event.Key, "error", err)
+		}
+	}
+}
+
+// emitMilestone reports a click that brought link to one of the milestones.
+// clicks is the total the click was counted into, so each milestone is
+// reached by exactly one click however many arrive at once.
+func (s *service) emitMilestone(ctx context.Context, key, owner string, clicks int64) {
+	if len(s.handlers) == 0 || !slices.Contains(s.milestones, clicks) {
+		return
+	}
+	s.emit(ctx, Event{Type: EventLinkMilestone, Key: key, Owner: owner, Clicks: clicks})
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"url-shortener/repository"
+)
+
+type recordedEvents []Event
+
+func (r *recordedEvents) HandleEvent(ctx context.Context, event Event) error {
+	*r = append(*r, event)
+	return nil
+}
+
+type failingHandler struct{}
+
+func (failingHandler) HandleEvent(ctx context.Context, event Event) error {
+	return errors.New("queue unavailable")
+}
+
+func TestEvents_Lifecycle(t *testing.T) {
+	var events recordedEvents
+	repo := &MockRepository{
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			return repository.Link{Key: key, URL: "https://example.com", Owner: "alice"}, nil
+		},
+		PurgeOrphansFunc: func(ctx context.Context) ([]repository.Link, error) {
+			return []repository.Link{{Key: "old", Owner: "alice"}}, nil
+		},
+	}
+	svc := NewShortenerService(repo, WithEventHandler(failingHandler{}), WithEventHandler(&events))
+	ctx := context.Background()
+	alice := Identity{Owner: "alice"}
+
+	key, err := svc.ShortenURL(ctx, ShortenRequest{URL: "https://example.com", Owner: "alice"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.UpdateURL(ctx, alice, key, "https://example.org"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.DeleteURL(ctx, alice, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n, err := svc.PurgeExpired(ctx); err != nil || n != 1 {
+		t.Fatalf("PurgeExpired() = %d, %v", n, err)
+	}
+
+	want := []Event{
+		{Type: EventLinkCreated, Key: key, Owner: "alice", URL: "https://example.com"},
+		{Type: EventLinkUpdated, Key: key, Owner: "alice", URL: "https://example.org"},
+		{Type: EventLinkDeleted, Key: key, Owner: "alice"},
+		{Type: EventLinkExpired, Key: "old", Owner: "alice"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, event := range events {
+		if event.At.IsZero() {
+			t.Errorf("event %d has no time", i)
+		}
+		event.At = want[i].At
+		if event != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, event, want[i])
+		}
+	}
+}
+
+func TestEvents_Batch(t *testing.T) {
+	var events recordedEvents
+	repo := &MockRepository{
+		SaveBatchFunc: func(ctx context.Context, reqs []repository.SaveRequest) []error {
+			return []error{nil, repository.ErrConflict}
+		},
+	}
+	svc := NewShortenerService(repo, WithEventHandler(&events))
+
+	results := svc.ShortenBatch(context.Background(), []ShortenRequest{
+		{URL: "https://example.com/a"},
+		{URL: "https://example.com/b", Alias: "taken"},
+		{URL: "not a url"},
+	})
+	if results[1].Err != ErrConflict || results[2].Err == nil {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if len(events) != 1 || events[0].Key != results[0].Key || events[0].Type != EventLinkCreated {
+		t.Errorf("expected one created event for the saved link, got %+v", events)
+	}
+}
+
+func TestEvents_Milestones(t *testing.T) {
+	var events recordedEvents
+	clicks := int64(8)
+	repo := &MockRepository{
+		// The count read with the link is stale, as it is when other visits
+		// are counted at the same time; only the incremented one is right.
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			return repository.Link{Key: key, URL: "https://example.com", Owner: "alice", Clicks: 8}, nil
+		},
+		IncrClicksFunc: func(ctx context.Context, key, referrer string) (int64, error) {
+			clicks++
+			return clicks, nil
+		},
+	}
+	svc := NewShortenerService(repo, WithEventHandler(&events), WithClickMilestones(10, 12))
+
+	for range 5 {
+		if _, err := svc.GetOriginalURL(context.Background(), "abc", Visit{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected two milestones, got %+v", events)
+	}
+	for i, want := range []int64{10, 12} {
+		if events[i].Type != EventLinkMilestone || events[i].Clicks != want || events[i].Owner != "alice" {
+			t.Errorf("event %d = %+v, want milestone %d", i, events[i], want)
+		}
+	}
+}
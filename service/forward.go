@@ -0,0 +1,77 @@
+package service
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"url-shortener/repository"
+)
+
+var queryForwardings = map[repository.QueryForwarding]bool{
+	"":                         true,
+	repository.ForwardKeep:     true,
+	repository.ForwardOverride: true,
+	repository.ForwardAppend:   true,
+}
+
+// forwardDestination merges the link's UTM defaults, the visitor's query
+// string and path suffix into the link's URL. The destination's own query is
+// only re-encoded when something was added to it.
+func forwardDestination(link repository.Link, visit Visit) string {
+	u, err := url.Parse(link.URL)
+	if err != nil {
+		return link.URL
+	}
+
+	if link.ForwardPath && visit.Path != "" {
+		// Cleaning first keeps ".." segments from climbing out of the
+		// destination's path.
+		suffix := path.Clean("/" + visit.Path)
+		if suffix != "/" {
+			if strings.HasSuffix(visit.Path, "/") {
+				suffix += "/"
+			}
+			u.Path = strings.TrimSuffix(u.Path, "/") + suffix
+			u.RawPath = ""
+		}
+	}
+
+	query := u.Query()
+	changed := false
+	for key, value := range utmParams(link.UTM) {
+		if value != "" && !query.Has(key) {
+			query.Set(key, value)
+			changed = true
+		}
+	}
+	if link.ForwardQuery != "" {
+		for key, values := range visit.Query {
+			switch link.ForwardQuery {
+			case repository.ForwardKeep:
+				if query.Has(key) {
+					continue
+				}
+				query[key] = values
+			case repository.ForwardOverride:
+				query[key] = values
+			case repository.ForwardAppend:
+				query[key] = append(query[key], values...)
+			}
+			changed = true
+		}
+	}
+	if changed {
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+func utmParams(utm repository.UTM) map[string]string {
+	return map[string]string{
+		"utm_source":   utm.Source,
+		"utm_medium":   utm.Medium,
+		"utm_campaign": utm.Campaign,
+		"utm_term":     utm.Term,
+		"utm_content":  utm.Content,
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"url-shortener/repository"
+)
+
+func TestForwardDestination(t *testing.T) {
+	campaign := repository.UTM{Source: "newsletter", Medium: "email", Campaign: "spring sale"}
+
+	tests := []struct {
+		name  string
+		link  repository.Link
+		visit Visit
+		want  string
+	}{
+		{
+			name: "nothing to forward",
+			link: repository.Link{URL: "https://example.com/a?b=2&a=1"},
+			want: "https://example.com/a?b=2&a=1",
+		},
+		{
+			name: "utm defaults",
+			link: repository.Link{URL: "https://example.com/sale", UTM: campaign},
+			want: "https://example.com/sale?utm_campaign=spring+sale&utm_medium=email&utm_source=newsletter",
+		},
+		{
+			name: "destination utm wins",
+			link: repository.Link{URL: "https://example.com/?utm_source=partner", UTM: campaign},
+			want: "https://example.com/?utm_campaign=spring+sale&utm_medium=email&utm_source=partner",
+		},
+		{
+			name:  "query dropped by default",
+			link:  repository.Link{URL: "https://example.com/"},
+			visit: Visit{Query: url.Values{"ref": {"x"}}},
+			want:  "https://example.com/",
+		},
+		{
+			name:  "keep",
+			link:  repository.Link{URL: "https://example.com/?lang=en", ForwardQuery: repository.ForwardKeep},
+			visit: Visit{Query: url.Values{"lang": {"de"}, "ref": {"x"}}},
+			want:  "https://example.com/?lang=en&ref=x",
+		},
+		{
+			name:  "override",
+			link:  repository.Link{URL: "https://example.com/?lang=en", ForwardQuery: repository.ForwardOverride},
+			visit: Visit{Query: url.Values{"lang": {"de"}}},
+			want:  "https://example.com/?lang=de",
+		},
+		{
+			name:  "append",
+			link:  repository.Link{URL: "https://example.com/?tag=a", ForwardQuery: repository.ForwardAppend},
+			visit: Visit{Query: url.Values{"tag": {"b", "c"}}},
+			want:  "https://example.com/?tag=a&tag=b&tag=c",
+		},
+		{
+			name:  "incoming utm cannot beat keep",
+			link:  repository.Link{URL: "https://example.com/", UTM: repository.UTM{Source: "qr"}, ForwardQuery: repository.ForwardKeep},
+			visit: Visit{Query: url.Values{"utm_source": {"spoofed"}}},
+			want:  "https://example.com/?utm_source=qr",
+		},
+		{
+			name:  "escaping",
+			link:  repository.Link{URL: "https://example.com/search", ForwardQuery: repository.ForwardKeep},
+			visit: Visit{Query: url.Values{"q": {"a&b=c d"}}},
+			want:  "https://example.com/search?q=a%26b%3Dc+d",
+		},
+		{
+			name:  "path suffix",
+			link:  repository.Link{URL: "https://example.com/docs/", ForwardPath: true},
+			visit: Visit{Path: "/guide/install"},
+			want:  "https://example.com/docs/guide/install",
+		},
+		{
+			name:  "path suffix keeps query and trailing slash",
+			link:  repository.Link{URL: "https://example.com/docs?v=2", ForwardPath: true},
+			visit: Visit{Path: "/guide/"},
+			want:  "https://example.com/docs/guide/?v=2",
+		},
+		{
+			name:  "path suffix cannot climb",
+			link:  repository.Link{URL: "https://example.com/docs", ForwardPath: true},
+			visit: Visit{Path: "/../../admin"},
+			want:  "https://example.com/docs/admin",
+		},
+		{
+			name:  "path suffix is escaped",
+			link:  repository.Link{URL: "https://example.com/files", ForwardPath: true},
+			visit: Visit{Path: "/a b/ü?.txt"},
+			want:  "https://example.com/files/a%20b/%C3%BC%3F.txt",
+		},
+		{
+			name:  "everything",
+			link:  repository.Link{URL: "https://example.com/shop", UTM: repository.UTM{Source: "qr"}, ForwardQuery: repository.ForwardOverride, ForwardPath: true},
+			visit: Visit{Path: "/shoes", Query: url.Values{"size": {"42"}}},
+			want:  "https://example.com/shop/shoes?size=42&utm_source=qr",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := forwardDestination(tt.link, tt.visit); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetOriginalURL_Forwarding(t *testing.T) {
+	link := repository.Link{Key: "docs", URL: "https://example.com/docs"}
+	clicks := 0
+	mockRepo := &MockRepository{
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			return link, nil
+		},
+		IncrClicksFunc: func(ctx context.Context, key, referrer string) (int64, error) {
+			clicks++
+			return int64(clicks), nil
+		},
+	}
+	service := NewShortenerService(mockRepo)
+	ctx := context.Background()
+
+	if _, err := service.GetOriginalURL(ctx, "docs", Visit{Path: "/guide"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected links without path forwarding to reject suffixes, got %v", err)
+	}
+	if clicks != 0 {
+		t.Errorf("expected rejected visits not to count, got %d", clicks)
+	}
+
+	link.ForwardPath = true
+	link.Variants = []repository.Variant{{URL: "https://example.com/v1", Weight: 1}, {URL: "https://example.com/v2", Weight: 1}}
+	got, err := service.GetOriginalURL(ctx, "docs", Visit{Path: "/guide", Variant: 2})
+	if err != nil || got.URL != "https://example.com/v2/guide" {
+		t.Errorf("expected the suffix on the chosen variant, got %s, %v", got.URL, err)
+	}
+
+	if _, err := service.ShortenURL(ctx, ShortenRequest{URL: "https://example.com", ForwardQuery: "merge"}); !errors.Is(err, ErrInvalidForwarding) {
+		t.Errorf("expected ErrInvalidForwarding, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+	"url-shortener/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultPasswordAttempts = 5
+	defaultPasswordWindow   = 15 * time.Minute
+	// maxPasswordLength is the most bcrypt will look at.
+	maxPasswordLength = 72
+)
+
+// hashPassword returns the hash stored for password, or "" when the link is
+// not protected.
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	if len(password) > maxPasswordLength {
+		return "", ErrInvalidPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkPassword lets the visit through unprotected links and links whose
+// password it carries. Wrong passwords count towards a per-link limit, so a
+// link cannot be brute forced no matter how many clients try. Every attempt
+// is counted before the password is compared, so concurrent guesses cannot
+// all slip under the limit; a right password clears the count again.
+func (s *service) checkPassword(ctx context.Context, link repository.Link, password string) error {
+	if link.PasswordHash == "" {
+		return nil
+	}
+	if password == "" {
+		return ErrPasswordRequired
+	}
+
+	attempts, err := s.repo.AddPasswordAttempt(ctx, link.Key, s.passwordWindow)
+	if err != nil {
+		return err
+	}
+	if attempts > s.passwordAttempts {
+		return ErrTooManyAttempts
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		if attempts == s.passwordAttempts {
+			slog.WarnContext(ctx, "link locked after wrong passwords", "key", link.Key, "window", s.passwordWindow)
+		}
+		return ErrWrongPassword
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.repo.ResetPasswordFailures(ctx, link.Key); err != nil {
+		slog.WarnContext(ctx, "failed to reset password failures", "key", link.Key, "error", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"url-shortener/repository"
+)
+
+func TestPasswordProtectedLink(t *testing.T) {
+	var saved repository.Link
+	failures := int64(0)
+	clicks := 0
+	mockRepo := &MockRepository{
+		SaveFunc: func(ctx context.Context, link repository.Link, ttl time.Duration) error {
+			saved = link
+			return nil
+		},
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			return saved, nil
+		},
+		IncrClicksFunc: func(ctx context.Context, key, referrer string) (int64, error) {
+			clicks++
+			return int64(clicks), nil
+		},
+		AddPasswordAttemptFunc: func(ctx context.Context, key string, window time.Duration) (int64, error) {
+			failures++
+			return failures, nil
+		},
+		ResetPasswordFailuresFunc: func(ctx context.Context, key string) error {
+			failures = 0
+			return nil
+		},
+	}
+	service := NewShortenerService(mockRepo, WithPasswordAttempts(2, time.Minute))
+	ctx := context.Background()
+
+	key, err := service.ShortenURL(ctx, ShortenRequest{URL: "https://example.com", Password: "open sesame"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if saved.PasswordHash == "" || saved.PasswordHash == "open sesame" {
+		t.Fatalf("expected a password hash, got %q", saved.PasswordHash)
+	}
+
+	if _, err := service.GetOriginalURL(ctx, key, Visit{}); !errors.Is(err, ErrPasswordRequired) {
+		t.Errorf("expected ErrPasswordRequired, got %v", err)
+	}
+	if _, err := service.PreviewLink(ctx, key, Visit{}); !errors.Is(err, ErrPasswordRequired) {
+		t.Errorf("expected preview to need the password too, got %v", err)
+	}
+
+	link, err := service.GetOriginalURL(ctx, key, Visit{Password: "open sesame"})
+	if err != nil || link.URL != "https://example.com" {
+		t.Fatalf("unexpected result: %+v, %v", link, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := service.GetOriginalURL(ctx, key, Visit{Password: "guess"}); !errors.Is(err, ErrWrongPassword) {
+			t.Errorf("attempt %d: expected ErrWrongPassword, got %v", i+1, err)
+		}
+	}
+	// Once the limit is reached even the right password is refused.
+	if _, err := service.GetOriginalURL(ctx, key, Visit{Password: "open sesame"}); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("expected ErrTooManyAttempts, got %v", err)
+	}
+	if clicks != 1 {
+		t.Errorf("expected only the unlocked visit to count, got %d clicks", clicks)
+	}
+}
+
+func TestShortenURL_PasswordTooLong(t *testing.T) {
+	service := NewShortenerService(&MockRepository{})
+
+	_, err := service.ShortenURL(context.Background(), ShortenRequest{
+		URL:      "https://example.com",
+		Password: string(make([]byte, maxPasswordLength+1)),
+	})
+	if !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("expected ErrInvalidPassword, got %v", err)
+	}
+}
+
+func TestPasswordProtectedLink_ConcurrentGuesses(t *testing.T) {
+	hash, err := hashPassword("open sesame")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var attempts atomic.Int64
+	mockRepo := &MockRepository{
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			return repository.Link{Key: key, URL: "https://example.com", PasswordHash: hash}, nil
+		},
+		AddPasswordAttemptFunc: func(ctx context.Context, key string, window time.Duration) (int64, error) {
+			return attempts.Add(1), nil
+		},
+	}
+	service := NewShortenerService(mockRepo, WithPasswordAttempts(3, time.Minute))
+
+	var wrong atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.GetOriginalURL(context.Background(), "abc", Visit{Password: "guess"})
+			switch {
+			case errors.Is(err, ErrWrongPassword):
+				wrong.Add(1)
+			case !errors.Is(err, ErrTooManyAttempts):
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if wrong.Load() != 3 {
+		t.Errorf("expected only 3 guesses to be checked, got %d", wrong.Load())
+	}
+}
+
+func TestShortenURL_PasswordGetsItsOwnKey(t *testing.T) {
+	links := map[string]repository.Link{}
+	mockRepo := &MockRepository{
+		SaveFunc: func(ctx context.Context, link repository.Link, ttl time.Duration) error {
+			if existing, ok := links[link.Key]; ok && existing.PasswordHash != link.PasswordHash {
+				return repository.ErrConflict
+			}
+			links[link.Key] = link
+			return nil
+		},
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			link, ok := links[key]
+			if !ok {
+				return repository.Link{}, repository.ErrNotFound
+			}
+			return link, nil
+		},
+	}
+	service := NewShortenerService(mockRepo)
+	ctx := context.Background()
+
+	plain, err := service.ShortenURL(ctx, ShortenRequest{URL: "https://example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	locked, err := service.ShortenURL(ctx, ShortenRequest{URL: "https://example.com", Password: "open sesame"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	other, err := service.ShortenURL(ctx, ShortenRequest{URL: "https://example.com", Password: "open sesame"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if locked == plain || other == locked {
+		t.Fatalf("expected every protected link to get its own key, got %q, %q and %q", plain, locked, other)
+	}
+	if again, _ := service.ShortenURL(ctx, ShortenRequest{URL: "https://example.com"}); again != plain {
+		t.Errorf("expected the plain link to keep its key, got %q and %q", plain, again)
+	}
+
+	if _, err := service.GetOriginalURL(ctx, locked, Visit{}); !errors.Is(err, ErrPasswordRequired) {
+		t.Errorf("expected ErrPasswordRequired, got %v", err)
+	}
+	if _, err := service.GetOriginalURL(ctx, plain, Visit{}); err != nil {
+		t.Errorf("expected the plain link to stay open, got %v", err)
+	}
+}
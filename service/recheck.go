@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"time"
+	"url-shortener/repository"
+)
+
+type RecheckSummary struct {
+	Checked  int
+	Disabled int
+}
+
+func (s *service) RecheckLinks(ctx context.Context) (RecheckSummary, error) {
+	var summary RecheckSummary
+	if s.policy == nil {
+		return summary, nil
+	}
+
+	now := time.Now().UTC()
+	err := s.repo.ScanLinks(ctx, func(link repository.Link) error {
+		if link.DisabledReason != "" || (link.ApprovedURL != "" && link.ApprovedURL == link.URL) {
+			return nil
+		}
+
+		summary.Checked++
+		policyErr := s.recheckDestinations(ctx, link)
+		if policyErr == nil {
+			return nil
+		}
+
+		err := s.repo.Disable(ctx, link.Key, policyErr.Rule, now)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "link disabled", "key", link.Key, "rule", policyErr.Rule, "url", link.URL)
+		summary.Disabled++
+		return nil
+	})
+	return summary, err
+}
+
+// recheckDestinations returns the first policy rejection of the link's URL,
+// its fallback, one of its rule targets or one of its variants. Policies
+// that could not decide are retried on the next run.
+func (s *service) recheckDestinations(ctx context.Context, link repository.Link) *PolicyError {
+	destinations := []string{link.URL}
+	if link.FallbackURL != "" {
+		destinations = append(destinations, link.FallbackURL)
+	}
+	for _, rule := range link.Rules {
+		destinations = append(destinations, rule.URL)
+	}
+	for _, variant := range link.Variants {
+		destinations = append(destinations, variant.URL)
+	}
+	for _, destination := range destinations {
+		u, err := url.Parse(destination)
+		if err != nil {
+			continue
+		}
+		var policyErr *PolicyError
+		if err := s.policy.Check(ctx, u); errors.As(err, &policyErr) {
+			return policyErr
+		}
+	}
+	return nil
+}
+
+func (s *service) ListDisabledLinks(ctx context.Context) ([]repository.Link, error) {
+	return s.repo.ListDisabled(ctx)
+}
+
+func (s *service) EnableLink(ctx context.Context, shortKey string) error {
+	err := s.repo.Enable(ctx, shortKey)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"url-shortener/repository"
+)
+
+func TestRecheckLinks(t *testing.T) {
+	stored := []repository.Link{
+		{Key: "ok", URL: "https://example.com"},
+		{Key: "bad", URL: "https://evil.example/login"},
+		{Key: "flagged", URL: "https://evil.example/", DisabledReason: "blocklist:evil.example"},
+		{Key: "approved", URL: "https://evil.example/docs", ApprovedURL: "https://evil.example/docs"},
+		{Key: "moved", URL: "https://evil.example/new", ApprovedURL: "https://evil.example/old"},
+		{Key: "unsure", URL: "https://flaky.example/"},
+		{Key: "ruled", URL: "https://example.com", Rules: []repository.Rule{{Platform: "ios", URL: "https://evil.example/app"}}},
+		{Key: "fallback", URL: "https://example.com", FallbackURL: "https://evil.example/soon"},
+	}
+	disabled := make(map[string]string)
+	mockRepo := &MockRepository{
+		ScanLinksFunc: func(ctx context.Context, fn func(repository.Link) error) error {
+			for _, link := range stored {
+				if err := fn(link); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		DisableFunc: func(ctx context.Context, key string, reason string, at time.Time) error {
+			disabled[key] = reason
+			return nil
+		},
+	}
+	policy := &stubPolicy{blocked: map[string]string{"evil.example": "blocklist:evil.example"}}
+	service := NewShortenerService(mockRepo, WithPolicy(policy))
+
+	summary, err := service.RecheckLinks(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if summary.Checked != 6 || summary.Disabled != 4 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+	if len(disabled) != 4 || disabled["bad"] != "blocklist:evil.example" || disabled["moved"] == "" || disabled["ruled"] == "" || disabled["fallback"] == "" {
+		t.Errorf("unexpected disabled links: %v", disabled)
+	}
+}
+
+func TestRecheckLinks_WithoutPolicy(t *testing.T) {
+	mockRepo := &MockRepository{
+		ScanLinksFunc: func(ctx context.Context, fn func(repository.Link) error) error {
+			t.Error("expected no scan without a policy")
+			return nil
+		},
+	}
+	service := NewShortenerService(mockRepo)
+
+	if _, err := service.RecheckLinks(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestEnableLink_NotFound(t *testing.T) {
+	mockRepo := &MockRepository{
+		EnableFunc: func(ctx context.Context, key string) error {
+			return repository.ErrNotFound
+		},
+	}
+	service := NewShortenerService(mockRepo)
+
+	if err := service.EnableLink(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
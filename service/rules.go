@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/repository"
+)
+
+const maxRules = 20
+
+// CountryResolver maps a visitor's IP address to an ISO 3166-1 alpha-2
+// country code, or "" when it is unknown.
+type CountryResolver interface {
+	Country(ip net.IP) (string, error)
+}
+
+var (
+	languagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+	countryPattern  = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// platforms are the values a rule's Platform may take.
+var platforms = map[string]bool{
+	"ios":     true,
+	"android": true,
+	"windows": true,
+	"macos":   true,
+	"linux":   true,
+}
+
+// newRules validates and normalises the rules of a new link.
+func (s *service) newRules(ctx context.Context, rules []repository.Rule) ([]repository.Rule, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	if len(rules) > maxRules {
+		return nil, fmt.Errorf("%w: at most %d rules are allowed", ErrInvalidRule, maxRules)
+	}
+
+	normalized := make([]repository.Rule, len(rules))
+	for i, rule := range rules {
+		rule.Platform = strings.ToLower(rule.Platform)
+		rule.Language = strings.ToLower(rule.Language)
+		rule.Country = strings.ToUpper(rule.Country)
+		if err := s.checkRule(ctx, rule); err != nil {
+			return nil, fmt.Errorf("%w %d: %w", ErrInvalidRule, i+1, err)
+		}
+		normalized[i] = rule
+	}
+	return normalized, nil
+}
+
+func (s *service) checkRule(ctx context.Context, rule repository.Rule) error {
+	if rule.Platform == "" && rule.Language == "" && rule.Country == "" && rule.From == "" && rule.To == "" {
+		return errors.New("no conditions")
+	}
+	if rule.Platform != "" && !platforms[rule.Platform] {
+		return fmt.Errorf("unknown platform %q", rule.Platform)
+	}
+	if rule.Language != "" && !languagePattern.MatchString(rule.Language) {
+		return fmt.Errorf("invalid language %q", rule.Language)
+	}
+	if rule.Country != "" {
+		if !countryPattern.MatchString(rule.Country) {
+			return fmt.Errorf("invalid country %q", rule.Country)
+		}
+		if s.countries == nil {
+			return errors.New("country rules need a GeoIP database")
+		}
+	}
+	if rule.From != "" || rule.To != "" {
+		if _, err := parseClock(rule.From); err != nil {
+			return fmt.Errorf("invalid from %q", rule.From)
+		}
+		if _, err := parseClock(rule.To); err != nil {
+			return fmt.Errorf("invalid to %q", rule.To)
+		}
+		if _, err := time.LoadLocation(rule.Timezone); err != nil {
+			return fmt.Errorf("unknown timezone %q", rule.Timezone)
+		}
+	} else if rule.Timezone != "" {
+		return errors.New("timezone without from and to")
+	}
+	return s.checkDestination(ctx, rule.URL)
+}
+
+// applyRules points link at the target of the first rule the visit matches
+// and reports whether one did. Links without a matching rule keep their own
+// URL.
+func (s *service) applyRules(link repository.Link, visit Visit, now time.Time) (repository.Link, bool) {
+	if len(link.Rules) == 0 {
+		return link, false
+	}
+
+	v := visitor{
+		platform: platformOf(visit.UserAgent),
+		language: preferredLanguage(visit.AcceptLanguage),
+	}
+	resolved := false
+	for _, rule := range link.Rules {
+		if rule.Country != "" && !resolved {
+			v.country = s.country(visit.IP)
+			resolved = true
+		}
+		if v.matches(rule, now) {
+			link.URL = rule.URL
+			return link, true
+		}
+	}
+	return link, false
+}
+
+// country looks up ip, treating every failure as an unknown country so that
+// GeoIP problems never break a redirect.
+func (s *service) country(ip string) string {
+	parsed := net.ParseIP(ip)
+	if s.countries == nil || parsed == nil {
+		return ""
+	}
+	country, err := s.countries.Country(parsed)
+	if err != nil {
+		return ""
+	}
+	return country
+}
+
+type visitor struct {
+	platform string
+	language string
+	country  string
+}
+
+func (v visitor) matches(rule repository.Rule, now time.Time) bool {
+	if rule.Platform != "" && rule.Platform != v.platform {
+		return false
+	}
+	if rule.Language != "" && v.language != rule.Language && !strings.HasPrefix(v.language, rule.Language+"-") {
+		return false
+	}
+	if rule.Country != "" && rule.Country != v.country {
+		return false
+	}
+	if rule.From != "" {
+		return inWindow(rule, now)
+	}
+	return true
+}
+
+// inWindow reports whether now falls between the rule's From (inclusive) and
+// To (exclusive) in its timezone.
+func inWindow(rule repository.Rule, now time.Time) bool {
+	from, _ := parseClock(rule.From)
+	to, _ := parseClock(rule.To)
+	loc, err := time.LoadLocation(rule.Timezone)
+	if err != nil {
+		return false
+	}
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if from <= to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
+
+// parseClock returns the minute of the day for an HH:MM time.
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// platformOf guesses the operating system from a User-Agent header. iPads
+// asking for desktop sites identify as macOS and are treated as such.
+func platformOf(userAgent string) string {
+	switch {
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"), strings.Contains(userAgent, "iPod"):
+		return "ios"
+	case strings.Contains(userAgent, "Android"):
+		return "android"
+	case strings.Contains(userAgent, "Windows"):
+		return "windows"
+	case strings.Contains(userAgent, "Macintosh"), strings.Contains(userAgent, "Mac OS X"):
+		return "macos"
+	case strings.Contains(userAgent, "Linux"):
+		return "linux"
+	}
+	return ""
+}
+
+// preferredLanguage returns the lower-cased tag with the highest quality in
+// an Accept-Language header. Ties go to the tag listed first.
+func preferredLanguage(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+	return best
+}
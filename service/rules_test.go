@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+	"url-shortener/repository"
+)
+
+const (
+	iPhoneUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1"
+	androidUA = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36"
+	windowsUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+	macUA     = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15"
+	linuxUA   = "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0"
+)
+
+type stubCountries map[string]string
+
+func (c stubCountries) Country(ip net.IP) (string, error) {
+	country, ok := c[ip.String()]
+	if !ok {
+		return "", errors.New("address not in database")
+	}
+	return country, nil
+}
+
+func TestPlatformOf(t *testing.T) {
+	tests := map[string]string{
+		iPhoneUA:     "ios",
+		androidUA:    "android",
+		windowsUA:    "windows",
+		macUA:        "macos",
+		linuxUA:      "linux",
+		"curl/8.5.0": "",
+	}
+	for ua, want := range tests {
+		if got := platformOf(ua); got != want {
+			t.Errorf("platformOf(%q) = %q, want %q", ua, got, want)
+		}
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := map[string]string{
+		"":                             "",
+		"de-DE,de;q=0.9,en;q=0.8":      "de-de",
+		"en;q=0.5, fr-CH":              "fr-ch",
+		"*;q=1, pt-BR;q=0.7, es;q=0.7": "pt-br",
+		"nl;q=bogus, it;q=0.1":         "it",
+		"en;q=0":                       "",
+	}
+	for header, want := range tests {
+		if got := preferredLanguage(header); got != want {
+			t.Errorf("preferredLanguage(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestInWindow(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 5, 1, hour, minute, 0, 0, time.UTC)
+	}
+	day := repository.Rule{From: "09:00", To: "17:30"}
+	night := repository.Rule{From: "22:00", To: "06:00"}
+
+	tests := []struct {
+		rule repository.Rule
+		now  time.Time
+		want bool
+	}{
+		{day, at(9, 0), true},
+		{day, at(17, 29), true},
+		{day, at(17, 30), false},
+		{day, at(8, 59), false},
+		{night, at(23, 0), true},
+		{night, at(2, 0), true},
+		{night, at(6, 0), false},
+		{night, at(12, 0), false},
+	}
+	for _, tt := range tests {
+		if got := inWindow(tt.rule, tt.now); got != tt.want {
+			t.Errorf("inWindow(%s-%s, %s) = %v, want %v", tt.rule.From, tt.rule.To, tt.now.Format("15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestApplyRules(t *testing.T) {
+	s := NewShortenerService(&MockRepository{}, WithCountryResolver(stubCountries{"203.0.113.7": "DE"})).(*service)
+	link := repository.Link{
+		URL: "https://example.com",
+		Rules: []repository.Rule{
+			{Platform: "ios", URL: "https://apps.apple.com/app/id1"},
+			{Platform: "android", URL: "https://play.google.com/store/apps/details?id=app"},
+			{Country: "DE", URL: "https://example.de"},
+			{Language: "fr", URL: "https://example.fr"},
+			{From: "00:00", To: "06:00", URL: "https://example.com/night"},
+		},
+	}
+	noon := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		visit Visit
+		now   time.Time
+		want  string
+	}{
+		{"ios", Visit{UserAgent: iPhoneUA, IP: "203.0.113.7"}, noon, "https://apps.apple.com/app/id1"},
+		{"android", Visit{UserAgent: androidUA}, noon, "https://play.google.com/store/apps/details?id=app"},
+		{"country", Visit{UserAgent: windowsUA, IP: "203.0.113.7"}, noon, "https://example.de"},
+		{"language", Visit{UserAgent: macUA, AcceptLanguage: "fr-CA,en;q=0.5"}, noon, "https://example.fr"},
+		{"time of day", Visit{UserAgent: linuxUA}, noon.Add(-9 * time.Hour), "https://example.com/night"},
+		{"unknown country", Visit{UserAgent: windowsUA, IP: "198.51.100.1"}, noon, "https://example.com"},
+		{"default", Visit{}, noon, "https://example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := s.applyRules(link, tt.visit, tt.now); got.URL != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got.URL)
+			}
+		})
+	}
+}
+
+func TestGetOriginalURL_Rules(t *testing.T) {
+	var saved repository.Link
+	mockRepo := &MockRepository{
+		SaveFunc: func(ctx context.Context, link repository.Link, ttl time.Duration) error {
+			saved = link
+			return nil
+		},
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			return saved, nil
+		},
+	}
+	service := NewShortenerService(mockRepo)
+	ctx := context.Background()
+
+	_, err := service.ShortenURL(ctx, ShortenRequest{
+		URL:   "https://example.com",
+		Rules: []repository.Rule{{Platform: "iOS", URL: "https://apps.apple.com/app/id1"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if saved.Rules[0].Platform != "ios" {
+		t.Errorf("expected the platform to be normalised, got %q", saved.Rules[0].Platform)
+	}
+
+	link, err := service.GetOriginalURL(ctx, saved.Key, Visit{UserAgent: iPhoneUA})
+	if err != nil || link.URL != "https://apps.apple.com/app/id1" {
+		t.Errorf("expected the app store, got %s, %v", link.URL, err)
+	}
+	link, _ = service.GetOriginalURL(ctx, saved.Key, Visit{UserAgent: androidUA})
+	if link.URL != "https://example.com" {
+		t.Errorf("expected the original URL, got %s", link.URL)
+	}
+}
+
+func TestShortenURL_InvalidRules(t *testing.T) {
+	service := NewShortenerService(&MockRepository{})
+	target := "https://example.org"
+
+	tests := []struct {
+		name string
+		rule repository.Rule
+		err  error
+	}{
+		{"no conditions", repository.Rule{URL: target}, ErrInvalidRule},
+		{"unknown platform", repository.Rule{Platform: "symbian", URL: target}, ErrInvalidRule},
+		{"invalid language", repository.Rule{Language: "english", URL: target}, ErrInvalidRule},
+		{"invalid country", repository.Rule{Country: "DEU", URL: target}, ErrInvalidRule},
+		{"country without geoip", repository.Rule{Country: "DE", URL: target}, ErrInvalidRule},
+		{"half a window", repository.Rule{From: "09:00", URL: target}, ErrInvalidRule},
+		{"invalid clock", repository.Rule{From: "25:00", To: "06:00", URL: target}, ErrInvalidRule},
+		{"unknown timezone", repository.Rule{From: "09:00", To: "17:00", Timezone: "Mars/Olympus", URL: target}, ErrInvalidRule},
+		{"timezone alone", repository.Rule{Platform: "ios", Timezone: "UTC", URL: target}, ErrInvalidRule},
+		{"invalid target", repository.Rule{Platform: "ios", URL: "itms-apps://app"}, ErrInvalidURL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.ShortenURL(context.Background(), ShortenRequest{
+				URL:   "https://example.com",
+				Rules: []repository.Rule{tt.rule},
+			})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+
+	rules := make([]repository.Rule, maxRules+1)
+	for i := range rules {
+		rules[i] = repository.Rule{Platform: "ios", URL: target}
+	}
+	if _, err := service.ShortenURL(context.Background(), ShortenRequest{URL: "https://example.com", Rules: rules}); !errors.Is(err, ErrInvalidRule) {
+		t.Errorf("expected too many rules to be rejected, got %v", err)
+	}
+}
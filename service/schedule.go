@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"time"
+	"url-shortener/repository"
+)
+
+// InactiveError is returned for links visited outside their activation
+// window.
+type InactiveError struct {
+	// Ended is true once the window has closed and false before it opens.
+	Ended bool
+	// FallbackURL is where the visitor should be sent instead, if the link
+	// has one.
+	FallbackURL string
+}
+
+func (e *InactiveError) Error() string {
+	if e.Ended {
+		return "link is no longer active"
+	}
+	return "link is not active yet"
+}
+
+// schedule is the validated activation window of a new link.
+type schedule struct {
+	notBefore   time.Time
+	notAfter    time.Time
+	fallbackURL string
+}
+
+// newSchedule validates the activation window of req. Times are stored in
+// UTC with second precision, which is what the repository keeps.
+func (s *service) newSchedule(ctx context.Context, req ShortenRequest) (schedule, error) {
+	sch := schedule{fallbackURL: req.FallbackURL}
+	if !req.NotBefore.IsZero() {
+		sch.notBefore = req.NotBefore.UTC().Truncate(time.Second)
+	}
+	if !req.NotAfter.IsZero() {
+		sch.notAfter = req.NotAfter.UTC().Truncate(time.Second)
+		if !sch.notAfter.After(time.Now()) {
+			return schedule{}, ErrInvalidSchedule
+		}
+		if !sch.notBefore.IsZero() && !sch.notAfter.After(sch.notBefore) {
+			return schedule{}, ErrInvalidSchedule
+		}
+	}
+
+	if sch.fallbackURL != "" {
+		if sch.notBefore.IsZero() && sch.notAfter.IsZero() {
+			return schedule{}, ErrInvalidSchedule
+		}
+		if err := s.checkDestination(ctx, sch.fallbackURL); err != nil {
+			return schedule{}, err
+		}
+	}
+	return sch, nil
+}
+
+// checkSchedule lets the visit through if now is inside the link's
+// activation window.
+func checkSchedule(link repository.Link, now time.Time) error {
+	switch {
+	case !link.NotBefore.IsZero() && now.Before(link.NotBefore):
+		return &InactiveError{FallbackURL: link.FallbackURL}
+	case !link.NotAfter.IsZero() && !now.Before(link.NotAfter):
+		return &InactiveError{Ended: true, FallbackURL: link.FallbackURL}
+	}
+	return nil
+}
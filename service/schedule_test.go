@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"url-shortener/repository"
+)
+
+func TestScheduledLink(t *testing.T) {
+	var saved repository.Link
+	clicks := 0
+	mockRepo := &MockRepository{
+		SaveFunc: func(ctx context.Context, link repository.Link, ttl time.Duration) error {
+			saved = link
+			return nil
+		},
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			return saved, nil
+		},
+		IncrClicksFunc: func(ctx context.Context, key, referrer string) (int64, error) {
+			clicks++
+			return int64(clicks), nil
+		},
+	}
+	service := NewShortenerService(mockRepo)
+	ctx := context.Background()
+
+	berlin := time.FixedZone("CEST", 2*60*60)
+	start := time.Now().Add(time.Hour).In(berlin)
+	_, err := service.ShortenURL(ctx, ShortenRequest{
+		URL:         "https://example.com/sale",
+		NotBefore:   start,
+		NotAfter:    start.Add(24 * time.Hour),
+		FallbackURL: "https://example.com",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if saved.NotBefore.Location() != time.UTC || !saved.NotBefore.Equal(start.Truncate(time.Second)) {
+		t.Errorf("expected the window to be stored in UTC, got %v", saved.NotBefore)
+	}
+
+	var inactive *InactiveError
+	if _, err := service.GetOriginalURL(ctx, saved.Key, Visit{}); !errors.As(err, &inactive) {
+		t.Fatalf("expected InactiveError, got %v", err)
+	}
+	if inactive.Ended || inactive.FallbackURL != "https://example.com" {
+		t.Errorf("unexpected inactive error: %+v", inactive)
+	}
+
+	saved.NotBefore = time.Now().Add(-2 * time.Hour)
+	saved.NotAfter = time.Now().Add(-time.Hour)
+	saved.FallbackURL = ""
+	if _, err := service.GetOriginalURL(ctx, saved.Key, Visit{}); !errors.As(err, &inactive) || !inactive.Ended {
+		t.Errorf("expected an ended link, got %v", err)
+	}
+
+	saved.NotAfter = time.Now().Add(time.Hour)
+	link, err := service.GetOriginalURL(ctx, saved.Key, Visit{})
+	if err != nil || link.URL != "https://example.com/sale" {
+		t.Errorf("expected the link to resolve inside its window, got %v, %v", link.URL, err)
+	}
+	if clicks != 1 {
+		t.Errorf("expected only visits inside the window to count, got %d", clicks)
+	}
+}
+
+func TestShortenURL_InvalidSchedule(t *testing.T) {
+	service := NewShortenerService(&MockRepository{})
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		req  ShortenRequest
+		err  error
+	}{
+		{"ends in the past", ShortenRequest{NotAfter: now.Add(-time.Minute)}, ErrInvalidSchedule},
+		{"ends before it starts", ShortenRequest{NotBefore: now.Add(2 * time.Hour), NotAfter: now.Add(time.Hour)}, ErrInvalidSchedule},
+		{"fallback without window", ShortenRequest{FallbackURL: "https://example.org"}, ErrInvalidSchedule},
+		{"invalid fallback", ShortenRequest{NotAfter: now.Add(time.Hour), FallbackURL: "ftp://example.org"}, ErrInvalidURL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.URL = "https://example.com"
+			if _, err := service.ShortenURL(context.Background(), tt.req); !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
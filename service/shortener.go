@@ -2,33 +2,833 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"hash/fnv"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 	"url-shortener/repository"
 )
 
+var (
+	ErrNotFound            = errors.New("link not found")
+	ErrForbidden           = errors.New("access to link denied")
+	ErrUnauthorized        = errors.New("invalid api key")
+	ErrInvalidCursor       = errors.New("invalid cursor")
+	ErrInvalidURL          = errors.New("invalid url")
+	ErrInvalidAlias        = errors.New("invalid alias")
+	ErrInvalidTTL          = errors.New("invalid ttl")
+	ErrConflict            = errors.New("short key already taken")
+	ErrInvalidOwner        = errors.New("invalid owner")
+	ErrDisabled            = errors.New("link disabled")
+	ErrInvalidInterstitial = errors.New("invalid interstitial")
+	ErrInvalidPassword     = errors.New("invalid password")
+	ErrPasswordRequired    = errors.New("password required")
+	ErrWrongPassword       = errors.New("wrong password")
+	ErrTooManyAttempts     = errors.New("too many wrong passwords")
+	ErrInvalidMaxClicks    = errors.New("invalid max clicks")
+	ErrExhausted           = errors.New("link has no uses left")
+	ErrInvalidSchedule     = errors.New("invalid activation window")
+	ErrInvalidRule         = errors.New("invalid rule")
+	ErrInvalidVariant      = errors.New("invalid variant")
+	ErrInvalidForwarding   = errors.New("invalid query forwarding")
+)
+
+var (
+	aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,64}$`)
+	// importKeyPattern is looser than aliasPattern so that keys from other
+	// shorteners survive a migration, but still only allows characters that
+	// need no escaping in a URL path.
+	importKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.~-]{1,64}$`)
+)
+
+// reservedAliases are path segments routed to other endpoints under /api/v1.
+var reservedAliases = map[string]bool{
+	"links":    true,
+	"batch":    true,
+	"admin":    true,
+	"webhooks": true,
+}
+
+// DestinationPolicy decides whether links may point at a URL. Rejections are
+// reported as *PolicyError.
+type DestinationPolicy interface {
+	Check(ctx context.Context, u *url.URL) error
+}
+
+// PolicyError is returned when a destination is rejected by a policy rule.
+type PolicyError struct {
+	Rule string
+}
+
+func (e *PolicyError) Error() string {
+	return "destination blocked by rule " + e.Rule
+}
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	maxInterstitial = time.Minute
+)
+
+type ShortenRequest struct {
+	URL   string
+	Owner string
+	// Alias is a caller chosen key used instead of a generated one.
+	Alias string
+	// TTL is how long the link lives. Zero keeps it forever.
+	TTL time.Duration
+	// Interstitial makes visitors wait on a preview page for this long
+	// before they are redirected.
+	Interstitial time.Duration
+	// Password protects the link. Only its hash is stored.
+	Password string
+	// MaxClicks burns the link after this many visits. Zero means no limit.
+	MaxClicks int64
+	// NotBefore and NotAfter limit when the link can be followed. Zero
+	// leaves that side of the window open.
+	NotBefore time.Time
+	NotAfter  time.Time
+	// FallbackURL is where visitors are sent outside the activation window.
+	// Without one they get an error instead.
+	FallbackURL string
+	// Rules send matching visitors to other destinations, see
+	// repository.Rule.
+	Rules []repository.Rule
+	// Variants split redirects between destinations by weight.
+	Variants []repository.Variant
+	// UTM parameters are added to the destination at redirect time.
+	UTM repository.UTM
+	// ForwardQuery passes the visitor's query string on to the destination
+	// and ForwardPath anything after the key.
+	ForwardQuery repository.QueryForwarding
+	ForwardPath  bool
+	// Signed links can only be followed through a signed URL.
+	Signed bool
+}
+
+// Visit describes a request to follow a link.
+type Visit struct {
+	// Password unlocks a password protected link.
+	Password string
+	// UserAgent, AcceptLanguage and IP describe the visitor for redirect
+	// rules.
+	UserAgent      string
+	AcceptLanguage string
+	IP             string
+	// Variant is the 1-based variant the visitor was given before, usually
+	// remembered in a cookie. Zero assigns one.
+	Variant int
+	// Query and Path are the query string and the path after the key the
+	// visitor arrived with. Links only accept a Path if they forward it.
+	Query url.Values
+	Path  string
+	// Referrer is the visitor's Referer header, ranked by domain in the
+	// admin statistics.
+	Referrer string
+	// Signed is set when the visitor's URL carried a valid signature.
+	Signed bool
+}
+
+type BatchResult struct {
+	Key string
+	Err error
+}
+
+// Identity is the caller resolved from an API key.
+type Identity struct {
+	Owner string
+	Admin bool
+}
+
+type ListRequest struct {
+	Owner     string
+	Sort      repository.SortField
+	Ascending bool
+	Cursor    string
+	Limit     int
+}
+
+type LinkPage struct {
+	Links      []repository.Link
+	NextCursor string
+}
+
 type ShortenerService interface {
-	ShortenURL(ctx context.Context, originalURL string) string
-	GetOriginalURL(ctx context.Context, shortKey string) (string, error)
+	ShortenURL(ctx context.Context, req ShortenRequest) (string, error)
+	// ShortenBatch shortens every request independently, so one invalid
+	// entry does not fail the others. Results are in request order.
+	ShortenBatch(ctx context.Context, reqs []ShortenRequest) []BatchResult
+	// GetOriginalURL resolves a link for a visit and counts the click.
+	// Visits outside the link's activation window fail with *InactiveError.
+	GetOriginalURL(ctx context.Context, shortKey string, visit Visit) (repository.Link, error)
+	// PreviewLink resolves a link like GetOriginalURL without counting a
+	// click. Links split between variants show their canonical URL.
+	PreviewLink(ctx context.Context, shortKey string, visit Visit) (repository.Link, error)
+	UpdateURL(ctx context.Context, id Identity, shortKey string, url string) error
+	DeleteURL(ctx context.Context, id Identity, shortKey string) error
+	ListLinks(ctx context.Context, req ListRequest) (LinkPage, error)
+	Authenticate(ctx context.Context, apiKey string) (Identity, error)
+	InspectLink(ctx context.Context, id Identity, shortKey string) (repository.Link, error)
+	// ClickTimeseries returns the clicks of a link owned by the caller per
+	// hour or day, with empty buckets filled in.
+	ClickTimeseries(ctx context.Context, id Identity, shortKey string, req TimeseriesRequest) (Timeseries, error)
+	// CreateAPIKey issues a new key for owner. The returned token is the only
+	// copy of the secret; only its hash is stored.
+	CreateAPIKey(ctx context.Context, owner string, admin bool) (string, repository.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string) error
+	ListAPIKeys(ctx context.Context) ([]repository.APIKey, error)
+	// PurgeExpired cleans up after expired links and emits an expired event
+	// for each of them.
+	PurgeExpired(ctx context.Context) (int, error)
+	ExportLinks(ctx context.Context, fn func(repository.Link) error) error
+	// ImportLinks stores links under their existing keys and returns one
+	// error per link, in order.
+	ImportLinks(ctx context.Context, links []repository.Link) []error
+	// RecheckLinks runs every stored link through the destination policy
+	// again and disables the ones it now rejects.
+	RecheckLinks(ctx context.Context) (RecheckSummary, error)
+	ListDisabledLinks(ctx context.Context) ([]repository.Link, error)
+	EnableLink(ctx context.Context, shortKey string) error
+	// TopLinks and TopReferrers rank the most clicked links and referring
+	// domains of a window for admins.
+	TopLinks(ctx context.Context, req DashboardRequest) (Ranking, error)
+	TopReferrers(ctx context.Context, req DashboardRequest) (Ranking, error)
+	// LinksCreated returns the number of links created per day.
+	LinksCreated(ctx context.Context, req DashboardRequest) (DailyCounts, error)
+	// SaveDomain registers a domain with its own namespace of keys, or
+	// updates its settings, see WithDomain.
+	SaveDomain(ctx context.Context, d repository.Domain) (repository.Domain, error)
+	GetDomain(ctx context.Context, name string) (repository.Domain, error)
+	ListDomains(ctx context.Context) ([]repository.Domain, error)
+	DeleteDomain(ctx context.Context, name string) error
 }
 
 type service struct {
-	repo repository.Repository
+	repo   repository.Repository
+	policy DestinationPolicy
+
+	passwordAttempts int64
+	passwordWindow   time.Duration
+
+	countries CountryResolver
+	// random replaces rand.IntN for variant assignment when set.
+	random func(n int) int
+
+	handlers   []EventHandler
+	milestones []int64
+
+	// randomKeyLength replaces hashed keys with random ones when set.
+	randomKeyLength int
+}
+
+type Option func(*service)
+
+// WithPolicy checks every destination against p before it is stored.
+func WithPolicy(p DestinationPolicy) Option {
+	return func(s *service) {
+		s.policy = p
+	}
+}
+
+// MinRandomKeyLength is the shortest random key. Eight base62 characters
+// carry about 47 bits, so even with millions of live links a scanner needs
+// billions of requests per hit.
+const MinRandomKeyLength = 8
+
+// WithRandomKeys generates keys of n random characters instead of hashing
+// the URL, so keys can be neither derived from destinations nor enumerated.
+// n is raised to MinRandomKeyLength. Shortening a URL twice then creates two
+// links.
+func WithRandomKeys(n int) Option {
+	return func(s *service) {
+		s.randomKeyLength = max(n, MinRandomKeyLength)
+	}
+}
+
+// WithPasswordAttempts allows n wrong passwords per link within window
+// before further attempts are refused until the window has passed.
+func WithPasswordAttempts(n int, window time.Duration) Option {
+	return func(s *service) {
+		s.passwordAttempts = int64(n)
+		s.passwordWindow = window
+	}
+}
+
+// WithCountryResolver enables redirect rules that match on the visitor's
+// country.
+func WithCountryResolver(r CountryResolver) Option {
+	return func(s *service) {
+		s.countries = r
+	}
+}
+
+func NewShortenerService(repo repository.Repository, opts ...Option) ShortenerService {
+	s := &service{
+		repo:             repo,
+		passwordAttempts: defaultPasswordAttempts,
+		passwordWindow:   defaultPasswordWindow,
+		milestones:       DefaultMilestones,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *service) ShortenURL(ctx context.Context, req ShortenRequest) (string, error) {
+	link, err := s.newLink(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.repo.Save(ctx, link, req.TTL); err != nil {
+		return "", saveError(err)
+	}
+	slog.InfoContext(ctx, "link created", "key", link.Key, "owner", link.Owner)
+	s.emit(ctx, Event{Type: EventLinkCreated, Key: link.Key, Owner: link.Owner, URL: link.URL, At: link.CreatedAt})
+	return link.Key, nil
+}
+
+func (s *service) ShortenBatch(ctx context.Context, reqs []ShortenRequest) []BatchResult {
+	results := make([]BatchResult, len(reqs))
+
+	saves := make([]repository.SaveRequest, 0, len(reqs))
+	indexes := make([]int, 0, len(reqs))
+	for i, req := range reqs {
+		link, err := s.newLink(ctx, req)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Key = link.Key
+		saves = append(saves, repository.SaveRequest{Link: link, TTL: req.TTL})
+		indexes = append(indexes, i)
+	}
+
+	if len(saves) == 0 {
+		return results
+	}
+
+	for j, err := range s.repo.SaveBatch(ctx, saves) {
+		if err != nil {
+			i := indexes[j]
+			results[i] = BatchResult{Err: saveError(err)}
+			continue
+		}
+		link := saves[j].Link
+		s.emit(ctx, Event{Type: EventLinkCreated, Key: link.Key, Owner: link.Owner, URL: link.URL, At: link.CreatedAt})
+	}
+	return results
+}
+
+func (s *service) newLink(ctx context.Context, req ShortenRequest) (repository.Link, error) {
+	if err := s.checkDestination(ctx, req.URL); err != nil {
+		return repository.Link{}, err
+	}
+	if req.TTL < 0 {
+		return repository.Link{}, ErrInvalidTTL
+	}
+	if req.Interstitial < 0 || req.Interstitial > maxInterstitial {
+		return repository.Link{}, ErrInvalidInterstitial
+	}
+	if req.MaxClicks < 0 {
+		return repository.Link{}, ErrInvalidMaxClicks
+	}
+	sch, err := s.newSchedule(ctx, req)
+	if err != nil {
+		return repository.Link{}, err
+	}
+	rules, err := s.newRules(ctx, req.Rules)
+	if err != nil {
+		return repository.Link{}, err
+	}
+	variants, err := s.newVariants(ctx, req.Variants)
+	if err != nil {
+		return repository.Link{}, err
+	}
+	if !queryForwardings[req.ForwardQuery] {
+		return repository.Link{}, ErrInvalidForwarding
+	}
+	passwordHash, err := hashPassword(req.Password)
+	if err != nil {
+		return repository.Link{}, err
+	}
+
+	shortKey := req.Alias
+	if shortKey == "" && s.randomKeyLength > 0 {
+		shortKey, err = randomKey(s.randomKeyLength)
+		if err != nil {
+			return repository.Link{}, err
+		}
+	} else if shortKey == "" {
+		// Owned links are keyed per owner so that two accounts shortening the
+		// same URL do not end up sharing (and fighting over) one key.
+		input := req.URL
+		if req.Owner != "" {
+			input = req.Owner + " " + req.URL
+		}
+		if req.MaxClicks > 0 || req.Password != "" {
+			// Every click-limited link needs its own budget and every
+			// password its own link, so these are never shared with an
+			// earlier link to the same URL.
+			nonce, err := randomToken()
+			if err != nil {
+				return repository.Link{}, err
+			}
+			input += " " + nonce
+		}
+		if req.Signed {
+			// Otherwise signing a URL would also sign an existing unsigned
+			// link to it.
+			input += " signed"
+		}
+		// Links with other options get other keys than a plain link to the
+		// same URL, which keeps its key.
+		if options := linkOptions(req, sch, rules, variants); options != "" {
+			input += " " + options
+		}
+		shortKey = s.generateKey(input)
+	} else if !aliasPattern.MatchString(shortKey) || reservedAliases[shortKey] {
+		return repository.Link{}, ErrInvalidAlias
+	}
+
+	return repository.Link{
+		Key:             shortKey,
+		URL:             req.URL,
+		Owner:           req.Owner,
+		CreatedAt:       time.Now().UTC(),
+		Interstitial:    req.Interstitial,
+		PasswordHash:    passwordHash,
+		MaxClicks:       req.MaxClicks,
+		RemainingClicks: req.MaxClicks,
+		NotBefore:       sch.notBefore,
+		NotAfter:        sch.notAfter,
+		FallbackURL:     sch.fallbackURL,
+		Rules:           rules,
+		Variants:        variants,
+		UTM:             req.UTM,
+		ForwardQuery:    req.ForwardQuery,
+		ForwardPath:     req.ForwardPath,
+		Signed:          req.Signed,
+	}, nil
+}
+
+// checkDestination validates raw and applies the destination policy and the
+// allowed destinations of the context's domain.
+// linkOptions encodes the options of req that change how its link behaves
+// and are not covered by the key input otherwise. It is empty for a plain
+// link.
+func linkOptions(req ShortenRequest, sch schedule, rules []repository.Rule, variants []repository.Variant) string {
+	options := struct {
+		TTL          time.Duration              `json:"ttl,omitempty"`
+		Interstitial time.Duration              `json:"interstitial,omitempty"`
+		NotBefore    int64                      `json:"not_before,omitempty"`
+		NotAfter     int64                      `json:"not_after,omitempty"`
+		FallbackURL  string                     `json:"fallback_url,omitempty"`
+		Rules        []repository.Rule          `json:"rules,omitempty"`
+		Variants     []repository.Variant       `json:"variants,omitempty"`
+		UTM          *repository.UTM            `json:"utm,omitempty"`
+		ForwardQuery repository.QueryForwarding `json:"forward_query,omitempty"`
+		ForwardPath  bool                       `json:"forward_path,omitempty"`
+	}{
+		TTL:          req.TTL,
+		Interstitial: req.Interstitial,
+		FallbackURL:  sch.fallbackURL,
+		Rules:        rules,
+		Variants:     variants,
+		ForwardQuery: req.ForwardQuery,
+		ForwardPath:  req.ForwardPath,
+	}
+	if !sch.notBefore.IsZero() {
+		options.NotBefore = sch.notBefore.Unix()
+	}
+	if !sch.notAfter.IsZero() {
+		options.NotAfter = sch.notAfter.Unix()
+	}
+	if req.UTM != (repository.UTM{}) {
+		options.UTM = &req.UTM
+	}
+	// The options are plain data, so marshalling them cannot fail.
+	encoded, _ := json.Marshal(options)
+	if string(encoded) == "{}" {
+		return ""
+	}
+	return string(encoded)
+}
+
+func (s *service) checkDestination(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+	if err := checkDomainDestination(ctx, u); err != nil {
+		return err
+	}
+	if s.policy != nil {
+		return s.policy.Check(ctx, u)
+	}
+	return nil
+}
+
+func saveError(err error) error {
+	if errors.Is(err, repository.ErrConflict) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *service) GetOriginalURL(ctx context.Context, shortKey string, visit Visit) (repository.Link, error) {
+	link, err := s.resolve(ctx, shortKey, visit)
+	if err != nil {
+		return repository.Link{}, err
+	}
+
+	if link.MaxClicks > 0 {
+		// Unlike click counting, the limit is enforced even if Redis fails.
+		remaining, err := s.repo.ConsumeClick(ctx, shortKey)
+		if errors.Is(err, repository.ErrExhausted) {
+			return repository.Link{}, ErrExhausted
+		}
+		if err != nil {
+			return repository.Link{}, err
+		}
+		link.RemainingClicks = remaining
+	}
+
+	// Rules target visitors explicitly, so they take precedence over the
+	// split.
+	link, matched := s.applyRules(link, visit, time.Now())
+	if !matched {
+		link = s.chooseVariant(link, visit)
+	}
+	link.URL = forwardDestination(link, visit)
+
+	// Click counting must never break a redirect.
+	if clicks, err := s.repo.IncrClicks(ctx, shortKey, referrerDomain(visit.Referrer)); err != nil {
+		slog.WarnContext(ctx, "failed to count click", "key", shortKey, "error", err)
+	} else {
+		s.emitMilestone(ctx, shortKey, link.Owner, clicks)
+	}
+	if link.Variant > 0 {
+		if err := s.repo.IncrVariantClicks(ctx, shortKey, link.Variant-1); err != nil {
+			slog.WarnContext(ctx, "failed to count variant click", "key", shortKey, "variant", link.Variant, "error", err)
+		}
+	}
+	return link, nil
+}
+
+func (s *service) PreviewLink(ctx context.Context, shortKey string, visit Visit) (repository.Link, error) {
+	link, err := s.resolve(ctx, shortKey, visit)
+	if err != nil {
+		return repository.Link{}, err
+	}
+	link, _ = s.applyRules(link, visit, time.Now())
+	return link, nil
+}
+
+// resolve loads a link and checks that the visit may follow it.
+func (s *service) resolve(ctx context.Context, shortKey string, visit Visit) (repository.Link, error) {
+	link, err := s.repo.GetLink(ctx, shortKey)
+	if errors.Is(err, repository.ErrNotFound) {
+		return repository.Link{}, ErrNotFound
+	}
+	if err != nil {
+		return repository.Link{}, err
+	}
+	if visit.Path != "" && !link.ForwardPath {
+		return repository.Link{}, ErrNotFound
+	}
+	// Signed links must look missing to anyone who guessed the key.
+	if link.Signed && !visit.Signed {
+		return repository.Link{}, ErrNotFound
+	}
+	if link.DisabledReason != "" {
+		return repository.Link{}, ErrDisabled
+	}
+	if err := checkSchedule(link, time.Now()); err != nil {
+		return repository.Link{}, err
+	}
+	if link.MaxClicks > 0 && link.RemainingClicks <= 0 {
+		return repository.Link{}, ErrExhausted
+	}
+	if err := s.checkPassword(ctx, link, visit.Password); err != nil {
+		return repository.Link{}, err
+	}
+	return link, nil
+}
+
+func (s *service) UpdateURL(ctx context.Context, id Identity, shortKey string, url string) error {
+	if err := s.checkDestination(ctx, url); err != nil {
+		return err
+	}
+	link, err := s.ownedLink(ctx, id, shortKey)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Update(ctx, shortKey, url); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	s.emit(ctx, Event{Type: EventLinkUpdated, Key: shortKey, Owner: link.Owner, URL: url})
+	return nil
+}
+
+func (s *service) DeleteURL(ctx context.Context, id Identity, shortKey string) error {
+	link, err := s.ownedLink(ctx, id, shortKey)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, shortKey); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "link deleted", "key", shortKey, "by", id.Owner)
+	s.emit(ctx, Event{Type: EventLinkDeleted, Key: shortKey, Owner: link.Owner})
+	return nil
+}
+
+// ownedLink loads a link and checks that the caller may manage it. Anonymous
+// links have no owner and can only be managed by admins.
+func (s *service) ownedLink(ctx context.Context, id Identity, shortKey string) (repository.Link, error) {
+	link, err := s.repo.GetLink(ctx, shortKey)
+	if errors.Is(err, repository.ErrNotFound) {
+		return repository.Link{}, ErrNotFound
+	}
+	if err != nil {
+		return repository.Link{}, err
+	}
+
+	if !id.Admin && (link.Owner == "" || link.Owner != id.Owner) {
+		return repository.Link{}, ErrForbidden
+	}
+	return link, nil
+}
+
+func (s *service) ListLinks(ctx context.Context, req ListRequest) (LinkPage, error) {
+	offset, err := decodeCursor(req.Cursor)
+	if err != nil {
+		return LinkPage{}, err
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	links, next, err := s.repo.ListByOwner(ctx, req.Owner, repository.ListOptions{
+		Sort:      req.Sort,
+		Ascending: req.Ascending,
+		Offset:    offset,
+		Limit:     int64(limit),
+	})
+	if err != nil {
+		return LinkPage{}, err
+	}
+
+	page := LinkPage{Links: links}
+	if next > 0 {
+		page.NextCursor = encodeCursor(next)
+	}
+	return page, nil
+}
+
+func (s *service) Authenticate(ctx context.Context, apiKey string) (Identity, error) {
+	key, err := s.repo.GetAPIKey(ctx, apiKey)
+	if errors.Is(err, repository.ErrNotFound) {
+		return Identity{}, ErrUnauthorized
+	}
+	if err != nil {
+		return Identity{}, err
+	}
+	return Identity{Owner: key.Owner, Admin: key.Admin}, nil
+}
+
+func (s *service) InspectLink(ctx context.Context, id Identity, shortKey string) (repository.Link, error) {
+	return s.ownedLink(ctx, id, shortKey)
 }
 
-func NewShortenerService(repo repository.Repository) ShortenerService {
-	return &service{repo: repo}
+func (s *service) CreateAPIKey(ctx context.Context, owner string, admin bool) (string, repository.APIKey, error) {
+	if owner == "" || strings.Contains(owner, ":") {
+		return "", repository.APIKey{}, ErrInvalidOwner
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return "", repository.APIKey{}, err
+	}
+
+	key, err := s.repo.CreateAPIKey(ctx, token, repository.APIKey{
+		Owner:     owner,
+		Admin:     admin,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return "", repository.APIKey{}, err
+	}
+	return token, key, nil
+}
+
+func (s *service) RevokeAPIKey(ctx context.Context, id string) error {
+	err := s.repo.DeleteAPIKey(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *service) ListAPIKeys(ctx context.Context) ([]repository.APIKey, error) {
+	return s.repo.ListAPIKeys(ctx)
+}
+
+func (s *service) PurgeExpired(ctx context.Context) (int, error) {
+	purged, err := s.repo.PurgeOrphans(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, link := range purged {
+		s.emit(ctx, Event{Type: EventLinkExpired, Key: link.Key, Owner: link.Owner})
+	}
+	return len(purged), nil
+}
+
+func (s *service) ExportLinks(ctx context.Context, fn func(repository.Link) error) error {
+	return s.repo.ScanLinks(ctx, fn)
+}
+
+func (s *service) ImportLinks(ctx context.Context, links []repository.Link) []error {
+	errs := make([]error, len(links))
+	now := time.Now().UTC()
+
+	saves := make([]repository.SaveRequest, 0, len(links))
+	indexes := make([]int, 0, len(links))
+	for i, link := range links {
+		link, ttl, err := s.importedLink(ctx, link, now)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		saves = append(saves, repository.SaveRequest{Link: link, TTL: ttl})
+		indexes = append(indexes, i)
+	}
+
+	if len(saves) == 0 {
+		return errs
+	}
+
+	for j, err := range s.repo.SaveBatch(ctx, saves) {
+		if err != nil {
+			errs[indexes[j]] = saveError(err)
+		}
+	}
+	return errs
 }
 
-func (s *service) ShortenURL(ctx context.Context, originalURL string) string {
-	shortKey := s.generateKey(originalURL)
+// importedLink checks a link read from an export the way newLink checks a
+// new one, and returns it with the TTL it is stored with.
+func (s *service) importedLink(ctx context.Context, link repository.Link, now time.Time) (repository.Link, time.Duration, error) {
+	if err := s.checkDestination(ctx, link.URL); err != nil {
+		return repository.Link{}, 0, err
+	}
+	if !importKeyPattern.MatchString(link.Key) || reservedAliases[link.Key] {
+		return repository.Link{}, 0, ErrInvalidAlias
+	}
+
+	var ttl time.Duration
+	if !link.ExpiresAt.IsZero() {
+		ttl = link.ExpiresAt.Sub(now)
+		if ttl <= 0 {
+			return repository.Link{}, 0, ErrInvalidTTL
+		}
+	}
+	if link.CreatedAt.IsZero() {
+		link.CreatedAt = now
+	}
+
+	if link.Interstitial < 0 || link.Interstitial > maxInterstitial {
+		return repository.Link{}, 0, ErrInvalidInterstitial
+	}
+	if link.MaxClicks < 0 || link.RemainingClicks < 0 || link.RemainingClicks > link.MaxClicks {
+		return repository.Link{}, 0, ErrInvalidMaxClicks
+	}
+	if link.FallbackURL != "" {
+		if err := s.checkDestination(ctx, link.FallbackURL); err != nil {
+			return repository.Link{}, 0, err
+		}
+	}
+	rules, err := s.newRules(ctx, link.Rules)
+	if err != nil {
+		return repository.Link{}, 0, err
+	}
+	link.Rules = rules
+	variants, err := s.newVariants(ctx, link.Variants)
+	if err != nil {
+		return repository.Link{}, 0, err
+	}
+	link.Variants = variants
+	if !queryForwardings[link.ForwardQuery] {
+		return repository.Link{}, 0, ErrInvalidForwarding
+	}
+	return link, ttl, nil
+}
+
+func encodeCursor(offset int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(offset, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	offset, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return offset, nil
+}
 
-	s.repo.Save(ctx, shortKey, originalURL, 0)
-	return shortKey
+// randomToken returns 24 random bytes, URL-safe base64 encoded.
+func randomToken() (string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
 }
 
-func (s *service) GetOriginalURL(ctx context.Context, shortKey string) (string, error) {
-	return s.repo.Get(ctx, shortKey)
+// randomKey returns n uniformly random base62 characters.
+func randomKey(n int) (string, error) {
+	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	// Bytes from 248 up would favour the first characters of the charset.
+	const limit = 256 - 256%len(charset)
+
+	key := make([]byte, 0, n)
+	buf := make([]byte, n)
+	for len(key) < n {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) < limit && len(key) < n {
+				key = append(key, charset[int(b)%len(charset)])
+			}
+		}
+	}
+	return string(key), nil
 }
 
 func (s *service) generateKey(input string) string {
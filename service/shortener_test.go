@@ -3,23 +3,59 @@ package service
 import (
 	"context"
 	"errors"
+	"net/url"
 	"testing"
 	"time"
+	"url-shortener/repository"
 )
 
 // MockRepository - мок репозитория для тестирования
 type MockRepository struct {
-	SaveFunc func(ctx context.Context, key string, url string, ttl time.Duration) error
-	GetFunc  func(ctx context.Context, key string) (string, error)
+	SaveFunc                  func(ctx context.Context, link repository.Link, ttl time.Duration) error
+	SaveBatchFunc             func(ctx context.Context, reqs []repository.SaveRequest) []error
+	GetFunc                   func(ctx context.Context, key string) (string, error)
+	GetLinkFunc               func(ctx context.Context, key string) (repository.Link, error)
+	UpdateFunc                func(ctx context.Context, key string, url string) error
+	DeleteFunc                func(ctx context.Context, key string) error
+	IncrClicksFunc            func(ctx context.Context, key, referrer string) (int64, error)
+	IncrVariantClicksFunc     func(ctx context.Context, key string, variant int) error
+	ListByOwnerFunc           func(ctx context.Context, owner string, opts repository.ListOptions) ([]repository.Link, int64, error)
+	GetAPIKeyFunc             func(ctx context.Context, token string) (repository.APIKey, error)
+	ScanLinksFunc             func(ctx context.Context, fn func(repository.Link) error) error
+	CreateAPIKeyFunc          func(ctx context.Context, token string, key repository.APIKey) (repository.APIKey, error)
+	DeleteAPIKeyFunc          func(ctx context.Context, id string) error
+	ListAPIKeysFunc           func(ctx context.Context) ([]repository.APIKey, error)
+	PurgeOrphansFunc          func(ctx context.Context) ([]repository.Link, error)
+	ConsumeClickFunc          func(ctx context.Context, key string) (int64, error)
+	ClickSeriesFunc           func(ctx context.Context, key string, g repository.Granularity, from, to time.Time) (map[time.Time]int64, error)
+	TopLinksFunc              func(ctx context.Context, from, to time.Time, n int) ([]repository.Ranked, error)
+	TopReferrersFunc          func(ctx context.Context, from, to time.Time, n int) ([]repository.Ranked, error)
+	LinksCreatedFunc          func(ctx context.Context, from, to time.Time) (map[time.Time]int64, error)
+	SaveDomainFunc            func(ctx context.Context, d repository.Domain) error
+	GetDomainFunc             func(ctx context.Context, name string) (repository.Domain, error)
+	DeleteDomainFunc          func(ctx context.Context, name string) error
+	ListDomainsFunc           func(ctx context.Context) ([]repository.Domain, error)
+	AddPasswordAttemptFunc    func(ctx context.Context, key string, window time.Duration) (int64, error)
+	ResetPasswordFailuresFunc func(ctx context.Context, key string) error
+	DisableFunc               func(ctx context.Context, key string, reason string, at time.Time) error
+	EnableFunc                func(ctx context.Context, key string) error
+	ListDisabledFunc          func(ctx context.Context) ([]repository.Link, error)
 }
 
-func (m *MockRepository) Save(ctx context.Context, key string, url string, ttl time.Duration) error {
+func (m *MockRepository) Save(ctx context.Context, link repository.Link, ttl time.Duration) error {
 	if m.SaveFunc != nil {
-		return m.SaveFunc(ctx, key, url, ttl)
+		return m.SaveFunc(ctx, link, ttl)
 	}
 	return nil
 }
 
+func (m *MockRepository) SaveBatch(ctx context.Context, reqs []repository.SaveRequest) []error {
+	if m.SaveBatchFunc != nil {
+		return m.SaveBatchFunc(ctx, reqs)
+	}
+	return make([]error, len(reqs))
+}
+
 func (m *MockRepository) Get(ctx context.Context, key string) (string, error) {
 	if m.GetFunc != nil {
 		return m.GetFunc(ctx, key)
@@ -27,6 +63,188 @@ func (m *MockRepository) Get(ctx context.Context, key string) (string, error) {
 	return "", errors.New("not found")
 }
 
+func (m *MockRepository) GetLink(ctx context.Context, key string) (repository.Link, error) {
+	if m.GetLinkFunc != nil {
+		return m.GetLinkFunc(ctx, key)
+	}
+	return repository.Link{}, repository.ErrNotFound
+}
+
+func (m *MockRepository) Update(ctx context.Context, key string, url string) error {
+	if m.UpdateFunc != nil {
+		return m.UpdateFunc(ctx, key, url)
+	}
+	return nil
+}
+
+func (m *MockRepository) Delete(ctx context.Context, key string) error {
+	if m.DeleteFunc != nil {
+		return m.DeleteFunc(ctx, key)
+	}
+	return nil
+}
+
+func (m *MockRepository) IncrVariantClicks(ctx context.Context, key string, variant int) error {
+	if m.IncrVariantClicksFunc != nil {
+		return m.IncrVariantClicksFunc(ctx, key, variant)
+	}
+	return nil
+}
+
+func (m *MockRepository) IncrClicks(ctx context.Context, key, referrer string) (int64, error) {
+	if m.IncrClicksFunc != nil {
+		return m.IncrClicksFunc(ctx, key, referrer)
+	}
+	return 0, nil
+}
+
+func (m *MockRepository) ListByOwner(ctx context.Context, owner string, opts repository.ListOptions) ([]repository.Link, int64, error) {
+	if m.ListByOwnerFunc != nil {
+		return m.ListByOwnerFunc(ctx, owner, opts)
+	}
+	return nil, 0, nil
+}
+
+func (m *MockRepository) GetAPIKey(ctx context.Context, token string) (repository.APIKey, error) {
+	if m.GetAPIKeyFunc != nil {
+		return m.GetAPIKeyFunc(ctx, token)
+	}
+	return repository.APIKey{}, repository.ErrNotFound
+}
+
+func (m *MockRepository) ScanLinks(ctx context.Context, fn func(repository.Link) error) error {
+	if m.ScanLinksFunc != nil {
+		return m.ScanLinksFunc(ctx, fn)
+	}
+	return nil
+}
+
+func (m *MockRepository) CreateAPIKey(ctx context.Context, token string, key repository.APIKey) (repository.APIKey, error) {
+	if m.CreateAPIKeyFunc != nil {
+		return m.CreateAPIKeyFunc(ctx, token, key)
+	}
+	return key, nil
+}
+
+func (m *MockRepository) DeleteAPIKey(ctx context.Context, id string) error {
+	if m.DeleteAPIKeyFunc != nil {
+		return m.DeleteAPIKeyFunc(ctx, id)
+	}
+	return nil
+}
+
+func (m *MockRepository) ListAPIKeys(ctx context.Context) ([]repository.APIKey, error) {
+	if m.ListAPIKeysFunc != nil {
+		return m.ListAPIKeysFunc(ctx)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) PurgeOrphans(ctx context.Context) ([]repository.Link, error) {
+	if m.PurgeOrphansFunc != nil {
+		return m.PurgeOrphansFunc(ctx)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) ConsumeClick(ctx context.Context, key string) (int64, error) {
+	if m.ConsumeClickFunc != nil {
+		return m.ConsumeClickFunc(ctx, key)
+	}
+	return -1, nil
+}
+
+func (m *MockRepository) ClickSeries(ctx context.Context, key string, g repository.Granularity, from, to time.Time) (map[time.Time]int64, error) {
+	if m.ClickSeriesFunc != nil {
+		return m.ClickSeriesFunc(ctx, key, g, from, to)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) TopLinks(ctx context.Context, from, to time.Time, n int) ([]repository.Ranked, error) {
+	if m.TopLinksFunc != nil {
+		return m.TopLinksFunc(ctx, from, to, n)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) TopReferrers(ctx context.Context, from, to time.Time, n int) ([]repository.Ranked, error) {
+	if m.TopReferrersFunc != nil {
+		return m.TopReferrersFunc(ctx, from, to, n)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) SaveDomain(ctx context.Context, d repository.Domain) error {
+	if m.SaveDomainFunc != nil {
+		return m.SaveDomainFunc(ctx, d)
+	}
+	return nil
+}
+
+func (m *MockRepository) GetDomain(ctx context.Context, name string) (repository.Domain, error) {
+	if m.GetDomainFunc != nil {
+		return m.GetDomainFunc(ctx, name)
+	}
+	return repository.Domain{}, repository.ErrNotFound
+}
+
+func (m *MockRepository) DeleteDomain(ctx context.Context, name string) error {
+	if m.DeleteDomainFunc != nil {
+		return m.DeleteDomainFunc(ctx, name)
+	}
+	return nil
+}
+
+func (m *MockRepository) ListDomains(ctx context.Context) ([]repository.Domain, error) {
+	if m.ListDomainsFunc != nil {
+		return m.ListDomainsFunc(ctx)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) LinksCreated(ctx context.Context, from, to time.Time) (map[time.Time]int64, error) {
+	if m.LinksCreatedFunc != nil {
+		return m.LinksCreatedFunc(ctx, from, to)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) AddPasswordAttempt(ctx context.Context, key string, window time.Duration) (int64, error) {
+	if m.AddPasswordAttemptFunc != nil {
+		return m.AddPasswordAttemptFunc(ctx, key, window)
+	}
+	return 1, nil
+}
+
+func (m *MockRepository) ResetPasswordFailures(ctx context.Context, key string) error {
+	if m.ResetPasswordFailuresFunc != nil {
+		return m.ResetPasswordFailuresFunc(ctx, key)
+	}
+	return nil
+}
+
+func (m *MockRepository) Disable(ctx context.Context, key string, reason string, at time.Time) error {
+	if m.DisableFunc != nil {
+		return m.DisableFunc(ctx, key, reason, at)
+	}
+	return nil
+}
+
+func (m *MockRepository) Enable(ctx context.Context, key string) error {
+	if m.EnableFunc != nil {
+		return m.EnableFunc(ctx, key)
+	}
+	return nil
+}
+
+func (m *MockRepository) ListDisabled(ctx context.Context) ([]repository.Link, error) {
+	if m.ListDisabledFunc != nil {
+		return m.ListDisabledFunc(ctx)
+	}
+	return nil, nil
+}
+
 func TestShortenURL(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -52,12 +270,18 @@ func TestShortenURL(t *testing.T) {
 			saveError:   nil,
 			wantError:   false,
 		},
+		{
+			name:        "repository error",
+			originalURL: "https://example.com",
+			saveError:   errors.New("connection error"),
+			wantError:   true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo := &MockRepository{
-				SaveFunc: func(ctx context.Context, key string, url string, ttl time.Duration) error {
+				SaveFunc: func(ctx context.Context, link repository.Link, ttl time.Duration) error {
 					return tt.saveError
 				},
 			}
@@ -65,7 +289,18 @@ func TestShortenURL(t *testing.T) {
 			service := NewShortenerService(mockRepo)
 			ctx := context.Background()
 
-			shortKey := service.ShortenURL(ctx, tt.originalURL)
+			shortKey, err := service.ShortenURL(ctx, ShortenRequest{URL: tt.originalURL})
+
+			if tt.wantError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
 
 			if shortKey == "" {
 				t.Error("expected non-empty short key")
@@ -95,8 +330,8 @@ func TestShortenURL_ConsistentHashing(t *testing.T) {
 	ctx := context.Background()
 
 	url := "https://example.com"
-	key1 := service.ShortenURL(ctx, url)
-	key2 := service.ShortenURL(ctx, url)
+	key1, _ := service.ShortenURL(ctx, ShortenRequest{URL: url})
+	key2, _ := service.ShortenURL(ctx, ShortenRequest{URL: url})
 
 	if key1 != key2 {
 		t.Errorf("expected same key for same URL, got %s and %s", key1, key2)
@@ -111,8 +346,8 @@ func TestShortenURL_DifferentURLs(t *testing.T) {
 	url1 := "https://example.com"
 	url2 := "https://example.org"
 
-	key1 := service.ShortenURL(ctx, url1)
-	key2 := service.ShortenURL(ctx, url2)
+	key1, _ := service.ShortenURL(ctx, ShortenRequest{URL: url1})
+	key2, _ := service.ShortenURL(ctx, ShortenRequest{URL: url2})
 
 	if key1 == key2 {
 		t.Error("expected different keys for different URLs")
@@ -124,6 +359,7 @@ func TestGetOriginalURL(t *testing.T) {
 		name        string
 		shortKey    string
 		mockReturn  string
+		disabled    string
 		mockError   error
 		expectedURL string
 		expectedErr bool
@@ -152,20 +388,28 @@ func TestGetOriginalURL(t *testing.T) {
 			expectedURL: "",
 			expectedErr: true,
 		},
+		{
+			name:        "disabled link",
+			shortKey:    "flagged",
+			mockReturn:  "https://evil.example",
+			disabled:    "blocklist:evil.example",
+			expectedURL: "",
+			expectedErr: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo := &MockRepository{
-				GetFunc: func(ctx context.Context, key string) (string, error) {
-					return tt.mockReturn, tt.mockError
+				GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+					return repository.Link{Key: key, URL: tt.mockReturn, DisabledReason: tt.disabled}, tt.mockError
 				},
 			}
 
 			service := NewShortenerService(mockRepo)
 			ctx := context.Background()
 
-			url, err := service.GetOriginalURL(ctx, tt.shortKey)
+			link, err := service.GetOriginalURL(ctx, tt.shortKey, Visit{})
 
 			if tt.expectedErr && err == nil {
 				t.Error("expected error but got none")
@@ -175,13 +419,562 @@ func TestGetOriginalURL(t *testing.T) {
 				t.Errorf("unexpected error: %v", err)
 			}
 
-			if url != tt.expectedURL {
-				t.Errorf("expected URL %s, got %s", tt.expectedURL, url)
+			if link.URL != tt.expectedURL {
+				t.Errorf("expected URL %s, got %s", tt.expectedURL, link.URL)
 			}
 		})
 	}
 }
 
+func TestPreviewLink_DoesNotCountClicks(t *testing.T) {
+	clicks := 0
+	mockRepo := &MockRepository{
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			return repository.Link{Key: key, URL: "https://example.com", Clicks: 7}, nil
+		},
+		IncrClicksFunc: func(ctx context.Context, key, referrer string) (int64, error) {
+			clicks++
+			return int64(clicks), nil
+		},
+	}
+	service := NewShortenerService(mockRepo)
+	ctx := context.Background()
+
+	link, err := service.PreviewLink(ctx, "abc123", Visit{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if link.URL != "https://example.com" || link.Clicks != 7 {
+		t.Errorf("unexpected link: %+v", link)
+	}
+	if clicks != 0 {
+		t.Errorf("expected preview not to count a click, got %d", clicks)
+	}
+
+	if _, err := service.GetOriginalURL(ctx, "abc123", Visit{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if clicks != 1 {
+		t.Errorf("expected a visit to count a click, got %d", clicks)
+	}
+}
+
+func TestGetOriginalURL_ClickLimited(t *testing.T) {
+	remaining := int64(2)
+	clicks := 0
+	mockRepo := &MockRepository{
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			return repository.Link{Key: key, URL: "https://example.com", MaxClicks: 2, RemainingClicks: remaining}, nil
+		},
+		ConsumeClickFunc: func(ctx context.Context, key string) (int64, error) {
+			if remaining == 0 {
+				return 0, repository.ErrExhausted
+			}
+			remaining--
+			return remaining, nil
+		},
+		IncrClicksFunc: func(ctx context.Context, key, referrer string) (int64, error) {
+			clicks++
+			return int64(clicks), nil
+		},
+	}
+	service := NewShortenerService(mockRepo)
+	ctx := context.Background()
+
+	for want := int64(1); want >= 0; want-- {
+		link, err := service.GetOriginalURL(ctx, "once", Visit{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if link.RemainingClicks != want {
+			t.Errorf("expected %d uses left, got %d", want, link.RemainingClicks)
+		}
+	}
+
+	if _, err := service.GetOriginalURL(ctx, "once", Visit{}); !errors.Is(err, ErrExhausted) {
+		t.Errorf("expected ErrExhausted, got %v", err)
+	}
+	if _, err := service.PreviewLink(ctx, "once", Visit{}); !errors.Is(err, ErrExhausted) {
+		t.Errorf("expected preview of a used up link to fail, got %v", err)
+	}
+	if clicks != 2 {
+		t.Errorf("expected 2 counted clicks, got %d", clicks)
+	}
+}
+
+func TestGetOriginalURL_ClickLimitFailsClosed(t *testing.T) {
+	mockRepo := &MockRepository{
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			return repository.Link{Key: key, URL: "https://example.com", MaxClicks: 1, RemainingClicks: 1}, nil
+		},
+		ConsumeClickFunc: func(ctx context.Context, key string) (int64, error) {
+			return 0, errors.New("connection error")
+		},
+	}
+	service := NewShortenerService(mockRepo)
+
+	if _, err := service.GetOriginalURL(context.Background(), "once", Visit{}); err == nil {
+		t.Error("expected the visit to fail when the limit cannot be enforced")
+	}
+}
+
+func TestShortenURL_ClickLimitedKeysAreUnique(t *testing.T) {
+	service := NewShortenerService(&MockRepository{})
+	ctx := context.Background()
+	req := ShortenRequest{URL: "https://example.com/invite", MaxClicks: 1}
+
+	first, err := service.ShortenURL(ctx, req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, _ := service.ShortenURL(ctx, req)
+	if first == second {
+		t.Error("expected every click-limited link to get its own key")
+	}
+
+	if _, err := service.ShortenURL(ctx, ShortenRequest{URL: "https://example.com", MaxClicks: -1}); !errors.Is(err, ErrInvalidMaxClicks) {
+		t.Errorf("expected ErrInvalidMaxClicks, got %v", err)
+	}
+}
+
+func TestShortenURL_OwnerScopedKeys(t *testing.T) {
+	var saved repository.Link
+	mockRepo := &MockRepository{
+		SaveFunc: func(ctx context.Context, link repository.Link, ttl time.Duration) error {
+			saved = link
+			return nil
+		},
+	}
+	service := NewShortenerService(mockRepo)
+	ctx := context.Background()
+
+	url := "https://example.com"
+	anonymous, _ := service.ShortenURL(ctx, ShortenRequest{URL: url})
+	alice, _ := service.ShortenURL(ctx, ShortenRequest{URL: url, Owner: "alice"})
+	bob, _ := service.ShortenURL(ctx, ShortenRequest{URL: url, Owner: "bob"})
+
+	if anonymous == alice || alice == bob {
+		t.Errorf("expected distinct keys per owner, got %s, %s and %s", anonymous, alice, bob)
+	}
+	if saved.Owner != "bob" || saved.Key != bob {
+		t.Errorf("expected link saved for bob under %s, got %+v", bob, saved)
+	}
+	if saved.CreatedAt.IsZero() {
+		t.Error("expected creation time to be set")
+	}
+}
+
+func TestShortenURL_Validation(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         ShortenRequest
+		saveError   error
+		expectedKey string
+		expectedErr error
+	}{
+		{
+			name:        "alias",
+			req:         ShortenRequest{URL: "https://example.com", Alias: "launch-2024"},
+			expectedKey: "launch-2024",
+		},
+		{
+			name:        "alias taken",
+			req:         ShortenRequest{URL: "https://example.com", Alias: "launch-2024"},
+			saveError:   repository.ErrConflict,
+			expectedErr: ErrConflict,
+		},
+		{
+			name:        "alias too short",
+			req:         ShortenRequest{URL: "https://example.com", Alias: "ab"},
+			expectedErr: ErrInvalidAlias,
+		},
+		{
+			name:        "reserved alias",
+			req:         ShortenRequest{URL: "https://example.com", Alias: "links"},
+			expectedErr: ErrInvalidAlias,
+		},
+		{
+			name:        "relative URL",
+			req:         ShortenRequest{URL: "/relative/path"},
+			expectedErr: ErrInvalidURL,
+		},
+		{
+			name:        "unsupported scheme",
+			req:         ShortenRequest{URL: "javascript:alert(1)"},
+			expectedErr: ErrInvalidURL,
+		},
+		{
+			name:        "negative TTL",
+			req:         ShortenRequest{URL: "https://example.com", TTL: -time.Second},
+			expectedErr: ErrInvalidTTL,
+		},
+		{
+			name:        "interstitial",
+			req:         ShortenRequest{URL: "https://example.com", Alias: "slow-link", Interstitial: 5 * time.Second},
+			expectedKey: "slow-link",
+		},
+		{
+			name:        "interstitial too long",
+			req:         ShortenRequest{URL: "https://example.com", Interstitial: time.Hour},
+			expectedErr: ErrInvalidInterstitial,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockRepository{
+				SaveFunc: func(ctx context.Context, link repository.Link, ttl time.Duration) error {
+					return tt.saveError
+				},
+			}
+			service := NewShortenerService(mockRepo)
+
+			key, err := service.ShortenURL(context.Background(), tt.req)
+
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("expected error %v, got %v", tt.expectedErr, err)
+			}
+			if tt.expectedKey != "" && key != tt.expectedKey {
+				t.Errorf("expected key %s, got %s", tt.expectedKey, key)
+			}
+		})
+	}
+}
+
+type stubPolicy struct {
+	blocked map[string]string
+	checked []string
+}
+
+func (p *stubPolicy) Check(ctx context.Context, u *url.URL) error {
+	p.checked = append(p.checked, u.Host)
+	if rule, ok := p.blocked[u.Host]; ok {
+		return &PolicyError{Rule: rule}
+	}
+	return nil
+}
+
+func TestShortenURL_Policy(t *testing.T) {
+	saved := false
+	mockRepo := &MockRepository{
+		SaveFunc: func(ctx context.Context, link repository.Link, ttl time.Duration) error {
+			saved = true
+			return nil
+		},
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			return repository.Link{Key: key, Owner: "alice"}, nil
+		},
+	}
+	policy := &stubPolicy{blocked: map[string]string{"evil.example": "blocklist:evil.example"}}
+	service := NewShortenerService(mockRepo, WithPolicy(policy))
+	ctx := context.Background()
+
+	_, err := service.ShortenURL(ctx, ShortenRequest{URL: "https://evil.example/login"})
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) || policyErr.Rule != "blocklist:evil.example" {
+		t.Fatalf("expected policy error, got %v", err)
+	}
+	if saved {
+		t.Error("expected blocked link not to be saved")
+	}
+
+	// Malformed URLs are rejected before any policy sees them.
+	if _, err := service.ShortenURL(ctx, ShortenRequest{URL: "ftp://evil.example"}); !errors.Is(err, ErrInvalidURL) {
+		t.Errorf("expected ErrInvalidURL, got %v", err)
+	}
+	if len(policy.checked) != 1 {
+		t.Errorf("expected one policy check, got %v", policy.checked)
+	}
+
+	if _, err := service.ShortenURL(ctx, ShortenRequest{URL: "https://example.com"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = service.UpdateURL(ctx, Identity{Owner: "alice"}, "abc123", "https://evil.example/")
+	if !errors.As(err, &policyErr) {
+		t.Errorf("expected update to be checked against the policy, got %v", err)
+	}
+}
+
+func TestShortenBatch(t *testing.T) {
+	var saved []repository.SaveRequest
+	mockRepo := &MockRepository{
+		SaveBatchFunc: func(ctx context.Context, reqs []repository.SaveRequest) []error {
+			saved = reqs
+			errs := make([]error, len(reqs))
+			for i, req := range reqs {
+				if req.Link.Key == "taken" {
+					errs[i] = repository.ErrConflict
+				}
+			}
+			return errs
+		},
+	}
+	service := NewShortenerService(mockRepo)
+
+	results := service.ShortenBatch(context.Background(), []ShortenRequest{
+		{URL: "https://example.com", TTL: time.Hour},
+		{URL: "not a url"},
+		{URL: "https://example.org", Alias: "taken"},
+		{URL: "https://example.net", Alias: "promo"},
+	})
+
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(results))
+	}
+	if results[0].Err != nil || results[0].Key == "" {
+		t.Errorf("unexpected first result: %+v", results[0])
+	}
+	if !errors.Is(results[1].Err, ErrInvalidURL) {
+		t.Errorf("expected ErrInvalidURL, got %v", results[1].Err)
+	}
+	if !errors.Is(results[2].Err, ErrConflict) || results[2].Key != "" {
+		t.Errorf("expected conflict without key, got %+v", results[2])
+	}
+	if results[3].Err != nil || results[3].Key != "promo" {
+		t.Errorf("unexpected last result: %+v", results[3])
+	}
+
+	if len(saved) != 3 {
+		t.Fatalf("expected invalid entries to be skipped, saved %d", len(saved))
+	}
+	if saved[0].TTL != time.Hour {
+		t.Errorf("expected TTL to be passed through, got %s", saved[0].TTL)
+	}
+}
+
+func TestImportLinks(t *testing.T) {
+	var saved []repository.SaveRequest
+	mockRepo := &MockRepository{
+		SaveBatchFunc: func(ctx context.Context, reqs []repository.SaveRequest) []error {
+			saved = reqs
+			errs := make([]error, len(reqs))
+			for i, req := range reqs {
+				if req.Link.Key == "taken" {
+					errs[i] = repository.ErrConflict
+				}
+			}
+			return errs
+		},
+	}
+	service := NewShortenerService(mockRepo)
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	errs := service.ImportLinks(context.Background(), []repository.Link{
+		{Key: "old.key~1", URL: "https://example.com", CreatedAt: created, Clicks: 7},
+		{Key: "expiring", URL: "https://example.org", ExpiresAt: time.Now().Add(time.Hour)},
+		{Key: "expired", URL: "https://example.org", ExpiresAt: time.Now().Add(-time.Hour)},
+		{Key: "bad/key", URL: "https://example.org"},
+		{Key: "badurl", URL: "ftp://example.org"},
+		{Key: "taken", URL: "https://example.org"},
+		{Key: "overdrawn", URL: "https://example.org", MaxClicks: 1, RemainingClicks: 2},
+		{Key: "sideways", URL: "https://example.org", ForwardQuery: "sideways"},
+		{Key: "badvariant", URL: "https://example.org", Variants: []repository.Variant{{URL: "https://example.org/a", Weight: 1}}},
+	})
+
+	expected := []error{nil, nil, ErrInvalidTTL, ErrInvalidAlias, ErrInvalidURL, ErrConflict, ErrInvalidMaxClicks, ErrInvalidForwarding, ErrInvalidVariant}
+	for i, want := range expected {
+		if !errors.Is(errs[i], want) {
+			t.Errorf("link %d: expected error %v, got %v", i, want, errs[i])
+		}
+	}
+
+	if len(saved) != 3 {
+		t.Fatalf("expected 3 links to reach the repository, got %d", len(saved))
+	}
+	if saved[0].Link.Key != "old.key~1" || !saved[0].Link.CreatedAt.Equal(created) || saved[0].TTL != 0 {
+		t.Errorf("expected key and creation time to be preserved, got %+v", saved[0])
+	}
+	if saved[1].TTL <= 0 || saved[1].TTL > time.Hour {
+		t.Errorf("expected TTL derived from expiry, got %s", saved[1].TTL)
+	}
+	if saved[1].Link.CreatedAt.IsZero() {
+		t.Error("expected missing creation time to default to now")
+	}
+}
+
+func TestManageLink_Authorization(t *testing.T) {
+	tests := []struct {
+		name        string
+		identity    Identity
+		linkOwner   string
+		getError    error
+		expectedErr error
+	}{
+		{
+			name:        "owner",
+			identity:    Identity{Owner: "alice"},
+			linkOwner:   "alice",
+			expectedErr: nil,
+		},
+		{
+			name:        "another owner",
+			identity:    Identity{Owner: "bob"},
+			linkOwner:   "alice",
+			expectedErr: ErrForbidden,
+		},
+		{
+			name:        "anonymous link",
+			identity:    Identity{Owner: "bob"},
+			linkOwner:   "",
+			expectedErr: ErrForbidden,
+		},
+		{
+			name:        "admin",
+			identity:    Identity{Owner: "ops", Admin: true},
+			linkOwner:   "alice",
+			expectedErr: nil,
+		},
+		{
+			name:        "missing link",
+			identity:    Identity{Owner: "alice"},
+			getError:    repository.ErrNotFound,
+			expectedErr: ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var updated, deleted bool
+			mockRepo := &MockRepository{
+				GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+					return repository.Link{Key: key, Owner: tt.linkOwner}, tt.getError
+				},
+				UpdateFunc: func(ctx context.Context, key string, url string) error {
+					updated = true
+					return nil
+				},
+				DeleteFunc: func(ctx context.Context, key string) error {
+					deleted = true
+					return nil
+				},
+			}
+
+			service := NewShortenerService(mockRepo)
+			ctx := context.Background()
+
+			updateErr := service.UpdateURL(ctx, tt.identity, "abc123", "https://example.org")
+			deleteErr := service.DeleteURL(ctx, tt.identity, "abc123")
+
+			if !errors.Is(updateErr, tt.expectedErr) {
+				t.Errorf("UpdateURL error = %v, want %v", updateErr, tt.expectedErr)
+			}
+			if !errors.Is(deleteErr, tt.expectedErr) {
+				t.Errorf("DeleteURL error = %v, want %v", deleteErr, tt.expectedErr)
+			}
+			if allowed := tt.expectedErr == nil; updated != allowed || deleted != allowed {
+				t.Errorf("expected repository writes = %v, got update %v delete %v", allowed, updated, deleted)
+			}
+		})
+	}
+}
+
+func TestListLinks_Pagination(t *testing.T) {
+	var gotOpts repository.ListOptions
+	mockRepo := &MockRepository{
+		ListByOwnerFunc: func(ctx context.Context, owner string, opts repository.ListOptions) ([]repository.Link, int64, error) {
+			gotOpts = opts
+			return []repository.Link{{Key: "abc123", Owner: owner}}, opts.Offset + opts.Limit, nil
+		},
+	}
+	service := NewShortenerService(mockRepo)
+	ctx := context.Background()
+
+	first, err := service.ListLinks(ctx, ListRequest{Owner: "alice", Limit: 500})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotOpts.Limit != maxPageSize || gotOpts.Offset != 0 {
+		t.Errorf("unexpected list options: %+v", gotOpts)
+	}
+	if first.NextCursor == "" {
+		t.Fatal("expected next cursor")
+	}
+
+	if _, err := service.ListLinks(ctx, ListRequest{Owner: "alice", Cursor: first.NextCursor}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotOpts.Offset != maxPageSize || gotOpts.Limit != defaultPageSize {
+		t.Errorf("unexpected list options: %+v", gotOpts)
+	}
+
+	if _, err := service.ListLinks(ctx, ListRequest{Owner: "alice", Cursor: "!!"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	mockRepo := &MockRepository{
+		GetAPIKeyFunc: func(ctx context.Context, token string) (repository.APIKey, error) {
+			if token == "valid" {
+				return repository.APIKey{Owner: "alice", Admin: true}, nil
+			}
+			return repository.APIKey{}, repository.ErrNotFound
+		},
+	}
+	service := NewShortenerService(mockRepo)
+	ctx := context.Background()
+
+	id, err := service.Authenticate(ctx, "valid")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id.Owner != "alice" || !id.Admin {
+		t.Errorf("unexpected identity: %+v", id)
+	}
+
+	if _, err := service.Authenticate(ctx, "invalid"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized, got %v", err)
+	}
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	var stored repository.APIKey
+	var storedToken string
+	mockRepo := &MockRepository{
+		CreateAPIKeyFunc: func(ctx context.Context, token string, key repository.APIKey) (repository.APIKey, error) {
+			storedToken, stored = token, key
+			key.ID = "id"
+			return key, nil
+		},
+	}
+	service := NewShortenerService(mockRepo)
+	ctx := context.Background()
+
+	token, key, err := service.CreateAPIKey(ctx, "alice", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token == "" || token != storedToken {
+		t.Errorf("expected the returned token to be stored, got %q and %q", token, storedToken)
+	}
+	if key.ID != "id" || stored.Owner != "alice" || !stored.Admin || stored.CreatedAt.IsZero() {
+		t.Errorf("unexpected key: %+v (stored %+v)", key, stored)
+	}
+
+	other, _, _ := service.CreateAPIKey(ctx, "alice", false)
+	if other == token {
+		t.Error("expected a new token for every key")
+	}
+
+	if _, _, err := service.CreateAPIKey(ctx, "", false); !errors.Is(err, ErrInvalidOwner) {
+		t.Errorf("expected ErrInvalidOwner, got %v", err)
+	}
+}
+
+func TestRevokeAPIKey_NotFound(t *testing.T) {
+	mockRepo := &MockRepository{
+		DeleteAPIKeyFunc: func(ctx context.Context, id string) error {
+			return repository.ErrNotFound
+		},
+	}
+	service := NewShortenerService(mockRepo)
+
+	if err := service.RevokeAPIKey(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
 func TestToBase62(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -267,7 +1060,7 @@ func BenchmarkShortenURL(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		service.ShortenURL(ctx, url)
+		service.ShortenURL(ctx, ShortenRequest{URL: url})
 	}
 }
 
@@ -279,3 +1072,116 @@ func BenchmarkToBase62(b *testing.B) {
 		toBase62(num)
 	}
 }
+
+func TestGetOriginalURL_Signed(t *testing.T) {
+	repo := &MockRepository{
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			return repository.Link{Key: key, URL: "https://example.com", Signed: true}, nil
+		},
+	}
+	svc := NewShortenerService(repo)
+
+	if _, err := svc.GetOriginalURL(context.Background(), "abc", Visit{}); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound without a signature, got %v", err)
+	}
+	if _, err := svc.PreviewLink(context.Background(), "abc", Visit{}); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound for an unsigned preview, got %v", err)
+	}
+	link, err := svc.GetOriginalURL(context.Background(), "abc", Visit{Signed: true})
+	if err != nil || link.URL != "https://example.com" {
+		t.Errorf("unexpected result %+v, %v", link, err)
+	}
+}
+
+func TestShortenURL_SignedGetsOwnKey(t *testing.T) {
+	var saved []repository.Link
+	repo := &MockRepository{
+		SaveFunc: func(ctx context.Context, link repository.Link, ttl time.Duration) error {
+			saved = append(saved, link)
+			return nil
+		},
+	}
+	svc := NewShortenerService(repo)
+
+	plain, err := svc.ShortenURL(context.Background(), ShortenRequest{URL: "https://example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	signed, err := svc.ShortenURL(context.Background(), ShortenRequest{URL: "https://example.com", Signed: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if plain == signed {
+		t.Errorf("signed and unsigned links share key %q", plain)
+	}
+	if len(saved) != 2 || saved[0].Signed || !saved[1].Signed {
+		t.Errorf("unexpected saved links: %+v", saved)
+	}
+}
+
+func TestShortenURL_OptionsGetOwnKey(t *testing.T) {
+	svc := NewShortenerService(&MockRepository{})
+	ctx := context.Background()
+	url := "https://example.com"
+
+	plain, err := svc.ShortenURL(ctx, ShortenRequest{URL: url})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	requests := []ShortenRequest{
+		{URL: url, TTL: time.Hour},
+		{URL: url, Interstitial: 5 * time.Second},
+		{URL: url, NotAfter: time.Now().Add(time.Hour)},
+		{URL: url, Rules: []repository.Rule{{Platform: "ios", URL: "https://apps.example.com"}}},
+		{URL: url, Variants: []repository.Variant{{URL: "https://example.com/a", Weight: 1}, {URL: "https://example.com/b", Weight: 1}}},
+		{URL: url, UTM: repository.UTM{Source: "newsletter"}},
+		{URL: url, ForwardQuery: repository.ForwardKeep},
+		{URL: url, ForwardPath: true},
+	}
+	seen := map[string]bool{plain: true}
+	for i, req := range requests {
+		key, err := svc.ShortenURL(ctx, req)
+		if err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+		if seen[key] {
+			t.Errorf("request %d: key %q is shared with another link", i, key)
+		}
+		seen[key] = true
+
+		if again, _ := svc.ShortenURL(ctx, req); again != key {
+			t.Errorf("request %d: expected the same options to keep key %q, got %q", i, key, again)
+		}
+	}
+}
+
+func TestShortenURL_RandomKeys(t *testing.T) {
+	tests := []struct {
+		length int
+		want   int
+	}{
+		{12, 12},
+		{4, MinRandomKeyLength},
+	}
+	for _, tt := range tests {
+		svc := NewShortenerService(&MockRepository{}, WithRandomKeys(tt.length))
+
+		first, err := svc.ShortenURL(context.Background(), ShortenRequest{URL: "https://example.com"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		second, _ := svc.ShortenURL(context.Background(), ShortenRequest{URL: "https://example.com"})
+		if len(first) != tt.want || !aliasPattern.MatchString(first) {
+			t.Errorf("WithRandomKeys(%d) generated %q, want %d base62 characters", tt.length, first, tt.want)
+		}
+		if first == second {
+			t.Errorf("random keys repeat: %q", first)
+		}
+	}
+
+	// Aliases are kept as chosen.
+	svc := NewShortenerService(&MockRepository{}, WithRandomKeys(10))
+	if key, err := svc.ShortenURL(context.Background(), ShortenRequest{URL: "https://example.com", Alias: "launch"}); err != nil || key != "launch" {
+		t.Errorf("unexpected result %q, %v", key, err)
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+	"url-shortener/repository"
+)
+
+var ErrInvalidTimeseries = errors.New("invalid time series range")
+
+// maxTimeseriesPoints caps the buckets returned by one request.
+const maxTimeseriesPoints = 1000
+
+// defaultSpans is the range returned when a request sets no start.
+var defaultSpans = map[repository.Granularity]time.Duration{
+	repository.GranularityHour: 24 * time.Hour,
+	repository.GranularityDay:  30 * 24 * time.Hour,
+}
+
+type TimeseriesRequest struct {
+	// Granularity defaults to hourly buckets.
+	Granularity repository.Granularity
+	// From defaults to a day (hourly) or 30 days (daily) before To, which
+	// defaults to now. Both are widened to whole buckets.
+	From, To time.Time
+}
+
+type TimeseriesPoint struct {
+	Start  time.Time
+	Clicks int64
+}
+
+// Timeseries has one point per bucket in [From, To), including empty ones.
+type Timeseries struct {
+	Granularity repository.Granularity
+	From, To    time.Time
+	Points      []TimeseriesPoint
+}
+
+func (s *service) ClickTimeseries(ctx context.Context, id Identity, shortKey string, req TimeseriesRequest) (Timeseries, error) {
+	g := req.Granularity
+	if g == "" {
+		g = repository.GranularityHour
+	}
+	span, ok := defaultSpans[g]
+	if !ok {
+		return Timeseries{}, ErrInvalidTimeseries
+	}
+
+	to := req.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	from := req.From
+	if from.IsZero() {
+		from = to.Add(-span)
+	}
+	from = bucketStart(g, from)
+	if start := bucketStart(g, to); start.Before(to) {
+		to = nextBucket(g, start)
+	} else {
+		to = start
+	}
+	if !from.Before(to) {
+		return Timeseries{}, ErrInvalidTimeseries
+	}
+
+	var points []TimeseriesPoint
+	for t := from; t.Before(to); t = nextBucket(g, t) {
+		if len(points) == maxTimeseriesPoints {
+			return Timeseries{}, ErrInvalidTimeseries
+		}
+		points = append(points, TimeseriesPoint{Start: t})
+	}
+
+	if _, err := s.ownedLink(ctx, id, shortKey); err != nil {
+		return Timeseries{}, err
+	}
+	counts, err := s.repo.ClickSeries(ctx, shortKey, g, from, to)
+	if err != nil {
+		return Timeseries{}, err
+	}
+	for i := range points {
+		points[i].Clicks = counts[points[i].Start]
+	}
+	return Timeseries{Granularity: g, From: from, To: to, Points: points}, nil
+}
+
+// bucketStart returns the start of the UTC bucket containing t.
+func bucketStart(g repository.Granularity, t time.Time) time.Time {
+	t = t.UTC()
+	if g == repository.GranularityHour {
+		return t.Truncate(time.Hour)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func nextBucket(g repository.Granularity, start time.Time) time.Time {
+	if g == repository.GranularityHour {
+		return start.Add(time.Hour)
+	}
+	return start.AddDate(0, 0, 1)
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+	"url-shortener/repository"
+)
+
+func TestClickTimeseries(t *testing.T) {
+	base := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	var gotFrom, gotTo time.Time
+	repo := &MockRepository{
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			return repository.Link{Key: key, Owner: "alice"}, nil
+		},
+		ClickSeriesFunc: func(ctx context.Context, key string, g repository.Granularity, from, to time.Time) (map[time.Time]int64, error) {
+			gotFrom, gotTo = from, to
+			return map[time.Time]int64{
+				base.Add(time.Hour):       4,
+				base.Add(3 * time.Hour):   1,
+				base.AddDate(0, 0, 2):     7,
+				base.Add(-48 * time.Hour): 9,
+			}, nil
+		},
+	}
+	svc := NewShortenerService(repo)
+	alice := Identity{Owner: "alice"}
+
+	series, err := svc.ClickTimeseries(context.Background(), alice, "abc", TimeseriesRequest{
+		From: base.Add(30 * time.Minute),
+		To:   base.Add(3*time.Hour + time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if series.Granularity != repository.GranularityHour {
+		t.Errorf("expected hourly buckets by default, got %q", series.Granularity)
+	}
+	// The range is widened to whole buckets.
+	if !series.From.Equal(base) || !series.To.Equal(base.Add(4*time.Hour)) || !gotFrom.Equal(base) || !gotTo.Equal(series.To) {
+		t.Errorf("unexpected range %v - %v (repository %v - %v)", series.From, series.To, gotFrom, gotTo)
+	}
+	want := []int64{0, 4, 0, 1}
+	if len(series.Points) != len(want) {
+		t.Fatalf("got %d points, want %d", len(series.Points), len(want))
+	}
+	for i, point := range series.Points {
+		if !point.Start.Equal(base.Add(time.Duration(i)*time.Hour)) || point.Clicks != want[i] {
+			t.Errorf("point %d = %+v, want %d clicks", i, point, want[i])
+		}
+	}
+
+	series, err = svc.ClickTimeseries(context.Background(), alice, "abc", TimeseriesRequest{
+		Granularity: repository.GranularityDay,
+		From:        base,
+		To:          base.AddDate(0, 0, 3),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(series.Points) != 3 || series.Points[2].Clicks != 7 || series.Points[0].Clicks != 0 {
+		t.Errorf("unexpected daily series: %+v", series.Points)
+	}
+}
+
+func TestClickTimeseries_Invalid(t *testing.T) {
+	repo := &MockRepository{
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			return repository.Link{Key: key, Owner: "alice"}, nil
+		},
+	}
+	svc := NewShortenerService(repo)
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		id   Identity
+		req  TimeseriesRequest
+		want error
+	}{
+		{"unknown granularity", Identity{Owner: "alice"}, TimeseriesRequest{Granularity: "week"}, ErrInvalidTimeseries},
+		{"reversed", Identity{Owner: "alice"}, TimeseriesRequest{From: now, To: now.Add(-2 * time.Hour)}, ErrInvalidTimeseries},
+		{"too many points", Identity{Owner: "alice"}, TimeseriesRequest{From: now.AddDate(-1, 0, 0), To: now}, ErrInvalidTimeseries},
+		{"not owner", Identity{Owner: "bob"}, TimeseriesRequest{}, ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.ClickTimeseries(context.Background(), tt.id, "abc", tt.req); err != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"url-shortener/repository"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "url-shortener/service"
+
+// expectedErrors are outcomes caused by the caller's input or the link's
+// state. They are recorded on spans without marking them as failed.
+var expectedErrors = []error{
+	ErrNotFound, ErrForbidden, ErrUnauthorized, ErrInvalidCursor, ErrInvalidURL,
+	ErrInvalidAlias, ErrInvalidTTL, ErrConflict, ErrInvalidOwner, ErrDisabled,
+	ErrInvalidInterstitial, ErrInvalidPassword, ErrPasswordRequired,
+	ErrWrongPassword, ErrTooManyAttempts, ErrInvalidMaxClicks, ErrExhausted,
+	ErrInvalidSchedule, ErrInvalidRule, ErrInvalidVariant, ErrInvalidForwarding,
+	ErrInvalidTimeseries, ErrInvalidWindow, ErrInvalidDomain, ErrUnknownDomain,
+}
+
+// tracedService starts a span around every call of the wrapped service.
+// Spans carry link keys but never destination URLs or secrets.
+type tracedService struct {
+	next ShortenerService
+}
+
+// NewTracedService wraps svc so each call is traced with the global tracer
+// provider.
+func NewTracedService(svc ShortenerService) ShortenerService {
+	return &tracedService{next: svc}
+}
+
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "service."+name, trace.WithAttributes(attrs...))
+}
+
+func keyAttr(key string) attribute.KeyValue {
+	return attribute.String("link.key", key)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		if !isExpected(err) {
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}
+
+func isExpected(err error) bool {
+	var policyErr *PolicyError
+	var inactiveErr *InactiveError
+	if errors.As(err, &policyErr) || errors.As(err, &inactiveErr) {
+		return true
+	}
+	for _, expected := range expectedErrors {
+		if errors.Is(err, expected) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *tracedService) ShortenURL(ctx context.Context, req ShortenRequest) (key string, err error) {
+	ctx, span := startSpan(ctx, "ShortenURL")
+	defer func() {
+		if key != "" {
+			span.SetAttributes(keyAttr(key))
+		}
+		endSpan(span, err)
+	}()
+	return t.next.ShortenURL(ctx, req)
+}
+
+func (t *tracedService) ShortenBatch(ctx context.Context, reqs []ShortenRequest) []BatchResult {
+	ctx, span := startSpan(ctx, "ShortenBatch", attribute.Int("batch.size", len(reqs)))
+	defer span.End()
+	return t.next.ShortenBatch(ctx, reqs)
+}
+
+func (t *tracedService) GetOriginalURL(ctx context.Context, shortKey string, visit Visit) (link repository.Link, err error) {
+	ctx, span := startSpan(ctx, "GetOriginalURL", keyAttr(shortKey))
+	defer func() {
+		if link.Variant > 0 {
+			span.SetAttributes(attribute.Int("link.variant", link.Variant))
+		}
+		endSpan(span, err)
+	}()
+	return t.next.GetOriginalURL(ctx, shortKey, visit)
+}
+
+func (t *tracedService) PreviewLink(ctx context.Context, shortKey string, visit Visit) (_ repository.Link, err error) {
+	ctx, span := startSpan(ctx, "PreviewLink", keyAttr(shortKey))
+	defer func() { endSpan(span, err) }()
+	return t.next.PreviewLink(ctx, shortKey, visit)
+}
+
+func (t *tracedService) UpdateURL(ctx context.Context, id Identity, shortKey string, url string) (err error) {
+	ctx, span := startSpan(ctx, "UpdateURL", keyAttr(shortKey))
+	defer func() { endSpan(span, err) }()
+	return t.next.UpdateURL(ctx, id, shortKey, url)
+}
+
+func (t *tracedService) DeleteURL(ctx context.Context, id Identity, shortKey string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteURL", keyAttr(shortKey))
+	defer func() { endSpan(span, err) }()
+	return t.next.DeleteURL(ctx, id, shortKey)
+}
+
+func (t *tracedService) ListLinks(ctx context.Context, req ListRequest) (_ LinkPage, err error) {
+	ctx, span := startSpan(ctx, "ListLinks")
+	defer func() { endSpan(span, err) }()
+	return t.next.ListLinks(ctx, req)
+}
+
+func (t *tracedService) Authenticate(ctx context.Context, apiKey string) (_ Identity, err error) {
+	ctx, span := startSpan(ctx, "Authenticate")
+	defer func() { endSpan(span, err) }()
+	return t.next.Authenticate(ctx, apiKey)
+}
+
+func (t *tracedService) InspectLink(ctx context.Context, id Identity, shortKey string) (_ repository.Link, err error) {
+	ctx, span := startSpan(ctx, "InspectLink", keyAttr(shortKey))
+	defer func() { endSpan(span, err) }()
+	return t.next.InspectLink(ctx, id, shortKey)
+}
+
+func (t *tracedService) ClickTimeseries(ctx context.Context, id Identity, shortKey string, req TimeseriesRequest) (_ Timeseries, err error) {
+	ctx, span := startSpan(ctx, "ClickTimeseries", keyAttr(shortKey), attribute.String("series.granularity", string(req.Granularity)))
+	defer func() { endSpan(span, err) }()
+	return t.next.ClickTimeseries(ctx, id, shortKey, req)
+}
+
+func (t *tracedService) TopLinks(ctx context.Context, req DashboardRequest) (_ Ranking, err error) {
+	ctx, span := startSpan(ctx, "TopLinks")
+	defer func() { endSpan(span, err) }()
+	return t.next.TopLinks(ctx, req)
+}
+
+func (t *tracedService) TopReferrers(ctx context.Context, req DashboardRequest) (_ Ranking, err error) {
+	ctx, span := startSpan(ctx, "TopReferrers")
+	defer func() { endSpan(span, err) }()
+	return t.next.TopReferrers(ctx, req)
+}
+
+func (t *tracedService) LinksCreated(ctx context.Context, req DashboardRequest) (_ DailyCounts, err error) {
+	ctx, span := startSpan(ctx, "LinksCreated")
+	defer func() { endSpan(span, err) }()
+	return t.next.LinksCreated(ctx, req)
+}
+
+func (t *tracedService) SaveDomain(ctx context.Context, d repository.Domain) (_ repository.Domain, err error) {
+	ctx, span := startSpan(ctx, "SaveDomain", attribute.String("domain.name", d.Name))
+	defer func() { endSpan(span, err) }()
+	return t.next.SaveDomain(ctx, d)
+}
+
+func (t *tracedService) GetDomain(ctx context.Context, name string) (_ repository.Domain, err error) {
+	ctx, span := startSpan(ctx, "GetDomain", attribute.String("domain.name", name))
+	defer func() { endSpan(span, err) }()
+	return t.next.GetDomain(ctx, name)
+}
+
+func (t *tracedService) ListDomains(ctx context.Context) (_ []repository.Domain, err error) {
+	ctx, span := startSpan(ctx, "ListDomains")
+	defer func() { endSpan(span, err) }()
+	return t.next.ListDomains(ctx)
+}
+
+func (t *tracedService) DeleteDomain(ctx context.Context, name string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteDomain", attribute.String("domain.name", name))
+	defer func() { endSpan(span, err) }()
+	return t.next.DeleteDomain(ctx, name)
+}
+
+func (t *tracedService) CreateAPIKey(ctx context.Context, owner string, admin bool) (_ string, _ repository.APIKey, err error) {
+	ctx, span := startSpan(ctx, "CreateAPIKey")
+	defer func() { endSpan(span, err) }()
+	return t.next.CreateAPIKey(ctx, owner, admin)
+}
+
+func (t *tracedService) RevokeAPIKey(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "RevokeAPIKey", attribute.String("api_key.id", id))
+	defer func() { endSpan(span, err) }()
+	return t.next.RevokeAPIKey(ctx, id)
+}
+
+func (t *tracedService) ListAPIKeys(ctx context.Context) (_ []repository.APIKey, err error) {
+	ctx, span := startSpan(ctx, "ListAPIKeys")
+	defer func() { endSpan(span, err) }()
+	return t.next.ListAPIKeys(ctx)
+}
+
+func (t *tracedService) PurgeExpired(ctx context.Context) (_ int, err error) {
+	ctx, span := startSpan(ctx, "PurgeExpired")
+	defer func() { endSpan(span, err) }()
+	return t.next.PurgeExpired(ctx)
+}
+
+func (t *tracedService) ExportLinks(ctx context.Context, fn func(repository.Link) error) (err error) {
+	ctx, span := startSpan(ctx, "ExportLinks")
+	defer func() { endSpan(span, err) }()
+	return t.next.ExportLinks(ctx, fn)
+}
+
+func (t *tracedService) ImportLinks(ctx context.Context, links []repository.Link) []error {
+	ctx, span := startSpan(ctx, "ImportLinks", attribute.Int("batch.size", len(links)))
+	defer span.End()
+	return t.next.ImportLinks(ctx, links)
+}
+
+func (t *tracedService) RecheckLinks(ctx context.Context) (_ RecheckSummary, err error) {
+	ctx, span := startSpan(ctx, "RecheckLinks")
+	defer func() { endSpan(span, err) }()
+	return t.next.RecheckLinks(ctx)
+}
+
+func (t *tracedService) ListDisabledLinks(ctx context.Context) (_ []repository.Link, err error) {
+	ctx, span := startSpan(ctx, "ListDisabledLinks")
+	defer func() { endSpan(span, err) }()
+	return t.next.ListDisabledLinks(ctx)
+}
+
+func (t *tracedService) EnableLink(ctx context.Context, shortKey string) (err error) {
+	ctx, span := startSpan(ctx, "EnableLink", keyAttr(shortKey))
+	defer func() { endSpan(span, err) }()
+	return t.next.EnableLink(ctx, shortKey)
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"url-shortener/repository"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a tracer provider exporting to memory for the test.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		_ = provider.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+func spanNamed(spans tracetest.SpanStubs, name string) (tracetest.SpanStub, bool) {
+	for _, span := range spans {
+		if span.Name == name {
+			return span, true
+		}
+	}
+	return tracetest.SpanStub{}, false
+}
+
+func TestTracing_SpanStructure(t *testing.T) {
+	exporter := recordSpans(t)
+
+	repo := &MockRepository{
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			return repository.Link{Key: key, URL: "https://example.com/secret?token=abc"}, nil
+		},
+	}
+	svc := NewTracedService(NewShortenerService(repository.NewTracedRepository(repo)))
+
+	if _, err := svc.GetOriginalURL(context.Background(), "abc123", Visit{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	root, ok := spanNamed(spans, "service.GetOriginalURL")
+	if !ok {
+		t.Fatalf("no service span in %d spans", len(spans))
+	}
+	if root.Parent.IsValid() {
+		t.Error("service span should be the root")
+	}
+
+	for _, name := range []string{"repository.GetLink", "repository.IncrClicks"} {
+		span, ok := spanNamed(spans, name)
+		if !ok {
+			t.Errorf("missing span %s", name)
+			continue
+		}
+		if span.Parent.SpanID() != root.SpanContext.SpanID() {
+			t.Errorf("%s is not a child of the service span", name)
+		}
+	}
+
+	for _, span := range spans {
+		hasKey := false
+		for _, attr := range span.Attributes {
+			if attr.Key == "link.key" && attr.Value.AsString() == "abc123" {
+				hasKey = true
+			}
+			if strings.Contains(attr.Value.Emit(), "example.com") {
+				t.Errorf("%s leaks the destination in %s", span.Name, attr.Key)
+			}
+		}
+		if !hasKey {
+			t.Errorf("%s has no link.key attribute", span.Name)
+		}
+	}
+}
+
+func TestTracing_ErrorStatus(t *testing.T) {
+	exporter := recordSpans(t)
+
+	failure := errors.New("connection refused")
+	repo := &MockRepository{
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			if key == "missing" {
+				return repository.Link{}, repository.ErrNotFound
+			}
+			return repository.Link{}, failure
+		},
+	}
+	svc := NewTracedService(NewShortenerService(repository.NewTracedRepository(repo)))
+
+	tests := []struct {
+		key  string
+		want codes.Code
+	}{
+		{key: "missing", want: codes.Unset},
+		{key: "broken", want: codes.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			exporter.Reset()
+			if _, err := svc.GetOriginalURL(context.Background(), tt.key, Visit{}); err == nil {
+				t.Fatal("expected an error")
+			}
+
+			for _, span := range exporter.GetSpans() {
+				if span.Status.Code != tt.want {
+					t.Errorf("%s status = %v, want %v", span.Name, span.Status.Code, tt.want)
+				}
+				if len(span.Events) == 0 {
+					t.Errorf("%s did not record the error", span.Name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"sync"
+	"url-shortener/repository"
+)
+
+const (
+	maxVariants      = 10
+	maxVariantWeight = 1000
+)
+
+// lockedRand makes a rand.Rand safe to share between requests.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (l *lockedRand) IntN(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.IntN(n)
+}
+
+// WithRandSource makes variant assignment of visitors without a cookie or
+// IP address draw from src instead of the global random source.
+func WithRandSource(src rand.Source) Option {
+	return func(s *service) {
+		s.random = (&lockedRand{r: rand.New(src)}).IntN
+	}
+}
+
+// newVariants validates the A/B split of a new link.
+func (s *service) newVariants(ctx context.Context, variants []repository.Variant) ([]repository.Variant, error) {
+	if len(variants) == 0 {
+		return nil, nil
+	}
+	if len(variants) < 2 || len(variants) > maxVariants {
+		return nil, fmt.Errorf("%w: between 2 and %d variants are needed", ErrInvalidVariant, maxVariants)
+	}
+
+	normalized := make([]repository.Variant, len(variants))
+	for i, variant := range variants {
+		if variant.Weight < 1 || variant.Weight > maxVariantWeight {
+			return nil, fmt.Errorf("%w %d: weight must be between 1 and %d", ErrInvalidVariant, i+1, maxVariantWeight)
+		}
+		if err := s.checkDestination(ctx, variant.URL); err != nil {
+			return nil, fmt.Errorf("%w %d: %w", ErrInvalidVariant, i+1, err)
+		}
+		normalized[i] = repository.Variant{URL: variant.URL, Weight: variant.Weight}
+	}
+	return normalized, nil
+}
+
+// chooseVariant points link at one of its variants. Returning visitors keep
+// the variant they were given before; new ones are assigned by a hash of
+// their IP address, or at random when it is unknown.
+func (s *service) chooseVariant(link repository.Link, visit Visit) repository.Link {
+	if len(link.Variants) == 0 {
+		return link
+	}
+
+	if visit.Variant >= 1 && visit.Variant <= len(link.Variants) {
+		link.Variant = visit.Variant
+	} else {
+		total := 0
+		for _, variant := range link.Variants {
+			total += variant.Weight
+		}
+
+		var n int
+		switch {
+		case visit.IP != "":
+			h := fnv.New64a()
+			h.Write([]byte(link.Key + " " + visit.IP))
+			n = int(h.Sum64() % uint64(total))
+		case s.random != nil:
+			n = s.random(total)
+		default:
+			n = rand.IntN(total)
+		}
+		link.Variant = pickVariant(link.Variants, n)
+	}
+
+	link.URL = link.Variants[link.Variant-1].URL
+	return link
+}
+
+// pickVariant returns the 1-based index of the variant that n, a number
+// below the total weight, falls on.
+func pickVariant(variants []repository.Variant, n int) int {
+	for i, variant := range variants {
+		if n < variant.Weight {
+			return i + 1
+		}
+		n -= variant.Weight
+	}
+	return len(variants)
+}
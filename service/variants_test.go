@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"testing"
+	"url-shortener/repository"
+)
+
+func TestPickVariant(t *testing.T) {
+	variants := []repository.Variant{{Weight: 1}, {Weight: 3}, {Weight: 6}}
+	tests := map[int]int{0: 1, 1: 2, 3: 2, 4: 3, 9: 3}
+	for n, want := range tests {
+		if got := pickVariant(variants, n); got != want {
+			t.Errorf("pickVariant(%d) = %d, want %d", n, got, want)
+		}
+	}
+}
+
+func splitLink() repository.Link {
+	return repository.Link{
+		Key: "exp",
+		URL: "https://example.com",
+		Variants: []repository.Variant{
+			{URL: "https://example.com/a", Weight: 1},
+			{URL: "https://example.com/b", Weight: 3},
+		},
+	}
+}
+
+func TestGetOriginalURL_Variants(t *testing.T) {
+	variantClicks := make(map[int]int)
+	mockRepo := &MockRepository{
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			return splitLink(), nil
+		},
+		IncrVariantClicksFunc: func(ctx context.Context, key string, variant int) error {
+			variantClicks[variant]++
+			return nil
+		},
+	}
+	service := NewShortenerService(mockRepo, WithRandSource(rand.NewPCG(1, 2)))
+	ctx := context.Background()
+
+	counts := make(map[string]int)
+	for range 4000 {
+		link, err := service.GetOriginalURL(ctx, "exp", Visit{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		counts[link.URL]++
+	}
+	if a := counts["https://example.com/a"]; a < 800 || a > 1200 {
+		t.Errorf("expected about a quarter of visits on variant a, got %v", counts)
+	}
+	if variantClicks[0] != counts["https://example.com/a"] || variantClicks[1] != counts["https://example.com/b"] {
+		t.Errorf("variant clicks %v do not match redirects %v", variantClicks, counts)
+	}
+
+	link, _ := service.GetOriginalURL(ctx, "exp", Visit{Variant: 1})
+	if link.URL != "https://example.com/a" || link.Variant != 1 {
+		t.Errorf("expected the remembered variant, got %+v", link)
+	}
+
+	link, _ = service.GetOriginalURL(ctx, "exp", Visit{Variant: 7, IP: "203.0.113.7"})
+	for range 10 {
+		again, _ := service.GetOriginalURL(ctx, "exp", Visit{IP: "203.0.113.7"})
+		if again.Variant != link.Variant {
+			t.Fatalf("expected the same IP to keep variant %d, got %d", link.Variant, again.Variant)
+		}
+	}
+
+	preview, _ := service.PreviewLink(ctx, "exp", Visit{})
+	if preview.URL != "https://example.com" || preview.Variant != 0 {
+		t.Errorf("expected previews to show the canonical URL, got %+v", preview)
+	}
+}
+
+func TestGetOriginalURL_VariantsDeterministic(t *testing.T) {
+	mockRepo := &MockRepository{
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			return splitLink(), nil
+		},
+	}
+	first := NewShortenerService(mockRepo, WithRandSource(rand.NewPCG(7, 7)))
+	second := NewShortenerService(mockRepo, WithRandSource(rand.NewPCG(7, 7)))
+
+	for i := range 50 {
+		a, _ := first.GetOriginalURL(context.Background(), "exp", Visit{})
+		b, _ := second.GetOriginalURL(context.Background(), "exp", Visit{})
+		if a.Variant != b.Variant {
+			t.Fatalf("visit %d: expected equal sources to assign equal variants", i)
+		}
+	}
+}
+
+func TestGetOriginalURL_RulesBeatVariants(t *testing.T) {
+	variantCounted := false
+	mockRepo := &MockRepository{
+		GetLinkFunc: func(ctx context.Context, key string) (repository.Link, error) {
+			link := splitLink()
+			link.Rules = []repository.Rule{{Platform: "ios", URL: "https://apps.apple.com/app/id1"}}
+			return link, nil
+		},
+		IncrVariantClicksFunc: func(ctx context.Context, key string, variant int) error {
+			variantCounted = true
+			return nil
+		},
+	}
+	service := NewShortenerService(mockRepo)
+
+	link, _ := service.GetOriginalURL(context.Background(), "exp", Visit{UserAgent: iPhoneUA})
+	if link.URL != "https://apps.apple.com/app/id1" || link.Variant != 0 || variantCounted {
+		t.Errorf("expected the rule to win over the split, got %+v", link)
+	}
+}
+
+func TestShortenURL_InvalidVariants(t *testing.T) {
+	service := NewShortenerService(&MockRepository{})
+
+	tests := []struct {
+		name     string
+		variants []repository.Variant
+		err      error
+	}{
+		{"single variant", []repository.Variant{{URL: "https://example.com/a", Weight: 1}}, ErrInvalidVariant},
+		{"zero weight", []repository.Variant{{URL: "https://example.com/a", Weight: 1}, {URL: "https://example.com/b"}}, ErrInvalidVariant},
+		{"heavy weight", []repository.Variant{{URL: "https://example.com/a", Weight: 1}, {URL: "https://example.com/b", Weight: maxVariantWeight + 1}}, ErrInvalidVariant},
+		{"invalid url", []repository.Variant{{URL: "https://example.com/a", Weight: 1}, {URL: "javascript:alert(1)", Weight: 1}}, ErrInvalidURL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.ShortenURL(context.Background(), ShortenRequest{URL: "https://example.com", Variants: tt.variants})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
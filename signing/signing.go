@@ -0,0 +1,107 @@
+// Package signing makes short links that cannot be guessed. A signed link is
+// served as "<key>.<key id>.<signature>", where the signature is an HMAC of
+// the key under a server secret. Requests with a bad signature are rejected
+// before the link is looked up, so scanning the key space never reaches the
+// repository. Secrets are named by key IDs so they can be rotated: new links
+// are signed with the current key while links signed with older keys keep
+// working for as long as those keys are configured.
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid link signature")
+	// ErrUnsigned is returned for keys without a signature when signatures
+	// are required.
+	ErrUnsigned = errors.New("link signature required")
+)
+
+// minSecretLength is the shortest accepted secret in bytes.
+const minSecretLength = 16
+
+// signatureLength is the length of the encoded, truncated HMAC.
+const signatureLength = 22
+
+var (
+	keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,16}$`)
+	// signedPattern splits a signed segment into key, key ID and signature.
+	signedPattern = regexp.MustCompile(`^(.+)\.([A-Za-z0-9_-]{1,16})\.([A-Za-z0-9_-]{22})$`)
+)
+
+type Config struct {
+	// Enabled lets clients create signed links.
+	Enabled bool `yaml:"enabled"`
+	// Required signs every new link and rejects requests without a
+	// signature.
+	Required bool `yaml:"required"`
+	// Keys maps key IDs to secrets of at least 16 bytes.
+	Keys map[string]string `yaml:"keys"`
+	// CurrentKey is the ID of the key new links are signed with.
+	CurrentKey string `yaml:"current_key"`
+}
+
+type Signer struct {
+	keys     map[string][]byte
+	current  string
+	required bool
+}
+
+func New(cfg Config) (*Signer, error) {
+	if _, ok := cfg.Keys[cfg.CurrentKey]; !ok {
+		return nil, fmt.Errorf("signing: current key %q is not configured", cfg.CurrentKey)
+	}
+	keys := make(map[string][]byte, len(cfg.Keys))
+	for id, secret := range cfg.Keys {
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("signing: invalid key ID %q", id)
+		}
+		if len(secret) < minSecretLength {
+			return nil, fmt.Errorf("signing: secret of key %q is shorter than %d bytes", id, minSecretLength)
+		}
+		keys[id] = []byte(secret)
+	}
+	return &Signer{keys: keys, current: cfg.CurrentKey, required: cfg.Required}, nil
+}
+
+// Required reports whether every link must be signed.
+func (s *Signer) Required() bool {
+	return s.required
+}
+
+// Sign returns the path segment serving key as a signed link.
+func (s *Signer) Sign(key string) string {
+	return key + "." + s.current + "." + sign(s.keys[s.current], s.current, key)
+}
+
+// Verify returns the key of a path segment and whether it was signed. It
+// fails for segments with a wrong signature or an unknown key ID, and for
+// unsigned ones when signatures are required.
+func (s *Signer) Verify(segment string) (string, bool, error) {
+	m := signedPattern.FindStringSubmatch(segment)
+	if m == nil {
+		if s.required {
+			return "", false, ErrUnsigned
+		}
+		return segment, false, nil
+	}
+
+	key, id, signature := m[1], m[2], m[3]
+	secret, ok := s.keys[id]
+	if !ok || !hmac.Equal([]byte(signature), []byte(sign(secret, id, key))) {
+		return "", false, ErrInvalidSignature
+	}
+	return key, true, nil
+}
+
+func sign(secret []byte, id, key string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id + "." + key))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))[:signatureLength]
+}
@@ -0,0 +1,86 @@
+package signing
+
+import (
+	"strings"
+	"testing"
+)
+
+func newSigner(t *testing.T, cfg Config) *Signer {
+	t.Helper()
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s
+}
+
+func TestSignVerify(t *testing.T) {
+	old := newSigner(t, Config{
+		Keys:       map[string]string{"k1": "0123456789abcdef"},
+		CurrentKey: "k1",
+	})
+	signed := old.Sign("abc123")
+	if !strings.HasPrefix(signed, "abc123.k1.") || len(signed) != len("abc123.k1.")+signatureLength {
+		t.Fatalf("unexpected signed segment %q", signed)
+	}
+
+	// After rotating to k2, links signed with k1 keep working.
+	s := newSigner(t, Config{
+		Keys:       map[string]string{"k1": "0123456789abcdef", "k2": "fedcba9876543210"},
+		CurrentKey: "k2",
+	})
+	tests := []struct {
+		name       string
+		segment    string
+		wantKey    string
+		wantSigned bool
+		wantErr    error
+	}{
+		{"signed with old key", signed, "abc123", true, nil},
+		{"signed with current key", s.Sign("x.y"), "x.y", true, nil},
+		{"unsigned", "abc123", "abc123", false, nil},
+		{"tampered key", "abc124" + strings.TrimPrefix(signed, "abc123"), "", false, ErrInvalidSignature},
+		{"tampered signature", signed[:len(signed)-1] + "A", "", false, ErrInvalidSignature},
+		{"unknown key", strings.Replace(signed, ".k1.", ".k9.", 1), "", false, ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, isSigned, err := s.Verify(tt.segment)
+			if err != tt.wantErr || key != tt.wantKey || isSigned != tt.wantSigned {
+				t.Errorf("Verify(%q) = %q, %v, %v; want %q, %v, %v", tt.segment, key, isSigned, err, tt.wantKey, tt.wantSigned, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerify_Required(t *testing.T) {
+	s := newSigner(t, Config{
+		Required:   true,
+		Keys:       map[string]string{"k1": "0123456789abcdef"},
+		CurrentKey: "k1",
+	})
+	if _, _, err := s.Verify("abc123"); err != ErrUnsigned {
+		t.Errorf("expected ErrUnsigned, got %v", err)
+	}
+	if key, _, err := s.Verify(s.Sign("abc123")); err != nil || key != "abc123" {
+		t.Errorf("unexpected result %q, %v", key, err)
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"no current key", Config{Keys: map[string]string{"k1": "0123456789abcdef"}}},
+		{"short secret", Config{Keys: map[string]string{"k1": "short"}, CurrentKey: "k1"}},
+		{"bad key ID", Config{Keys: map[string]string{"k.1": "0123456789abcdef"}, CurrentKey: "k.1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.cfg); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup_Disabled(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+
+	fields := otel.GetTextMapPropagator().Fields()
+	if len(fields) == 0 || fields[0] != "traceparent" {
+		t.Errorf("expected W3C trace context propagation, got %v", fields)
+	}
+}
+
+func TestSetup_OTLP(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Config{
+		Exporter:    ExporterOTLP,
+		Endpoint:    "127.0.0.1:1",
+		Insecure:    true,
+		ServiceName: "url-shortener",
+		SampleRatio: 1,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = shutdown(ctx)
+	})
+
+	_, span := otel.Tracer("test").Start(context.Background(), "probe")
+	defer span.End()
+	if !span.SpanContext().IsSampled() {
+		t.Error("expected spans to be sampled")
+	}
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), Config{Exporter: "zipkin"}); err == nil {
+		t.Error("expected an error for an unknown exporter")
+	}
+}
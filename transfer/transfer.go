@@ -0,0 +1,534 @@
+package transfer
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+	"url-shortener/repository"
+	"url-shortener/service"
+)
+
+type Format string
+
+const (
+	FormatJSONL Format = "jsonl"
+	FormatCSV   Format = "csv"
+)
+
+var ErrUnknownFormat = errors.New("unknown format, expected jsonl or csv")
+
+// importBatch is how many records are handed to the service at once.
+const importBatch = 500
+
+// maxReportedErrors bounds the per-record errors kept in a Summary.
+const maxReportedErrors = 100
+
+var csvHeader = []string{
+	"key", "url", "created_at", "expires_at", "owner", "clicks",
+	"interstitial", "password_hash", "max_clicks", "remaining_clicks",
+	"not_before", "not_after", "fallback_url", "rules", "variants", "utm",
+	"forward_query", "forward_path", "signed",
+	"disabled_reason", "disabled_at", "approved_url",
+}
+
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case FormatJSONL, FormatCSV:
+		return Format(s), nil
+	default:
+		return "", ErrUnknownFormat
+	}
+}
+
+// Store is the part of service.ShortenerService used for transfers.
+type Store interface {
+	ExportLinks(ctx context.Context, fn func(repository.Link) error) error
+	ImportLinks(ctx context.Context, links []repository.Link) []error
+}
+
+// Record is one link in an export file.
+type Record struct {
+	Key       string     `json:"key"`
+	URL       string     `json:"url"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Owner     string     `json:"owner,omitempty"`
+	Clicks    int64      `json:"clicks"`
+	// Interstitial is in seconds.
+	Interstitial int64  `json:"interstitial,omitempty"`
+	PasswordHash string `json:"password_hash,omitempty"`
+	MaxClicks    int64  `json:"max_clicks,omitempty"`
+	// RemainingClicks is only set for click-limited links. A record
+	// without it gets the full MaxClicks back.
+	RemainingClicks *int64                     `json:"remaining_clicks,omitempty"`
+	NotBefore       *time.Time                 `json:"not_before,omitempty"`
+	NotAfter        *time.Time                 `json:"not_after,omitempty"`
+	FallbackURL     string                     `json:"fallback_url,omitempty"`
+	Rules           []repository.Rule          `json:"rules,omitempty"`
+	Variants        []repository.Variant       `json:"variants,omitempty"`
+	UTM             *repository.UTM            `json:"utm,omitempty"`
+	ForwardQuery    repository.QueryForwarding `json:"forward_query,omitempty"`
+	ForwardPath     bool                       `json:"forward_path,omitempty"`
+	Signed          bool                       `json:"signed,omitempty"`
+	DisabledReason  string                     `json:"disabled_reason,omitempty"`
+	DisabledAt      *time.Time                 `json:"disabled_at,omitempty"`
+	ApprovedURL     string                     `json:"approved_url,omitempty"`
+}
+
+func recordFromLink(link repository.Link) Record {
+	rec := Record{
+		Key:            link.Key,
+		URL:            link.URL,
+		CreatedAt:      link.CreatedAt,
+		ExpiresAt:      timeOrNil(link.ExpiresAt),
+		Owner:          link.Owner,
+		Clicks:         link.Clicks,
+		Interstitial:   int64(link.Interstitial / time.Second),
+		PasswordHash:   link.PasswordHash,
+		MaxClicks:      link.MaxClicks,
+		NotBefore:      timeOrNil(link.NotBefore),
+		NotAfter:       timeOrNil(link.NotAfter),
+		FallbackURL:    link.FallbackURL,
+		Rules:          link.Rules,
+		Variants:       link.Variants,
+		ForwardQuery:   link.ForwardQuery,
+		ForwardPath:    link.ForwardPath,
+		Signed:         link.Signed,
+		DisabledReason: link.DisabledReason,
+		ApprovedURL:    link.ApprovedURL,
+	}
+	if link.MaxClicks > 0 {
+		remaining := link.RemainingClicks
+		rec.RemainingClicks = &remaining
+	}
+	if link.UTM != (repository.UTM{}) {
+		utm := link.UTM
+		rec.UTM = &utm
+	}
+	if link.DisabledReason != "" {
+		rec.DisabledAt = timeOrNil(link.DisabledAt)
+	}
+	return rec
+}
+
+func (r Record) link() repository.Link {
+	link := repository.Link{
+		Key:             r.Key,
+		URL:             r.URL,
+		Owner:           r.Owner,
+		CreatedAt:       r.CreatedAt,
+		ExpiresAt:       timeOrZero(r.ExpiresAt),
+		Clicks:          r.Clicks,
+		Interstitial:    time.Duration(r.Interstitial) * time.Second,
+		PasswordHash:    r.PasswordHash,
+		MaxClicks:       r.MaxClicks,
+		RemainingClicks: r.MaxClicks,
+		NotBefore:       timeOrZero(r.NotBefore),
+		NotAfter:        timeOrZero(r.NotAfter),
+		FallbackURL:     r.FallbackURL,
+		Rules:           r.Rules,
+		Variants:        r.Variants,
+		ForwardQuery:    r.ForwardQuery,
+		ForwardPath:     r.ForwardPath,
+		Signed:          r.Signed,
+		DisabledReason:  r.DisabledReason,
+		DisabledAt:      timeOrZero(r.DisabledAt),
+		ApprovedURL:     r.ApprovedURL,
+	}
+	if r.RemainingClicks != nil {
+		link.RemainingClicks = *r.RemainingClicks
+	}
+	if r.UTM != nil {
+		link.UTM = *r.UTM
+	}
+	return link
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func timeOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// RecordError describes a record that was not imported. Line is the line of
+// the input the record was read from.
+type RecordError struct {
+	Line  int    `json:"line"`
+	Key   string `json:"key,omitempty"`
+	Error string `json:"error"`
+}
+
+type Summary struct {
+	Imported  int           `json:"imported"`
+	Conflicts int           `json:"conflicts"`
+	Failed    int           `json:"failed"`
+	Errors    []RecordError `json:"errors,omitempty"`
+}
+
+func (s *Summary) fail(line int, key string, err error) {
+	if errors.Is(err, service.ErrConflict) {
+		s.Conflicts++
+	} else {
+		s.Failed++
+	}
+	if len(s.Errors) < maxReportedErrors {
+		s.Errors = append(s.Errors, RecordError{Line: line, Key: key, Error: err.Error()})
+	}
+}
+
+// Export writes every link held by store to w.
+func Export(ctx context.Context, w io.Writer, format Format, store Store) error {
+	bw := bufio.NewWriter(w)
+
+	var write func(Record) error
+	switch format {
+	case FormatJSONL:
+		enc := json.NewEncoder(bw)
+		write = func(rec Record) error {
+			return enc.Encode(rec)
+		}
+	case FormatCSV:
+		cw := csv.NewWriter(bw)
+		if err := cw.Write(csvHeader); err != nil {
+			return err
+		}
+		write = func(rec Record) error {
+			if err := cw.Write(csvRow(rec)); err != nil {
+				return err
+			}
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		return ErrUnknownFormat
+	}
+
+	if err := store.ExportLinks(ctx, func(link repository.Link) error {
+		return write(recordFromLink(link))
+	}); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func csvRow(rec Record) []string {
+	remaining := ""
+	if rec.RemainingClicks != nil {
+		remaining = strconv.FormatInt(*rec.RemainingClicks, 10)
+	}
+	return []string{
+		rec.Key,
+		rec.URL,
+		rec.CreatedAt.Format(time.RFC3339),
+		csvTime(rec.ExpiresAt),
+		rec.Owner,
+		strconv.FormatInt(rec.Clicks, 10),
+		csvInt(rec.Interstitial),
+		rec.PasswordHash,
+		csvInt(rec.MaxClicks),
+		remaining,
+		csvTime(rec.NotBefore),
+		csvTime(rec.NotAfter),
+		rec.FallbackURL,
+		csvJSON(rec.Rules),
+		csvJSON(rec.Variants),
+		csvJSON(rec.UTM),
+		string(rec.ForwardQuery),
+		csvBool(rec.ForwardPath),
+		csvBool(rec.Signed),
+		rec.DisabledReason,
+		csvTime(rec.DisabledAt),
+		rec.ApprovedURL,
+	}
+}
+
+func csvTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func csvInt(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func csvBool(b bool) string {
+	if !b {
+		return ""
+	}
+	return "true"
+}
+
+// csvJSON encodes the structured fields of a record, which have no natural
+// CSV form, as JSON.
+func csvJSON[T any](v T) string {
+	// The values are plain data, so marshalling them cannot fail.
+	raw, _ := json.Marshal(v)
+	if string(raw) == "null" {
+		return ""
+	}
+	return string(raw)
+}
+
+// Import reads links from r and stores them under their original keys.
+// Invalid records and conflicts are counted in the summary rather than
+// aborting the import. An error is only returned when r cannot be read.
+func Import(ctx context.Context, r io.Reader, format Format, store Store) (Summary, error) {
+	var next func() (Record, int, error)
+	switch format {
+	case FormatJSONL:
+		next = jsonlReader(r)
+	case FormatCSV:
+		next = csvReader(r)
+	default:
+		return Summary{}, ErrUnknownFormat
+	}
+
+	var (
+		summary Summary
+		batch   []Record
+		lines   []int
+	)
+	flush := func() {
+		links := make([]repository.Link, len(batch))
+		for i, rec := range batch {
+			links[i] = rec.link()
+		}
+		for i, err := range store.ImportLinks(ctx, links) {
+			if err != nil {
+				summary.fail(lines[i], batch[i].Key, err)
+				continue
+			}
+			summary.Imported++
+		}
+		batch, lines = batch[:0], lines[:0]
+	}
+
+	for {
+		rec, line, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *parseError
+		if errors.As(err, &parseErr) {
+			summary.fail(line, "", err)
+			continue
+		}
+		if err != nil {
+			return summary, err
+		}
+
+		batch = append(batch, rec)
+		lines = append(lines, line)
+		if len(batch) == importBatch {
+			flush()
+		}
+	}
+	if len(batch) > 0 {
+		flush()
+	}
+
+	return summary, nil
+}
+
+// parseError is a malformed record. It is reported and skipped, unlike read
+// errors which abort the import.
+type parseError struct {
+	err error
+}
+
+func (e *parseError) Error() string {
+	return "malformed record: " + e.err.Error()
+}
+
+func (e *parseError) Unwrap() error {
+	return e.err
+}
+
+func jsonlReader(r io.Reader) func() (Record, int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+
+	return func() (Record, int, error) {
+		for scanner.Scan() {
+			line++
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+
+			var rec Record
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				return Record{}, line, &parseError{err: err}
+			}
+			return rec, line, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return Record{}, line, err
+		}
+		return Record{}, line, io.EOF
+	}
+}
+
+func csvReader(r io.Reader) func() (Record, int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	var columns map[string]int
+
+	return func() (Record, int, error) {
+		for {
+			row, err := cr.Read()
+			var csvErr *csv.ParseError
+			if errors.As(err, &csvErr) && columns != nil {
+				return Record{}, csvErr.Line, &parseError{err: err}
+			}
+			if err != nil {
+				return Record{}, 0, err
+			}
+			line, _ := cr.FieldPos(0)
+
+			if columns == nil {
+				columns = make(map[string]int, len(row))
+				for i, name := range row {
+					columns[name] = i
+				}
+				for _, name := range []string{"key", "url"} {
+					if _, ok := columns[name]; !ok {
+						return Record{}, line, fmt.Errorf("csv header has no %s column", name)
+					}
+				}
+				continue
+			}
+
+			rec, err := parseCSVRow(row, columns)
+			if err != nil {
+				return Record{}, line, &parseError{err: err}
+			}
+			return rec, line, nil
+		}
+	}
+}
+
+func parseCSVRow(row []string, columns map[string]int) (Record, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	rec := Record{
+		Key:            field("key"),
+		URL:            field("url"),
+		Owner:          field("owner"),
+		PasswordHash:   field("password_hash"),
+		FallbackURL:    field("fallback_url"),
+		ForwardQuery:   repository.QueryForwarding(field("forward_query")),
+		DisabledReason: field("disabled_reason"),
+		ApprovedURL:    field("approved_url"),
+	}
+
+	if v := field("created_at"); v != "" {
+		created, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return Record{}, fmt.Errorf("created_at: %w", err)
+		}
+		rec.CreatedAt = created
+	}
+	times := []struct {
+		name string
+		dst  **time.Time
+	}{
+		{"expires_at", &rec.ExpiresAt},
+		{"not_before", &rec.NotBefore},
+		{"not_after", &rec.NotAfter},
+		{"disabled_at", &rec.DisabledAt},
+	}
+	for _, t := range times {
+		if v := field(t.name); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return Record{}, fmt.Errorf("%s: %w", t.name, err)
+			}
+			*t.dst = &parsed
+		}
+	}
+
+	ints := []struct {
+		name string
+		dst  *int64
+	}{
+		{"clicks", &rec.Clicks},
+		{"interstitial", &rec.Interstitial},
+		{"max_clicks", &rec.MaxClicks},
+	}
+	for _, n := range ints {
+		if v := field(n.name); v != "" {
+			parsed, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return Record{}, fmt.Errorf("%s: %w", n.name, err)
+			}
+			*n.dst = parsed
+		}
+	}
+	if v := field("remaining_clicks"); v != "" {
+		remaining, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return Record{}, fmt.Errorf("remaining_clicks: %w", err)
+		}
+		rec.RemainingClicks = &remaining
+	}
+
+	bools := []struct {
+		name string
+		dst  *bool
+	}{
+		{"forward_path", &rec.ForwardPath},
+		{"signed", &rec.Signed},
+	}
+	for _, b := range bools {
+		if v := field(b.name); v != "" {
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				return Record{}, fmt.Errorf("%s: %w", b.name, err)
+			}
+			*b.dst = parsed
+		}
+	}
+
+	if v := field("rules"); v != "" {
+		if err := json.Unmarshal([]byte(v), &rec.Rules); err != nil {
+			return Record{}, fmt.Errorf("rules: %w", err)
+		}
+	}
+	if v := field("variants"); v != "" {
+		if err := json.Unmarshal([]byte(v), &rec.Variants); err != nil {
+			return Record{}, fmt.Errorf("variants: %w", err)
+		}
+	}
+	if v := field("utm"); v != "" {
+		if err := json.Unmarshal([]byte(v), &rec.UTM); err != nil {
+			return Record{}, fmt.Errorf("utm: %w", err)
+		}
+	}
+
+	return rec, nil
+}
@@ -0,0 +1,180 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/repository"
+	"url-shortener/service"
+)
+
+type memoryStore struct {
+	links    []repository.Link
+	imported []repository.Link
+}
+
+func (m *memoryStore) ExportLinks(ctx context.Context, fn func(repository.Link) error) error {
+	for _, link := range m.links {
+		if err := fn(link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryStore) ImportLinks(ctx context.Context, links []repository.Link) []error {
+	errs := make([]error, len(links))
+	for i, link := range links {
+		if link.Key == "taken" {
+			errs[i] = service.ErrConflict
+			continue
+		}
+		if !strings.HasPrefix(link.URL, "https://") {
+			errs[i] = service.ErrInvalidURL
+			continue
+		}
+		m.imported = append(m.imported, link)
+	}
+	return errs
+}
+
+func TestRoundTrip(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	links := []repository.Link{
+		{Key: "abc123", URL: "https://example.com/?a=1,b=2", Owner: "alice", CreatedAt: created, Clicks: 12},
+		{Key: "promo", URL: "https://example.org", CreatedAt: created, ExpiresAt: expires},
+		{
+			Key:             "everything",
+			URL:             "https://example.net/launch",
+			Owner:           "bob",
+			CreatedAt:       created,
+			ExpiresAt:       expires,
+			Clicks:          3,
+			Interstitial:    5 * time.Second,
+			PasswordHash:    "$2a$10$hash",
+			MaxClicks:       10,
+			RemainingClicks: 7,
+			NotBefore:       created.Add(time.Hour),
+			NotAfter:        expires,
+			FallbackURL:     "https://example.net/soon",
+			Rules:           []repository.Rule{{Platform: "ios", From: "09:00", To: "17:00", URL: "https://apps.example.net"}},
+			Variants:        []repository.Variant{{URL: "https://example.net/a", Weight: 1}, {URL: "https://example.net/b", Weight: 3}},
+			UTM:             repository.UTM{Source: "newsletter", Campaign: "launch"},
+			ForwardQuery:    repository.ForwardKeep,
+			ForwardPath:     true,
+			Signed:          true,
+			DisabledReason:  "blocked by policy",
+			DisabledAt:      created.Add(2 * time.Hour),
+			ApprovedURL:     "https://example.net/old",
+		},
+		{Key: "burnt", URL: "https://example.com/invite", CreatedAt: created, MaxClicks: 1},
+	}
+
+	// A field added to repository.Link later has to be set above, and so
+	// carried by both formats, before this passes. Variant belongs to a
+	// visit and is never stored.
+	everything := reflect.ValueOf(links[2])
+	for i := 0; i < everything.NumField(); i++ {
+		if everything.Field(i).IsZero() && everything.Type().Field(i).Name != "Variant" {
+			t.Fatalf("link %q leaves %s unset", links[2].Key, everything.Type().Field(i).Name)
+		}
+	}
+
+	for _, format := range []Format{FormatJSONL, FormatCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Export(context.Background(), &buf, format, &memoryStore{links: links}); err != nil {
+				t.Fatalf("export failed: %v", err)
+			}
+
+			dst := &memoryStore{}
+			summary, err := Import(context.Background(), &buf, format, dst)
+			if err != nil {
+				t.Fatalf("import failed: %v", err)
+			}
+
+			if summary.Imported != len(links) || summary.Failed != 0 || summary.Conflicts != 0 {
+				t.Errorf("unexpected summary: %+v", summary)
+			}
+			if len(dst.imported) != len(links) {
+				t.Fatalf("expected %d imported links, got %d", len(links), len(dst.imported))
+			}
+			for i, want := range links {
+				if got := dst.imported[i]; !reflect.DeepEqual(got, want) {
+					t.Errorf("link %d:\ngot  %+v\nwant %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestImport_RemainingClicksDefaultsToMaxClicks(t *testing.T) {
+	input := `{"key":"invite","url":"https://example.com","max_clicks":5}` + "\n"
+	dst := &memoryStore{}
+	if _, err := Import(context.Background(), strings.NewReader(input), FormatJSONL, dst); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(dst.imported) != 1 || dst.imported[0].RemainingClicks != 5 {
+		t.Errorf("expected the full click budget, got %+v", dst.imported)
+	}
+}
+
+func TestImport_ReportsFailures(t *testing.T) {
+	input := `key,url,clicks
+ok,https://example.com,1
+taken,https://example.org,0
+bad,ftp://example.org,0
+nan,https://example.net,lots
+`
+	summary, err := Import(context.Background(), strings.NewReader(input), FormatCSV, &memoryStore{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if summary.Imported != 1 || summary.Conflicts != 1 || summary.Failed != 2 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+
+	lines := make(map[int]string)
+	for _, e := range summary.Errors {
+		lines[e.Line] = e.Key
+	}
+	if lines[3] != "taken" || lines[4] != "bad" {
+		t.Errorf("expected failures to carry their line and key, got %+v", summary.Errors)
+	}
+	if _, ok := lines[5]; !ok {
+		t.Errorf("expected malformed row on line 5 to be reported, got %+v", summary.Errors)
+	}
+}
+
+func TestImport_MalformedJSONLine(t *testing.T) {
+	input := "{\"key\": \"ok\", \"url\": \"https://example.com\"}\n{not json}\n\n"
+	summary, err := Import(context.Background(), strings.NewReader(input), FormatJSONL, &memoryStore{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if summary.Imported != 1 || summary.Failed != 1 || summary.Errors[0].Line != 2 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestImport_CSVHeaderRequired(t *testing.T) {
+	_, err := Import(context.Background(), strings.NewReader("url\nhttps://example.com\n"), FormatCSV, &memoryStore{})
+	if err == nil {
+		t.Error("expected error for header without key column")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if _, err := ParseFormat("xml"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("expected ErrUnknownFormat, got %v", err)
+	}
+	if f, err := ParseFormat("csv"); err != nil || f != FormatCSV {
+		t.Errorf("expected csv, got %q, %v", f, err)
+	}
+}
@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598, which is
+// as internal as the private ranges but not covered by netip.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddress reports whether ip may receive deliveries. Loopback,
+// private, link-local and similar addresses are refused so that a
+// subscription cannot make the server POST to itself, to its network or to
+// a cloud metadata endpoint such as 169.254.169.254.
+func publicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() &&
+		!ip.IsPrivate() &&
+		!sharedAddressSpace.Contains(ip)
+}
+
+// checkHost resolves host and refuses it unless every address it has is
+// public. It catches obvious mistakes when subscribing; the dialer checks
+// again on every delivery, since the host may resolve differently later.
+func checkHost(ctx context.Context, host string) error {
+	addrs := []netip.Addr{}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		addrs = append(addrs, ip)
+	} else {
+		addrs, err = net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidURL, err)
+		}
+	}
+	for _, ip := range addrs {
+		if !publicAddress(ip) {
+			return fmt.Errorf("%w: %s is not a public address", ErrInvalidURL, ip)
+		}
+	}
+	return nil
+}
+
+// newHTTPClient returns the client deliveries are sent with. Unless
+// allowPrivate is set it refuses to connect to anything but public
+// addresses, checked after resolution so that DNS rebinding and redirects
+// cannot get around it. Proxies are not used, since the check would only
+// see the proxy's address.
+func newHTTPClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   refusePrivate,
+		}
+		transport.Proxy = nil
+		transport.DialContext = dialer.DialContext
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !publicAddress(addrPort.Addr()) {
+		return fmt.Errorf("webhook: refusing to connect to %s", addrPort.Addr())
+	}
+	return nil
+}
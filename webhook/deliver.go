@@ -0,0 +1,145 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+	"url-shortener/service"
+)
+
+const (
+	HeaderID        = "X-Webhook-ID"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Payload is the JSON body POSTed to subscribers.
+type Payload struct {
+	ID string `json:"id"`
+	service.Event
+}
+
+// Sign returns the X-Webhook-Signature header for body sent at timestamp:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">". Receivers should
+// recompute it and reject old timestamps to stop replays.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t + "."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run delivers due deliveries every interval until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := d.DeliverDue(ctx); err != nil {
+			slog.ErrorContext(ctx, "webhook delivery failed", "error", err)
+		}
+	}
+}
+
+// DeliverDue sends every delivery that is due and returns how many were
+// attempted. Deliveries claimed by a process that dies before sending them
+// are lost.
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	attempted := 0
+	for {
+		due, err := d.store.ClaimDue(ctx, d.now(), claimBatch)
+		if err != nil {
+			return attempted, err
+		}
+		for _, delivery := range due {
+			if err := d.deliver(ctx, delivery); err != nil {
+				return attempted, err
+			}
+			attempted++
+		}
+		if len(due) < claimBatch {
+			return attempted, nil
+		}
+	}
+}
+
+// deliver sends one delivery and schedules a retry or dead-letters it if
+// that fails. Only store errors are returned.
+func (d *Dispatcher) deliver(ctx context.Context, delivery Delivery) error {
+	sub, err := d.store.Subscription(ctx, delivery.Subscription)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = d.send(ctx, sub, delivery)
+	if err == nil {
+		return nil
+	}
+
+	delivery.Attempts++
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.maxAttempts {
+		slog.WarnContext(ctx, "webhook delivery dead-lettered",
+			"id", delivery.ID, "subscription", sub.ID, "attempts", delivery.Attempts, "error", err)
+		return d.store.DeadLetter(ctx, delivery)
+	}
+	delivery.NextAttempt = d.now().Add(d.retryDelay(delivery.Attempts))
+	return d.store.Enqueue(ctx, delivery)
+}
+
+func (d *Dispatcher) send(ctx context.Context, sub Subscription, delivery Delivery) error {
+	body, err := json.Marshal(Payload{ID: delivery.ID, Event: delivery.Event})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, delivery.ID)
+	req.Header.Set(HeaderEvent, string(delivery.Event.Type))
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, d.now(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// retryDelay is the wait after the given number of failed attempts.
+func (d *Dispatcher) retryDelay(attempts int) time.Duration {
+	delay := d.backoff
+	for i := 1; i < attempts && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.maxBackoff)
+}
@@ -0,0 +1,206 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/repository"
+	"url-shortener/service"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// maxDeadLetters caps the dead-letter list; older entries are dropped.
+const maxDeadLetters = 1000
+
+type redisStore struct {
+	client *redis.Client
+	keys   repository.Keyspace
+}
+
+// NewRedisStore keeps subscriptions in hashes and queues deliveries in a
+// sorted set scored by their next attempt, all under "webhook:" in keys.
+func NewRedisStore(client *redis.Client, keys repository.Keyspace) Store {
+	return &redisStore{client: client, keys: keys}
+}
+
+func (s *redisStore) subscriptionKey(id string) string {
+	return s.keys.Key("webhook", "subscription", id)
+}
+
+func (s *redisStore) subscriptionsIndex() string {
+	return s.keys.Key("webhook", "subscriptions")
+}
+
+func (s *redisStore) queueKey() string {
+	return s.keys.Key("webhook", "queue")
+}
+
+func (s *redisStore) deadLetterKey() string {
+	return s.keys.Key("webhook", "dead")
+}
+
+func (s *redisStore) SaveSubscription(ctx context.Context, sub Subscription) error {
+	events := make([]string, len(sub.Events))
+	for i, event := range sub.Events {
+		events[i] = string(event)
+	}
+	fields := map[string]any{
+		"owner":      sub.Owner,
+		"url":        sub.URL,
+		"secret":     sub.Secret,
+		"events":     strings.Join(events, ","),
+		"created_at": sub.CreatedAt.Unix(),
+	}
+	if sub.All {
+		fields["all"] = "1"
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, s.subscriptionKey(sub.ID), fields)
+	pipe.SAdd(ctx, s.subscriptionsIndex(), sub.ID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *redisStore) Subscription(ctx context.Context, id string) (Subscription, error) {
+	fields, err := s.client.HGetAll(ctx, s.subscriptionKey(id)).Result()
+	if err != nil {
+		return Subscription{}, err
+	}
+	if len(fields) == 0 {
+		return Subscription{}, ErrNotFound
+	}
+	return subscriptionFromFields(id, fields), nil
+}
+
+func (s *redisStore) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	ids, err := s.client.SMembers(ctx, s.subscriptionsIndex()).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.HGetAll(ctx, s.subscriptionKey(id))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	subs := make([]Subscription, 0, len(ids))
+	for i, id := range ids {
+		if fields := cmds[i].Val(); len(fields) > 0 {
+			subs = append(subs, subscriptionFromFields(id, fields))
+		}
+	}
+	return subs, nil
+}
+
+func subscriptionFromFields(id string, fields map[string]string) Subscription {
+	sub := Subscription{
+		ID:     id,
+		Owner:  fields["owner"],
+		URL:    fields["url"],
+		Secret: fields["secret"],
+		All:    fields["all"] == "1",
+	}
+	if events := fields["events"]; events != "" {
+		for _, event := range strings.Split(events, ",") {
+			sub.Events = append(sub.Events, service.EventType(event))
+		}
+	}
+	if sec, err := strconv.ParseInt(fields["created_at"], 10, 64); err == nil {
+		sub.CreatedAt = time.Unix(sec, 0).UTC()
+	}
+	return sub
+}
+
+func (s *redisStore) DeleteSubscription(ctx context.Context, id string) error {
+	pipe := s.client.TxPipeline()
+	deleted := pipe.Del(ctx, s.subscriptionKey(id))
+	pipe.SRem(ctx, s.subscriptionsIndex(), id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	if deleted.Val() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *redisStore) Enqueue(ctx context.Context, d Delivery) error {
+	member, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return s.client.ZAdd(ctx, s.queueKey(), redis.Z{
+		Score:  float64(d.NextAttempt.UnixMilli()),
+		Member: member,
+	}).Err()
+}
+
+func (s *redisStore) ClaimDue(ctx context.Context, now time.Time, n int) ([]Delivery, error) {
+	members, err := s.client.ZRangeByScore(ctx, s.queueKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.UnixMilli(), 10),
+		Count: int64(n),
+	}).Result()
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+
+	// Only the instance whose ZREM removed a member owns it.
+	pipe := s.client.Pipeline()
+	removed := make([]*redis.IntCmd, len(members))
+	for i, member := range members {
+		removed[i] = pipe.ZRem(ctx, s.queueKey(), member)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]Delivery, 0, len(members))
+	for i, member := range members {
+		if removed[i].Val() == 0 {
+			continue
+		}
+		var d Delivery
+		if err := json.Unmarshal([]byte(member), &d); err != nil {
+			continue
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+func (s *redisStore) DeadLetter(ctx context.Context, d Delivery) error {
+	entry, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	pipe := s.client.TxPipeline()
+	pipe.LPush(ctx, s.deadLetterKey(), entry)
+	pipe.LTrim(ctx, s.deadLetterKey(), 0, maxDeadLetters-1)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *redisStore) DeadLetters(ctx context.Context, n int) ([]Delivery, error) {
+	entries, err := s.client.LRange(ctx, s.deadLetterKey(), 0, int64(n)-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]Delivery, 0, len(entries))
+	for _, entry := range entries {
+		var d Delivery
+		if err := json.Unmarshal([]byte(entry), &d); err != nil {
+			continue
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,42 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+	"url-shortener/repository"
+	"url-shortener/service"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisStore_KeysUnderPrefix(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	store := NewRedisStore(client, repository.NewKeyspace("app"))
+	ctx := context.Background()
+
+	sub := Subscription{ID: "s1", Owner: "alice", URL: "https://example.com/hook", Secret: "secret", CreatedAt: time.Now()}
+	if err := store.SaveSubscription(ctx, sub); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	delivery := Delivery{ID: "d1", Subscription: "s1", Event: service.Event{Type: service.EventLinkCreated, Key: "abc"}, NextAttempt: time.Now()}
+	if err := store.Enqueue(ctx, delivery); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.DeadLetter(ctx, delivery); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, key := range []string{"app:webhook:subscription:s1", "app:webhook:subscriptions", "app:webhook:queue", "app:webhook:dead"} {
+		if !mr.Exists(key) {
+			t.Errorf("expected %s, got keys %v", key, mr.Keys())
+		}
+	}
+	got, err := store.Subscription(ctx, "s1")
+	if err != nil || got.URL != sub.URL {
+		t.Errorf("Subscription() = %+v, %v", got, err)
+	}
+}
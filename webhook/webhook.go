@@ -0,0 +1,288 @@
+// Package webhook notifies subscribers of link lifecycle events. Events from
+// the service are queued as deliveries in a Store and POSTed by a worker,
+// signed with the subscription's secret. Failed deliveries are retried with
+// exponential backoff and end up in a dead-letter list.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+	"url-shortener/service"
+)
+
+var (
+	ErrNotFound     = errors.New("webhook not found")
+	ErrInvalidURL   = errors.New("invalid webhook url")
+	ErrInvalidEvent = errors.New("invalid event type")
+)
+
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxAttempts is how often a delivery is tried before it is dead-lettered.
+	MaxAttempts int `yaml:"max_attempts"`
+	// Backoff is the wait after the first failure. It doubles with every
+	// further failure up to MaxBackoff.
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+	Timeout    time.Duration `yaml:"timeout"`
+	// PollInterval is how often the queue is checked for due deliveries.
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// ExpiryInterval is how often expired links are purged, which is when
+	// their expired events are sent.
+	ExpiryInterval time.Duration `yaml:"expiry_interval"`
+	// Milestones are the click counts reported by milestone events.
+	Milestones []int64 `yaml:"milestones"`
+	// AllowPrivateAddresses lets subscriptions point at loopback, private
+	// and link-local addresses, for deployments whose receivers are
+	// internal. Otherwise any client able to subscribe could make the
+	// server send requests into its own network.
+	AllowPrivateAddresses bool `yaml:"allow_private_addresses"`
+}
+
+// Subscription asks for events of links owned by Owner, or of all links if
+// All is set, to be POSTed to URL.
+type Subscription struct {
+	ID    string
+	Owner string
+	URL   string
+	// Secret signs every payload sent to URL.
+	Secret string
+	// Events limits the subscription to these types. Empty means all.
+	Events    []service.EventType
+	All       bool
+	CreatedAt time.Time
+}
+
+// matches reports whether event should be delivered to sub.
+func (sub Subscription) matches(event service.Event) bool {
+	if !sub.All && (event.Owner == "" || event.Owner != sub.Owner) {
+		return false
+	}
+	return len(sub.Events) == 0 || slices.Contains(sub.Events, event.Type)
+}
+
+// Delivery is one event on its way to one subscription. Its ID stays the
+// same across retries so receivers can drop duplicates.
+type Delivery struct {
+	ID           string        `json:"id"`
+	Subscription string        `json:"subscription"`
+	Event        service.Event `json:"event"`
+	// Attempts counts the failed attempts so far.
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error,omitempty"`
+	NextAttempt time.Time `json:"next_attempt"`
+}
+
+// Store keeps subscriptions and the delivery queue.
+type Store interface {
+	SaveSubscription(ctx context.Context, sub Subscription) error
+	Subscription(ctx context.Context, id string) (Subscription, error)
+	Subscriptions(ctx context.Context) ([]Subscription, error)
+	DeleteSubscription(ctx context.Context, id string) error
+	// Enqueue schedules d for d.NextAttempt.
+	Enqueue(ctx context.Context, d Delivery) error
+	// ClaimDue removes up to n deliveries due at now from the queue and
+	// returns them. Each delivery is claimed by one caller only.
+	ClaimDue(ctx context.Context, now time.Time, n int) ([]Delivery, error)
+	DeadLetter(ctx context.Context, d Delivery) error
+	// DeadLetters returns up to n dead-lettered deliveries, newest first.
+	DeadLetters(ctx context.Context, n int) ([]Delivery, error)
+}
+
+// Registry manages subscriptions on behalf of API clients.
+type Registry interface {
+	Subscribe(ctx context.Context, sub Subscription) (Subscription, error)
+	// Subscriptions lists the owner's subscriptions, or all of them if owner
+	// is empty.
+	Subscriptions(ctx context.Context, owner string) ([]Subscription, error)
+	// Unsubscribe deletes a subscription of owner, or any subscription if
+	// owner is empty.
+	Unsubscribe(ctx context.Context, owner, id string) error
+	DeadLetters(ctx context.Context, n int) ([]Delivery, error)
+}
+
+const (
+	defaultMaxAttempts = 8
+	defaultBackoff     = 30 * time.Second
+	defaultMaxBackoff  = time.Hour
+	defaultTimeout     = 10 * time.Second
+	claimBatch         = 100
+)
+
+// Dispatcher queues deliveries for service events and sends them.
+type Dispatcher struct {
+	store        Store
+	client       *http.Client
+	timeout      time.Duration
+	allowPrivate bool
+	maxAttempts  int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	now          func() time.Time
+}
+
+type Option func(*Dispatcher)
+
+// WithHTTPClient sends deliveries with client instead of one that only
+// connects to public addresses. Subscriptions are still checked unless
+// WithPrivateAddresses allows private ones.
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithTimeout bounds each delivery, including reading the response.
+func WithTimeout(timeout time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.timeout = timeout
+	}
+}
+
+// WithPrivateAddresses allows subscriptions and deliveries to loopback,
+// private and link-local addresses.
+func WithPrivateAddresses(allow bool) Option {
+	return func(d *Dispatcher) {
+		d.allowPrivate = allow
+	}
+}
+
+// WithRetries tries every delivery up to maxAttempts times, waiting backoff
+// after the first failure and twice as long after each further one, up to
+// maxBackoff.
+func WithRetries(maxAttempts int, backoff, maxBackoff time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.maxAttempts = maxAttempts
+		d.backoff = backoff
+		d.maxBackoff = maxBackoff
+	}
+}
+
+// WithClock replaces time.Now for scheduling retries.
+func WithClock(now func() time.Time) Option {
+	return func(d *Dispatcher) {
+		d.now = now
+	}
+}
+
+func NewDispatcher(store Store, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		store:       store,
+		timeout:     defaultTimeout,
+		maxAttempts: defaultMaxAttempts,
+		backoff:     defaultBackoff,
+		maxBackoff:  defaultMaxBackoff,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.client == nil {
+		d.client = newHTTPClient(d.timeout, d.allowPrivate)
+	}
+	return d
+}
+
+// HandleEvent queues a delivery of event for every matching subscription.
+func (d *Dispatcher) HandleEvent(ctx context.Context, event service.Event) error {
+	subs, err := d.store.Subscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, sub := range subs {
+		if !sub.matches(event) {
+			continue
+		}
+		id, err := randomID(8)
+		if err != nil {
+			return err
+		}
+		errs = append(errs, d.store.Enqueue(ctx, Delivery{
+			ID:           id,
+			Subscription: sub.ID,
+			Event:        event,
+			NextAttempt:  d.now(),
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// Subscribe validates sub and stores it under a new ID and secret.
+func (d *Dispatcher) Subscribe(ctx context.Context, sub Subscription) (Subscription, error) {
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return Subscription{}, ErrInvalidURL
+	}
+	for _, event := range sub.Events {
+		if !slices.Contains(service.EventTypes, event) {
+			return Subscription{}, ErrInvalidEvent
+		}
+	}
+	if !d.allowPrivate {
+		if err := checkHost(ctx, u.Hostname()); err != nil {
+			return Subscription{}, err
+		}
+	}
+
+	if sub.ID, err = randomID(8); err != nil {
+		return Subscription{}, err
+	}
+	if sub.Secret, err = randomID(32); err != nil {
+		return Subscription{}, err
+	}
+	sub.CreatedAt = d.now().UTC().Truncate(time.Second)
+
+	if err := d.store.SaveSubscription(ctx, sub); err != nil {
+		return Subscription{}, err
+	}
+	slog.InfoContext(ctx, "webhook subscribed", "id", sub.ID, "owner", sub.Owner, "url", sub.URL)
+	return sub, nil
+}
+
+func (d *Dispatcher) Subscriptions(ctx context.Context, owner string) ([]Subscription, error) {
+	subs, err := d.store.Subscriptions(ctx)
+	if err != nil || owner == "" {
+		return subs, err
+	}
+	return slices.DeleteFunc(subs, func(sub Subscription) bool {
+		return sub.Owner != owner
+	}), nil
+}
+
+func (d *Dispatcher) Unsubscribe(ctx context.Context, owner, id string) error {
+	sub, err := d.store.Subscription(ctx, id)
+	if err != nil {
+		return err
+	}
+	// Other owners' subscriptions are reported as missing so that their IDs
+	// cannot be probed.
+	if owner != "" && sub.Owner != owner {
+		return ErrNotFound
+	}
+	if err := d.store.DeleteSubscription(ctx, id); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "webhook unsubscribed", "id", id, "owner", sub.Owner)
+	return nil
+}
+
+func (d *Dispatcher) DeadLetters(ctx context.Context, n int) ([]Delivery, error) {
+	return d.store.DeadLetters(ctx, n)
+}
+
+func randomID(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,348 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"url-shortener/service"
+)
+
+// memoryStore is an in-memory Store for tests.
+type memoryStore struct {
+	mu    sync.Mutex
+	subs  map[string]Subscription
+	queue []Delivery
+	dead  []Delivery
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{subs: make(map[string]Subscription)}
+}
+
+func (s *memoryStore) SaveSubscription(ctx context.Context, sub Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs[sub.ID] = sub
+	return nil
+}
+
+func (s *memoryStore) Subscription(ctx context.Context, id string) (Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subs[id]
+	if !ok {
+		return Subscription{}, ErrNotFound
+	}
+	return sub, nil
+}
+
+func (s *memoryStore) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subs := make([]Subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		subs = append(subs, sub)
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	return subs, nil
+}
+
+func (s *memoryStore) DeleteSubscription(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subs[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.subs, id)
+	return nil
+}
+
+func (s *memoryStore) Enqueue(ctx context.Context, d Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, d)
+	return nil
+}
+
+func (s *memoryStore) ClaimDue(ctx context.Context, now time.Time, n int) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due, rest []Delivery
+	for _, d := range s.queue {
+		if len(due) < n && !d.NextAttempt.After(now) {
+			due = append(due, d)
+		} else {
+			rest = append(rest, d)
+		}
+	}
+	s.queue = rest
+	return due, nil
+}
+
+func (s *memoryStore) DeadLetter(ctx context.Context, d Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dead = append([]Delivery{d}, s.dead...)
+	return nil
+}
+
+func (s *memoryStore) DeadLetters(ctx context.Context, n int) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dead[:min(n, len(s.dead))], nil
+}
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver records requests and answers them with the next status from
+// statuses, then 200.
+func receiver(t *testing.T, statuses ...int) (*httptest.Server, func() []received) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, received{header: r.Header.Clone(), body: body})
+		status := http.StatusOK
+		if len(statuses) > 0 {
+			status, statuses = statuses[0], statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []received {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(requests)
+	}
+}
+
+func TestDispatcher_Deliver(t *testing.T) {
+	srv, requests := receiver(t)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	d := NewDispatcher(newMemoryStore(), WithPrivateAddresses(true), WithClock(func() time.Time { return now }))
+	ctx := context.Background()
+
+	sub, err := d.Subscribe(ctx, Subscription{Owner: "alice", URL: srv.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sub.ID == "" || len(sub.Secret) != 64 {
+		t.Fatalf("expected an ID and a secret, got %+v", sub)
+	}
+
+	event := service.Event{Type: service.EventLinkCreated, Key: "abc", Owner: "alice", URL: "https://example.com", At: now}
+	if err := d.HandleEvent(ctx, event); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n, err := d.DeliverDue(ctx); err != nil || n != 1 {
+		t.Fatalf("DeliverDue() = %d, %v", n, err)
+	}
+
+	reqs := requests()
+	if len(reqs) != 1 {
+		t.Fatalf("expected one request, got %d", len(reqs))
+	}
+	req := reqs[0]
+	if got, want := req.header.Get(HeaderSignature), Sign(sub.Secret, now, req.body); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if req.header.Get(HeaderEvent) != "link.created" || req.header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected headers: %v", req.header)
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(req.body, &payload); err != nil {
+		t.Fatalf("invalid payload %s: %v", req.body, err)
+	}
+	if payload.ID != req.header.Get(HeaderID) || payload.Key != "abc" || payload.URL != "https://example.com" {
+		t.Errorf("unexpected payload: %s", req.body)
+	}
+}
+
+func TestDispatcher_Matching(t *testing.T) {
+	srv, requests := receiver(t)
+	d := NewDispatcher(newMemoryStore(), WithPrivateAddresses(true))
+	ctx := context.Background()
+
+	subs := []Subscription{
+		{Owner: "alice", URL: srv.URL + "/alice"},
+		{Owner: "alice", URL: srv.URL + "/deleted", Events: []service.EventType{service.EventLinkDeleted}},
+		{Owner: "bob", URL: srv.URL + "/bob"},
+		{Owner: "ops", URL: srv.URL + "/all", All: true},
+	}
+	for _, sub := range subs {
+		if _, err := d.Subscribe(ctx, sub); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	_ = d.HandleEvent(ctx, service.Event{Type: service.EventLinkCreated, Key: "a", Owner: "alice"})
+	_ = d.HandleEvent(ctx, service.Event{Type: service.EventLinkCreated, Key: "anon"})
+	if _, err := d.DeliverDue(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []string
+	for _, req := range requests() {
+		var payload Payload
+		_ = json.Unmarshal(req.body, &payload)
+		got = append(got, payload.Key)
+	}
+	sort.Strings(got)
+	if want := []string{"a", "a", "anon"}; !slices.Equal(got, want) {
+		t.Errorf("delivered %v, want %v", got, want)
+	}
+}
+
+func TestDispatcher_RetryAndDeadLetter(t *testing.T) {
+	srv, requests := receiver(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := newMemoryStore()
+	d := NewDispatcher(store,
+		WithPrivateAddresses(true),
+		WithClock(func() time.Time { return now }),
+		WithRetries(3, time.Minute, 90*time.Second),
+	)
+	ctx := context.Background()
+
+	if _, err := d.Subscribe(ctx, Subscription{Owner: "alice", URL: srv.URL}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = d.HandleEvent(ctx, service.Event{Type: service.EventLinkDeleted, Key: "abc", Owner: "alice"})
+
+	steps := []struct {
+		advance time.Duration
+		sent    int
+	}{
+		{0, 1},
+		{59 * time.Second, 1}, // first retry waits the base backoff
+		{time.Second, 2},
+		{89 * time.Second, 2}, // the doubled backoff is capped
+		{time.Second, 3},
+	}
+	for i, step := range steps {
+		now = now.Add(step.advance)
+		if _, err := d.DeliverDue(ctx); err != nil {
+			t.Fatalf("step %d: unexpected error: %v", i, err)
+		}
+		if got := len(requests()); got != step.sent {
+			t.Fatalf("step %d: %d requests, want %d", i, got, step.sent)
+		}
+	}
+
+	dead, _ := d.DeadLetters(ctx, 10)
+	if len(dead) != 1 {
+		t.Fatalf("expected one dead letter, got %d", len(dead))
+	}
+	if dead[0].Attempts != 3 || dead[0].LastError != "unexpected status 503" {
+		t.Errorf("unexpected dead letter: %+v", dead[0])
+	}
+	if len(store.queue) != 0 {
+		t.Errorf("expected an empty queue, got %d", len(store.queue))
+	}
+
+	// All deliveries carry the same ID so receivers can deduplicate.
+	reqs := requests()
+	if reqs[0].header.Get(HeaderID) != reqs[2].header.Get(HeaderID) {
+		t.Error("expected retries to reuse the delivery ID")
+	}
+}
+
+func TestDispatcher_Unsubscribe(t *testing.T) {
+	srv, requests := receiver(t)
+	d := NewDispatcher(newMemoryStore(), WithPrivateAddresses(true))
+	ctx := context.Background()
+
+	sub, _ := d.Subscribe(ctx, Subscription{Owner: "alice", URL: srv.URL})
+	_ = d.HandleEvent(ctx, service.Event{Type: service.EventLinkCreated, Key: "abc", Owner: "alice"})
+
+	if err := d.Unsubscribe(ctx, "bob", sub.ID); err != ErrNotFound {
+		t.Errorf("expected other owners to get ErrNotFound, got %v", err)
+	}
+	if err := d.Unsubscribe(ctx, "alice", sub.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Deliveries queued before unsubscribing are dropped.
+	if _, err := d.DeliverDue(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(requests()) != 0 {
+		t.Error("expected no deliveries after unsubscribing")
+	}
+}
+
+func TestDispatcher_SubscribeInvalid(t *testing.T) {
+	d := NewDispatcher(newMemoryStore())
+	ctx := context.Background()
+
+	if _, err := d.Subscribe(ctx, Subscription{URL: "ftp://example.com"}); err != ErrInvalidURL {
+		t.Errorf("expected ErrInvalidURL, got %v", err)
+	}
+	if _, err := d.Subscribe(ctx, Subscription{URL: "https://example.com", Events: []service.EventType{"link.visited"}}); err != ErrInvalidEvent {
+		t.Errorf("expected ErrInvalidEvent, got %v", err)
+	}
+}
+
+func TestDispatcher_SubscribePrivateAddress(t *testing.T) {
+	d := NewDispatcher(newMemoryStore())
+	ctx := context.Background()
+
+	for _, url := range []string{
+		"http://169.254.169.254/latest/meta-data",
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"https://10.0.0.1/hook",
+		"https://192.168.1.1/hook",
+		"https://100.64.0.1/hook",
+		"https://[::1]/hook",
+		"https://[fe80::1]/hook",
+		"https://[::ffff:127.0.0.1]/hook",
+		"https://0.0.0.0/hook",
+	} {
+		if _, err := d.Subscribe(ctx, Subscription{URL: url}); !errors.Is(err, ErrInvalidURL) {
+			t.Errorf("%s: expected ErrInvalidURL, got %v", url, err)
+		}
+	}
+	if _, err := d.Subscribe(ctx, Subscription{URL: "https://93.184.215.14/hook"}); err != nil {
+		t.Errorf("expected a public address to be accepted, got %v", err)
+	}
+}
+
+func TestDispatcher_DeliverRefusesPrivateAddress(t *testing.T) {
+	srv, requests := receiver(t)
+	store := newMemoryStore()
+	d := NewDispatcher(store, WithRetries(1, time.Minute, time.Minute))
+	ctx := context.Background()
+
+	// The host passed the check when subscribing and resolves to an
+	// internal address now.
+	_ = store.SaveSubscription(ctx, Subscription{ID: "rebound", Owner: "alice", URL: srv.URL})
+	_ = d.HandleEvent(ctx, service.Event{Type: service.EventLinkCreated, Key: "abc", Owner: "alice"})
+	if _, err := d.DeliverDue(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(requests()) != 0 {
+		t.Error("expected no request to reach the private address")
+	}
+	dead, _ := d.DeadLetters(ctx, 10)
+	if len(dead) != 1 || !strings.Contains(dead[0].LastError, "refusing to connect") {
+		t.Errorf("expected the delivery to be dead-lettered, got %+v", dead)
+	}
+}
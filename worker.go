@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"url-shortener/service"
+)
+
+// recheckLinks runs the destination policy over all stored links every
+// interval until ctx is done.
+func recheckLinks(ctx context.Context, svc service.ShortenerService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		summary, err := svc.RecheckLinks(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "link recheck failed", "error", err)
+			continue
+		}
+		if summary.Disabled > 0 {
+			slog.InfoContext(ctx, "link recheck finished", "checked", summary.Checked, "disabled", summary.Disabled)
+		}
+	}
+}
+
+// purgeExpired cleans up after expired links every interval until ctx is
+// done, which emits their expired events.
+func purgeExpired(ctx context.Context, svc service.ShortenerService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := svc.PurgeExpired(ctx); err != nil {
+			slog.ErrorContext(ctx, "purging expired links failed", "error", err)
+		}
+	}
+}